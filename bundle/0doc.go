@@ -21,4 +21,22 @@
 //
 //   var bndl, err = bundle.NewBundleFromCbor(byteString)
 //
+// Successive bundles might be written to or read from a stream, e.g., a
+// network connection, by an Encoder or a Decoder.
+//
+//   var enc = bundle.NewEncoder(conn)
+//   err = enc.Encode(bndl)
+//
+//   var dec = bundle.NewDecoder(conn)
+//   err = dec.Decode(&bndl)
+//
+// A large payload might be kept in a file by a FilePayload, which is streamed
+// by the Encoder. After configuring a payload spool, the Decoder writes large
+// received payloads into such files.
+//
+//   err = bundle.SetPayloadSpool("/var/spool/dtn", 1<<20)
+//
+//   var fp, err = bundle.NewFilePayload("large.iso")
+//   var payloadBlock = bundle.NewFilePayloadBlock(0, fp)
+//
 package bundle
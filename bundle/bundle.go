@@ -3,10 +3,10 @@ package bundle
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
-	"github.com/ugorji/go/codec"
 )
 
 // Bundle represents a bundle as defined in section 4.2.1. Each Bundle contains
@@ -51,6 +51,50 @@ func (b *Bundle) ExtensionBlock(blockType CanonicalBlockType) (*CanonicalBlock,
 		"No CanonicalBlock with block type %d was found in Bundle", blockType))
 }
 
+// unusedBlockNumber returns the lowest unused block number, greater than zero.
+func (b *Bundle) unusedBlockNumber() uint {
+	var blockNumbers = make(map[uint]bool)
+	for _, cb := range b.CanonicalBlocks {
+		blockNumbers[cb.BlockNumber] = true
+	}
+
+	var blockNumber uint = 1
+	for blockNumbers[blockNumber] {
+		blockNumber++
+	}
+
+	return blockNumber
+}
+
+// AddExtensionBlock adds a new extension block to this Bundle. Its block number
+// will be set to the lowest unused block number, greater than zero. The block
+// is inserted before the payload block, which should be the last block.
+func (b *Bundle) AddExtensionBlock(block CanonicalBlock) {
+	block.BlockNumber = b.unusedBlockNumber()
+
+	var pos = len(b.CanonicalBlocks)
+	for i, cb := range b.CanonicalBlocks {
+		if cb.BlockType == PayloadBlock {
+			pos = i
+			break
+		}
+	}
+
+	b.CanonicalBlocks = append(b.CanonicalBlocks, CanonicalBlock{})
+	copy(b.CanonicalBlocks[pos+1:], b.CanonicalBlocks[pos:])
+	b.CanonicalBlocks[pos] = block
+}
+
+// RemoveExtensionBlock removes all extension blocks of the given block type
+// from this Bundle.
+func (b *Bundle) RemoveExtensionBlock(blockType CanonicalBlockType) {
+	for i := len(b.CanonicalBlocks) - 1; i >= 0; i-- {
+		if b.CanonicalBlocks[i].BlockType == blockType {
+			b.CanonicalBlocks = append(b.CanonicalBlocks[:i], b.CanonicalBlocks[i+1:]...)
+		}
+	}
+}
+
 // PayloadBlock returns this Bundle's payload block or an error, if it does
 // not exists.
 func (b *Bundle) PayloadBlock() (*CanonicalBlock, error) {
@@ -146,7 +190,7 @@ func (b Bundle) checkValid() (errs error) {
 		cbBlockNumbers[cb.BlockNumber] = true
 
 		switch cb.BlockType {
-		case PreviousNodeBlock, BundleAgeBlock, HopCountBlock:
+		case PreviousNodeBlock, BundleAgeBlock, HopCountBlock, SprayAndWaitBlock, PriorityBlock:
 			if _, ok := cbBlockTypes[cb.BlockType]; ok {
 				errs = multierror.Append(errs,
 					newBundleError(fmt.Sprintf(
@@ -156,6 +200,25 @@ func (b Bundle) checkValid() (errs error) {
 		}
 	}
 
+	// Check security blocks' targets
+	for _, cb := range b.CanonicalBlocks {
+		asb, ok := cb.Data.(AbstractSecurityBlock)
+		if !ok || (cb.BlockType != IntegrityBlock && cb.BlockType != ConfidentialityBlock) {
+			continue
+		}
+
+		for _, target := range asb.SecurityTargets {
+			if targetBlock, err := b.canonicalBlockByNumber(target); err != nil {
+				errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+					"Bundle: Security block %d targets the unknown block %d", cb.BlockNumber, target)))
+			} else if targetBlock.BlockType == cb.BlockType {
+				errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+					"Bundle: Security block %d targets another security block %d of its type",
+					cb.BlockNumber, target)))
+			}
+		}
+	}
+
 	if b.PrimaryBlock.CreationTimestamp[0] == 0 {
 		if _, ok := cbBlockTypes[BundleAgeBlock]; !ok {
 			errs = multierror.Append(errs, newBundleError(
@@ -174,71 +237,25 @@ func (b Bundle) IsAdministrativeRecord() bool {
 
 // ToCbor creates a byte array representing a CBOR indefinite-length array of
 // this Bundle with all its blocks, as defined in section 4 of the Bundle
-// Protocol Version 7.
+// Protocol Version 7. The blocks' CRC values are calculated, see Encoder.
+// A FilePayload is read into memory and ToCbor panics if this fails. Thus, a
+// bundle with a FilePayload should rather be written by an Encoder.
 func (b Bundle) ToCbor() []byte {
-	// It seems to be tricky using both definite-length and indefinite-length
-	// arays with the codec library. However, an indefinite-length array is just
-	// a byte array wrapped between the start and "break" code, which are
-	// exported as consts from the codec library.
-
 	var buf bytes.Buffer
-	var cborHandle *codec.CborHandle = new(codec.CborHandle)
-
-	buf.WriteByte(codec.CborStreamArray)
-
-	b.forEachBlock(func(blck block) {
-		codec.NewEncoder(&buf, cborHandle).MustEncode(blck)
-	})
-
-	buf.WriteByte(codec.CborStreamBreak)
+	if err := NewEncoder(&buf).Encode(b); err != nil {
+		panic(err)
+	}
 
 	return buf.Bytes()
 }
 
-// decodeBundleBlock decodes an already generic decoded block to its
-// determinated data structure.
-// The NewBundleFromCbor function decodes an array of interface{} which results
-// in an array of arrays, as codec tries to decode the whole data. This method
-// will re-encode this "anonymous" array to CBOR and will decode it to its
-// struct, which is referenced as the target pointer.
-func decodeBundleBlock(data interface{}, target interface{}) {
-	var b []byte = make([]byte, 0, 64)
-	var cborHandle *codec.CborHandle = new(codec.CborHandle)
-
-	codec.NewEncoderBytes(&b, cborHandle).MustEncode(data)
-	codec.NewDecoderBytes(b, cborHandle).MustDecode(target)
-}
-
 // NewBundleFromCbor tries to decodes the given data from CBOR into a Bundle.
-// It also checks the whole bundle's validity and each block's CRC value.
+// It also checks the whole bundle's validity and each block's CRC value. The
+// returned errors are those of the Decoder.
 func NewBundleFromCbor(data []byte) (b Bundle, err error) {
-	// The decoding might panic and would be recovered in the following function,
-	// which returns an error.
-	defer func() {
-		if r := recover(); r != nil {
-			err = newBundleError(fmt.Sprintf("Bundle: Decoding CBOR failed, %v", r))
-		}
-	}()
-
-	var dataArr []interface{}
-	codec.NewDecoderBytes(data, new(codec.CborHandle)).MustDecode(&dataArr)
-
-	var pb PrimaryBlock
-	decodeBundleBlock(dataArr[0], &pb)
-
-	var cb []CanonicalBlock = make([]CanonicalBlock, len(dataArr)-1)
-	for i := 0; i < len(cb); i++ {
-		decodeBundleBlock(dataArr[i+1], &cb[i])
-	}
-
-	b = Bundle{pb, cb}
-
-	if chkVldErr := b.checkValid(); chkVldErr != nil {
-		err = multierror.Append(err, chkVldErr)
-	}
-
-	if !b.CheckCRC() {
-		err = multierror.Append(err, newBundleError("CRC failed"))
+	err = NewDecoder(bytes.NewReader(data)).Decode(&b)
+	if err == io.EOF {
+		err = newDecodeError("no data", io.ErrUnexpectedEOF)
 	}
 
 	return
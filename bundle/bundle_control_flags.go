@@ -33,6 +33,11 @@ const (
 	// is requested.
 	RequestUserApplicationAck BundleControlFlags = 0x0020
 
+	// RequestCustody: Custody transfer is requested. This flag was defined in
+	// RFC 5050 and is no longer part of draft-ietf-dtn-bpbis-12, but it is
+	// used by this implementation's custody transfer.
+	RequestCustody BundleControlFlags = 0x0008
+
 	// MustNotFragmented: The bundle must not be fragmented.
 	MustNotFragmented BundleControlFlags = 0x0004
 
@@ -43,7 +48,7 @@ const (
 	// IsFragment: The bundle is a fragment.
 	IsFragment BundleControlFlags = 0x0001
 
-	bndlCFReservedFields BundleControlFlags = 0xE218
+	bndlCFReservedFields BundleControlFlags = 0xE210
 )
 
 // Has returns true if a given flag or mask of flags is set.
@@ -76,6 +81,12 @@ func (bcf BundleControlFlags) checkValid() (errs error) {
 				"no status report request flags\" failed"))
 	}
 
+	if bcf.Has(AdministrativeRecordPayload) && bcf.Has(RequestCustody) {
+		errs = multierror.Append(errs, newBundleError(
+			"BundleControlFlags: \"payload is administrative record => "+
+				"no custody request\" failed"))
+	}
+
 	return
 }
 
@@ -93,6 +104,7 @@ func (bcf BundleControlFlags) String() string {
 		{ContainsManifest, "CONTAINS_MANIFEST"},
 		{RequestStatusTime, "REQUESTED_TIME_IN_STATUS_REPORT"},
 		{RequestUserApplicationAck, "REQUESTED_APPLICATION_ACK"},
+		{RequestCustody, "REQUESTED_CUSTODY_TRANSFER"},
 		{MustNotFragmented, "MUST_NO_BE_FRAGMENTED"},
 		{AdministrativeRecordPayload, "ADMINISTRATIVE_PAYLOAD"},
 		{IsFragment, "IS_FRAGMENT"},
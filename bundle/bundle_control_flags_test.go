@@ -28,7 +28,8 @@ func TestBundleControlFlagsImplications(t *testing.T) {
 			StatusRequestReception,
 			StatusRequestForward,
 			StatusRequestDelivery,
-			StatusRequestDeletion}
+			StatusRequestDeletion,
+			RequestCustody}
 	)
 
 	cf |= AdministrativeRecordPayload
@@ -1,5 +1,7 @@
 package bundle
 
+import "fmt"
+
 // bundleError is a simple error-struct.
 type bundleError struct {
 	msg string
@@ -13,3 +15,54 @@ func newBundleError(msg string) *bundleError {
 func (e bundleError) Error() string {
 	return e.msg
 }
+
+// DecodeError is returned by the Decoder for malformed CBOR or an unexpected
+// structure of a bundle. The position within the stream is undefined
+// afterwards.
+type DecodeError struct {
+	Msg string
+	Err error
+}
+
+func newDecodeError(msg string, err error) *DecodeError {
+	return &DecodeError{Msg: msg, Err: err}
+}
+
+func (e *DecodeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Bundle: Decoding CBOR failed, %s: %v", e.Msg, e.Err)
+	}
+
+	return fmt.Sprintf("Bundle: Decoding CBOR failed, %s", e.Msg)
+}
+
+// Unwrap returns the underlying error, e.g., io.ErrUnexpectedEOF.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// CRCError is returned by the Decoder for a block, whose CRC value does not
+// match its content. The Index is the block's position within the bundle,
+// zero for the primary block.
+type CRCError struct {
+	Index int
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("Bundle: CRC of block %d failed", e.Index)
+}
+
+// InvalidBundleError is returned by the Decoder for a well-formed, but invalid
+// bundle. Err holds the violations.
+type InvalidBundleError struct {
+	Err error
+}
+
+func (e *InvalidBundleError) Error() string {
+	return fmt.Sprintf("Bundle: Invalid bundle, %v", e.Err)
+}
+
+// Unwrap returns the underlying violations.
+func (e *InvalidBundleError) Unwrap() error {
+	return e.Err
+}
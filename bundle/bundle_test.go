@@ -246,6 +246,73 @@ func TestBundleExtensionBlock(t *testing.T) {
 	}
 }
 
+func TestBundleAddExtensionBlock(t *testing.T) {
+	var bndl, err = NewBundle(
+		NewPrimaryBlock(
+			MustNotFragmented,
+			MustNewEndpointID("dtn:some"), DtnNone(),
+			NewCreationTimestamp(DtnTimeEpoch, 0), 3600),
+		[]CanonicalBlock{
+			NewBundleAgeBlock(1, 0, 420),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	bndl.AddExtensionBlock(NewHopCountBlock(0, 0, NewHopCount(23)))
+
+	if l := len(bndl.CanonicalBlocks); l != 3 {
+		t.Fatalf("Bundle has %d canonical blocks instead of 3", l)
+	}
+
+	if cb, err := bndl.ExtensionBlock(HopCountBlock); err != nil {
+		t.Errorf("Bundle did not returned the added Hop Count block: %v", err)
+	} else if cb.BlockNumber != 2 {
+		t.Errorf("Added Hop Count block has the block number %d instead of 2", cb.BlockNumber)
+	}
+
+	if bndl.CanonicalBlocks[2].BlockType != PayloadBlock {
+		t.Errorf("Payload block is not the last block")
+	}
+
+	if err := bndl.checkValid(); err != nil {
+		t.Errorf("Bundle is invalid after adding an extension block: %v", err)
+	}
+}
+
+func TestBundleRemoveExtensionBlock(t *testing.T) {
+	var bndl, err = NewBundle(
+		NewPrimaryBlock(
+			MustNotFragmented,
+			MustNewEndpointID("dtn:some"), DtnNone(),
+			NewCreationTimestamp(DtnTimeEpoch, 0), 3600),
+		[]CanonicalBlock{
+			NewPreviousNodeBlock(1, 0, MustNewEndpointID("dtn:prev")),
+			NewBundleAgeBlock(2, 0, 420),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	bndl.RemoveExtensionBlock(PreviousNodeBlock)
+
+	if l := len(bndl.CanonicalBlocks); l != 2 {
+		t.Fatalf("Bundle has %d canonical blocks instead of 2", l)
+	}
+
+	if _, err := bndl.ExtensionBlock(PreviousNodeBlock); err == nil {
+		t.Errorf("Bundle still contains the removed Previous Node block")
+	}
+
+	if _, err := bndl.ExtensionBlock(BundleAgeBlock); err != nil {
+		t.Errorf("Bundle misses the Bundle Age block: %v", err)
+	}
+}
+
 // createNewBundle is used in the TestBundleCheckValid function and returns
 // the Bundle with an ignored error. The error will be checked in this
 // test case.
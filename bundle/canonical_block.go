@@ -44,6 +44,16 @@ const (
 	// HopCountBlock is a BlockType for a Hop Count block as defined in
 	// section 4.3.3.
 	HopCountBlock CanonicalBlockType = 9
+
+	// SprayAndWaitBlock is a BlockType for a Spray and Wait block, holding the
+	// number of copies a node is allowed to spread. This block type is taken
+	// from the range for private and/or experimental use.
+	SprayAndWaitBlock CanonicalBlockType = 193
+
+	// PriorityBlock is a BlockType for a Priority block, holding the bundle's
+	// class of service and an ordinal. This block type is taken from the range
+	// for private and/or experimental use.
+	PriorityBlock CanonicalBlockType = 194
 )
 
 // CanonicalBlock represents the canonical bundle block defined
@@ -137,9 +147,14 @@ func (cb *CanonicalBlock) codecDecodeData(data interface{}) {
 		setEndpointIDFromCborArray(ep, data.([]interface{}))
 		cb.Data = *ep
 
-	case BundleAgeBlock:
+	case BundleAgeBlock, SprayAndWaitBlock:
 		cb.Data = uint(data.(uint64))
 
+	case IntegrityBlock, ConfidentialityBlock:
+		var asb AbstractSecurityBlock
+		setAbstractSecurityBlockFromCborArray(&asb, data.([]interface{}))
+		cb.Data = asb
+
 	case HopCountBlock:
 		tuple := data.([]interface{})
 		cb.Data = HopCount{
@@ -147,8 +162,23 @@ func (cb *CanonicalBlock) codecDecodeData(data interface{}) {
 			Count: uint(tuple[1].(uint64)),
 		}
 
-	// blockTypePayload is also a byte array and can be treated like the default.
+	case PriorityBlock:
+		tuple := data.([]interface{})
+		cb.Data = Priority{
+			Class:   PriorityClass(tuple[0].(uint64)),
+			Ordinal: uint(tuple[1].(uint64)),
+		}
+
+	// blockTypePayload is also a byte array and can be treated like the default,
+	// unless its FilePayload is represented by the path and size.
 	default:
+		if tuple, ok := data.([]interface{}); ok && cb.BlockType == PayloadBlock && len(tuple) == 2 {
+			if path, ok := tuple[0].(string); ok {
+				cb.Data = FilePayload{Path: path, Size: tuple[1].(uint64)}
+				return
+			}
+		}
+
 		// In some cases codec was "too smart" and decoded the data by itself.
 		// This `if` checks if the decoded data is a byte array ([]uint8) or if
 		// we have to re-encode the data.
@@ -195,7 +225,16 @@ func (cb CanonicalBlock) checkValidExtensionBlocks() error {
 
 		return nil
 
-	case IntegrityBlock, ConfidentialityBlock, ManifestBlock, FlowLabelBlock:
+	case IntegrityBlock, ConfidentialityBlock:
+		asb, ok := cb.Data.(AbstractSecurityBlock)
+		if !ok {
+			return newBundleError(
+				"CanonicalBlock: Security block contains no AbstractSecurityBlock")
+		}
+
+		return asb.checkValid()
+
+	case ManifestBlock, FlowLabelBlock:
 		// These extension blocks are defined in other specifications
 		return nil
 
@@ -206,6 +245,20 @@ func (cb CanonicalBlock) checkValidExtensionBlocks() error {
 		// Nothing to check here
 		return nil
 
+	case SprayAndWaitBlock:
+		if copies, ok := cb.Data.(uint); !ok || copies == 0 {
+			return newBundleError("CanonicalBlock: Spray and Wait block has no copies")
+		}
+
+		return nil
+
+	case PriorityBlock:
+		if prio, ok := cb.Data.(Priority); !ok || !prio.Class.isValid() {
+			return newBundleError("CanonicalBlock: Priority block has an unknown class")
+		}
+
+		return nil
+
 	default:
 		// "Block type codes 192 through 255 are not reserved and are available for
 		// private and/or experimental use.", draft-ietf-dtn-bpbis-12#section-4.2.3
@@ -292,6 +345,12 @@ func NewPayloadBlock(blockControlFlags BlockControlFlags, data []byte) Canonical
 	return NewCanonicalBlock(PayloadBlock, 0, blockControlFlags, data)
 }
 
+// NewFilePayloadBlock creates a new payload block, whose data is read from the
+// FilePayload's file.
+func NewFilePayloadBlock(blockControlFlags BlockControlFlags, fp FilePayload) CanonicalBlock {
+	return NewCanonicalBlock(PayloadBlock, 0, blockControlFlags, fp)
+}
+
 // NewPreviousNodeBlock creates a new Previous Node block.
 func NewPreviousNodeBlock(blockNumber uint, blockControlFlags BlockControlFlags,
 	prevNodeId EndpointID) CanonicalBlock {
@@ -313,3 +372,19 @@ func NewHopCountBlock(blockNumber uint, blockControlFlags BlockControlFlags,
 	return NewCanonicalBlock(
 		HopCountBlock, blockNumber, blockControlFlags, hopCount)
 }
+
+// NewSprayAndWaitBlock creates a new Spray and Wait block, holding the number
+// of copies the receiving node is allowed to spread.
+func NewSprayAndWaitBlock(blockNumber uint, blockControlFlags BlockControlFlags,
+	copies uint) CanonicalBlock {
+	return NewCanonicalBlock(
+		SprayAndWaitBlock, blockNumber, blockControlFlags, copies)
+}
+
+// NewPriorityBlock creates a new Priority block, holding the bundle's class of
+// service and ordinal.
+func NewPriorityBlock(blockNumber uint, blockControlFlags BlockControlFlags,
+	priority Priority) CanonicalBlock {
+	return NewCanonicalBlock(
+		PriorityBlock, blockNumber, blockControlFlags, priority)
+}
@@ -43,6 +43,10 @@ func TestCanonicalBlockCbor(t *testing.T) {
 		{NewBundleAgeBlock(23, 0, 100000), 5},
 		// Hop Count block
 		{NewHopCountBlock(23, 0, NewHopCount(100)), 5},
+		// Spray and Wait block
+		{NewSprayAndWaitBlock(23, 0, 8), 5},
+		// Priority block
+		{NewPriorityBlock(23, 0, NewPriority(ExpeditedPriority, 42)), 5},
 	}
 
 	for _, test := range tests {
@@ -129,6 +133,14 @@ func TestCanonicalBlockCheckValid(t *testing.T) {
 			false},
 		{NewPreviousNodeBlock(23, 0, DtnNone()), true},
 
+		// Spray and Wait block without any copies
+		{NewSprayAndWaitBlock(23, 0, 0), false},
+		{NewSprayAndWaitBlock(23, 0, 1), true},
+
+		// Priority block with an unknown class
+		{NewPriorityBlock(23, 0, NewPriority(PriorityClass(3), 0)), false},
+		{NewPriorityBlock(23, 0, NewPriority(BulkPriority, 0)), true},
+
 		// Reserved block type
 		{CanonicalBlock{191, 0, 0, CRCNo, nil, nil}, false},
 		{CanonicalBlock{192, 0, 0, CRCNo, nil, nil}, true},
@@ -155,6 +167,8 @@ func TestExtensionBlockTypes(t *testing.T) {
 		{"Previous Node", NewPreviousNodeBlock(23, 0, DtnNone()), 7, reflect.Slice},
 		{"Bundle Age", NewBundleAgeBlock(23, 0, 42000), 8, reflect.Uint64},
 		{"Hop Count", NewHopCountBlock(23, 0, NewHopCount(42)), 9, reflect.Slice},
+		{"Spray and Wait", NewSprayAndWaitBlock(23, 0, 8), 193, reflect.Uint64},
+		{"Priority", NewPriorityBlock(23, 0, DefaultPriority()), 194, reflect.Slice},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,216 @@
+package bundle
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ugorji/go/codec"
+)
+
+// NewConfidentialityBlock creates a new Block Confidentiality Block (BCB) for
+// the given AbstractSecurityBlock. One might prefer the Bundle's
+// AddConfidentialityBlock method, which also encrypts the security target.
+func NewConfidentialityBlock(blockNumber uint, blockControlFlags BlockControlFlags,
+	asb AbstractSecurityBlock) CanonicalBlock {
+	return NewCanonicalBlock(ConfidentialityBlock, blockNumber, blockControlFlags, asb)
+}
+
+// confidentialityAdditionalData creates the additional authenticated data of a
+// security target for the given BCB. Like the IPPT of a BIB, this is a CBOR
+// array of the primary block, the targeted block's header and the BCB's
+// header.
+func (b *Bundle) confidentialityAdditionalData(target CanonicalBlock, bcb CanonicalBlock) ([]byte, error) {
+	var aad []byte
+	var err = codec.NewEncoderBytes(&aad, new(codec.CborHandle)).Encode(
+		[]interface{}{b.securityPrimaryBlock(), securityBlockHeader(target), securityBlockHeader(bcb)})
+
+	return aad, err
+}
+
+// isSecurityTarget returns true if the block number is a target of some
+// security block of the requested block type.
+func (b *Bundle) isSecurityTarget(blockType CanonicalBlockType, blockNumber uint) bool {
+	for _, cb := range b.CanonicalBlocks {
+		if asb, ok := cb.Data.(AbstractSecurityBlock); ok && cb.BlockType == blockType {
+			if asb.HasTarget(blockNumber) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddConfidentialityBlock encrypts the targeted canonical block's data and adds
+// a new Block Confidentiality Block (BCB) with the given block number to this
+// Bundle. The security source might be dtn:none to be omitted.
+//
+// Only blocks with a byte array as their data, e.g., the payload block, are
+// supported. Blocks which are processed by every node or which are already
+// protected by a BIB cannot be targeted. To protect an encrypted block's
+// integrity, a BIB might be added afterwards.
+func (b *Bundle) AddConfidentialityBlock(blockNumber uint, target uint,
+	source EndpointID, ctx ConfidentialityContext) error {
+	if _, err := b.canonicalBlockByNumber(blockNumber); err == nil {
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Block number %d is already in use", blockNumber))
+	}
+
+	targetBlock, err := b.canonicalBlockByNumber(target)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := blockData(*targetBlock)
+	if err != nil {
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Security target %d contains no byte array, %v", target, err))
+	}
+
+	switch {
+	case targetBlock.BlockType == IntegrityBlock || targetBlock.BlockType == ConfidentialityBlock:
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Security target %d is itself a security block", target))
+
+	case b.isSecurityTarget(IntegrityBlock, target):
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Security target %d is protected by a BIB", target))
+
+	case b.isSecurityTarget(ConfidentialityBlock, target):
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Security target %d is already encrypted", target))
+	}
+
+	var bcb = NewConfidentialityBlock(blockNumber, 0, AbstractSecurityBlock{})
+
+	aad, err := b.confidentialityAdditionalData(*targetBlock, bcb)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, parameters, results, err := ctx.Encrypt(plaintext, aad)
+	if err != nil {
+		return err
+	}
+
+	var asb = NewAbstractSecurityBlock([]uint{target}, ctx.ContextId(), source, parameters)
+	asb.SecurityResults = append(asb.SecurityResults, results)
+
+	bcb.Data = asb
+	targetBlock.Data = ciphertext
+	b.CanonicalBlocks = append(b.CanonicalBlocks, bcb)
+
+	return nil
+}
+
+// AddConfidentialityBlocks encrypts each targeted canonical block, as
+// AddConfidentialityBlock does, by a new BCB with the lowest unused block
+// number. Each target gets its own BCB because a security context's
+// parameters, e.g., an AES-GCM IV, must not be used for multiple targets. If
+// some target cannot be encrypted, this Bundle is not altered.
+func (b *Bundle) AddConfidentialityBlocks(targets []uint,
+	source EndpointID, ctx ConfidentialityContext) error {
+	var bndl = *b
+	bndl.CanonicalBlocks = append([]CanonicalBlock(nil), b.CanonicalBlocks...)
+
+	for _, target := range targets {
+		if err := bndl.AddConfidentialityBlock(bndl.unusedBlockNumber(), target, source, ctx); err != nil {
+			return err
+		}
+	}
+
+	b.CanonicalBlocks = bndl.CanonicalBlocks
+	return nil
+}
+
+// DecryptConfidentiality decrypts the security targets of each Block
+// Confidentiality Block with the given ConfidentialityContexts. A BCB is
+// removed after all its security targets were decrypted. A BCB whose security
+// context is unavailable cannot be decrypted and remains in this Bundle.
+// An error is returned for each failed decryption.
+func (b *Bundle) DecryptConfidentiality(contexts []ConfidentialityContext) (errs error) {
+	for i := len(b.CanonicalBlocks) - 1; i >= 0; i-- {
+		var bcb = b.CanonicalBlocks[i]
+		if bcb.BlockType != ConfidentialityBlock {
+			continue
+		}
+
+		asb, ok := bcb.Data.(AbstractSecurityBlock)
+		if !ok {
+			errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+				"Bundle: BCB %d contains no AbstractSecurityBlock", bcb.BlockNumber)))
+			continue
+		}
+
+		var candidates []ConfidentialityContext
+		for _, ctx := range contexts {
+			if ctx.ContextId() == asb.SecurityContextId {
+				candidates = append(candidates, ctx)
+			}
+		}
+
+		if len(candidates) == 0 {
+			continue
+		}
+
+		var plaintexts = make(map[*CanonicalBlock][]byte)
+		for j, target := range asb.SecurityTargets {
+			targetBlock, err := b.canonicalBlockByNumber(target)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+
+			ciphertext, dataErr := blockData(*targetBlock)
+			if dataErr != nil || j >= len(asb.SecurityResults) {
+				errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+					"Bundle: BCB %d's security target %d is malformed", bcb.BlockNumber, target)))
+				continue
+			}
+
+			aad, err := b.confidentialityAdditionalData(*targetBlock, bcb)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+
+			for _, ctx := range candidates {
+				plaintext, err := ctx.Decrypt(ciphertext, aad, asb.SecurityContextParameters, asb.SecurityResults[j])
+				if err == nil {
+					plaintexts[targetBlock] = plaintext
+					break
+				}
+			}
+
+			if _, ok := plaintexts[targetBlock]; !ok {
+				errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+					"Bundle: BCB %d failed to decrypt security target %d", bcb.BlockNumber, target)))
+			}
+		}
+
+		if len(plaintexts) != len(asb.SecurityTargets) {
+			continue
+		}
+
+		for targetBlock, plaintext := range plaintexts {
+			targetBlock.Data = plaintext
+			targetBlock.CalculateCRC()
+		}
+
+		b.CanonicalBlocks = append(b.CanonicalBlocks[:i], b.CanonicalBlocks[i+1:]...)
+	}
+
+	return
+}
+
+// IsEncrypted returns true if this Bundle contains a Block Confidentiality
+// Block, whose security targets are still encrypted.
+func (b Bundle) IsEncrypted() bool {
+	for _, cb := range b.CanonicalBlocks {
+		if cb.BlockType == ConfidentialityBlock {
+			return true
+		}
+	}
+
+	return false
+}
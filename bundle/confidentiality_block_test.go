@@ -0,0 +1,230 @@
+package bundle
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBundleConfidentiality(t *testing.T) {
+	for _, keyLen := range []int{16, 32} {
+		var key = bytes.Repeat([]byte{0x23}, keyLen)
+
+		ctx, err := NewAESGCMContext(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		otherCtx, _ := NewAESGCMContext(bytes.Repeat([]byte{0x42}, keyLen))
+
+		// The experimental block becomes block 1.
+		b, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 23), 42000),
+			[]CanonicalBlock{
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddExtensionBlock(NewCanonicalBlock(192, 0, 0, []byte("experimental")))
+		orig, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 23), 42000),
+			[]CanonicalBlock{
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig.AddExtensionBlock(NewCanonicalBlock(192, 0, 0, []byte("experimental")))
+
+		if err := b.AddConfidentialityBlock(42, 0, MustNewEndpointID("dtn:src"), ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.AddConfidentialityBlock(43, 1, DtnNone(), ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if !b.IsEncrypted() {
+			t.Fatal("Bundle is not encrypted")
+		}
+
+		for _, blockNumber := range []uint{0, 1} {
+			cb, _ := b.canonicalBlockByNumber(blockNumber)
+			origCb, _ := orig.canonicalBlockByNumber(blockNumber)
+
+			if bytes.Equal(cb.Data.([]byte), origCb.Data.([]byte)) {
+				t.Fatalf("Block %d was not encrypted", blockNumber)
+			}
+		}
+
+		b.SetCRCType(CRC32)
+		b.CalculateCRC()
+
+		b2, err := NewBundleFromCbor(b.ToCbor())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b2.DecryptConfidentiality(nil); err != nil || !b2.IsEncrypted() {
+			t.Fatalf("Decryption without any context changed the bundle: %v", err)
+		}
+
+		if err := b2.DecryptConfidentiality([]ConfidentialityContext{otherCtx}); err == nil {
+			t.Fatal("Decryption with another key did not fail")
+		}
+
+		if err := b2.DecryptConfidentiality([]ConfidentialityContext{otherCtx, ctx}); err != nil {
+			t.Fatal(err)
+		}
+
+		if b2.IsEncrypted() {
+			t.Fatal("Bundle is still encrypted")
+		}
+
+		if !b2.CheckCRC() {
+			t.Fatal("Decrypted bundle's CRC mismatches")
+		}
+
+		b2.SetCRCType(CRCNo)
+		b2.CalculateCRC()
+		if !reflect.DeepEqual(b2, orig) {
+			t.Fatalf("Decrypted bundle differs:\n%v\n%v", b2.CanonicalBlocks, orig.CanonicalBlocks)
+		}
+	}
+}
+
+func TestBundleConfidentialityTampered(t *testing.T) {
+	ctx, _ := NewAESGCMContext(bytes.Repeat([]byte{0x23}, 16))
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddConfidentialityBlock(42, 0, DtnNone(), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redirecting the bundle alters the additional authenticated data.
+	b.PrimaryBlock.Destination = MustNewEndpointID("dtn:evil")
+
+	if err := b.DecryptConfidentiality([]ConfidentialityContext{ctx}); err == nil {
+		t.Fatal("Decryption of a tampered bundle did not fail")
+	}
+}
+
+func TestBundleAddConfidentialityBlockErrors(t *testing.T) {
+	ctx, _ := NewAESGCMContext(bytes.Repeat([]byte{0x23}, 16))
+	integrityCtx, _ := NewHMACSHA2Context(HMAC256, []byte("secret"))
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddExtensionBlock(NewCanonicalBlock(192, 0, 0, []byte("experimental")))
+
+	if err := b.AddConfidentialityBlock(23, 0, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BCB with an used block number did not fail")
+	}
+
+	if err := b.AddConfidentialityBlock(42, 23, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BCB targeting a Hop Count block did not fail")
+	}
+
+	if err := b.AddIntegrityBlock(41, []uint{1}, DtnNone(), integrityCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddConfidentialityBlock(42, 1, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BCB targeting a BIB protected block did not fail")
+	}
+
+	if err := b.AddConfidentialityBlock(42, 0, DtnNone(), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddConfidentialityBlock(43, 0, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BCB targeting an encrypted block did not fail")
+	}
+
+	if _, err := NewAESGCMContext([]byte("short")); err == nil {
+		t.Fatal("Creating an AESGCMContext with an invalid key did not fail")
+	}
+}
+
+func TestBundleAddConfidentialityBlocks(t *testing.T) {
+	ctx, _ := NewAESGCMContext(bytes.Repeat([]byte{0x23}, 16))
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AddExtensionBlock(NewCanonicalBlock(192, 0, 0, []byte("experimental")))
+	orig, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig.AddExtensionBlock(NewCanonicalBlock(192, 0, 0, []byte("experimental")))
+
+	// A failing target leaves the bundle unaltered.
+	if err := b.AddConfidentialityBlocks([]uint{0, 23}, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding BCBs targeting a Hop Count block did not fail")
+	} else if !reflect.DeepEqual(b, orig) {
+		t.Fatalf("Failed BCBs altered the bundle:\n%v\n%v", b.CanonicalBlocks, orig.CanonicalBlocks)
+	}
+
+	if err := b.AddConfidentialityBlocks([]uint{0, 1}, DtnNone(), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, blockNumber := range []uint{2, 3} {
+		cb, err := b.canonicalBlockByNumber(blockNumber)
+		if err != nil {
+			t.Fatal(err)
+		} else if cb.BlockType != ConfidentialityBlock {
+			t.Fatalf("Block %d is no BCB: %v", blockNumber, cb)
+		}
+
+		var target = []uint{0, 1}[i]
+		if asb := cb.Data.(AbstractSecurityBlock); len(asb.SecurityTargets) != 1 || !asb.HasTarget(target) {
+			t.Fatalf("BCB %d does not target block %d: %v", blockNumber, target, asb)
+		}
+	}
+
+	if err := b.DecryptConfidentiality([]ConfidentialityContext{ctx}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(b, orig) {
+		t.Fatalf("Decrypted bundle differs:\n%v\n%v", b.CanonicalBlocks, orig.CanonicalBlocks)
+	}
+}
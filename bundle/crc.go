@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"hash/crc32"
+	"io/ioutil"
 
 	"github.com/howeyc/crc16"
-	"github.com/ugorji/go/codec"
 )
 
 // CRCType indicates which CRC type is used. Only the three defined consts
@@ -37,47 +37,81 @@ var (
 	crc32table = crc32.MakeTable(crc32.Castagnoli)
 )
 
-// blockToBytes encodes a Block to a byte array based on the CBOR encoding. It
-// temporary sets the present CRC value to zero. Therefore this function is not
-// thread safe.
-func blockToBytes(blck block) []byte {
-	var b []byte = make([]byte, 0, 64)
-	var enc *codec.Encoder = codec.NewEncoderBytes(&b, new(codec.CborHandle))
-
-	var blockCRC = blck.getCRC()
-
-	blck.resetCRC()
-	enc.MustEncode(blck)
-	blck.setCRC(blockCRC)
-
-	return b
-}
-
 // calculateCRC calculates a Block's CRC value based on its CRCType. The CRC
 // value will be set to zero temporary during calcuation. Thereforce this
 // function is not thread safe.
 // The returned value is a byte array containing the CRC in network byte order
 // (big endian) and its length is 4 for CRC32 or 2 for CRC16.
+//
+// A FilePayload is read from its file. If this fails, a zeroed CRC value is
+// returned, which will not match.
 func calculateCRC(blck block) []byte {
-	var data = blockToBytes(blck)
-	var arr = emptyCRC(blck.GetCRCType())
+	var blockCRC = blck.getCRC()
+	defer blck.setCRC(blockCRC)
+
+	crc, err := NewEncoder(ioutil.Discard).encodeBlock(blck)
+	if err != nil {
+		return emptyCRC(blck.GetCRCType())
+	}
 
-	switch blck.GetCRCType() {
+	return crc
+}
+
+// crcWriter calculates the CRC value of the CRCType over all written data.
+type crcWriter struct {
+	crcType CRCType
+	crc16   uint16
+	crc32   uint32
+}
+
+// newCRCWriter creates a new crcWriter for the CRCType.
+func newCRCWriter(crcType CRCType) *crcWriter {
+	return &crcWriter{crcType: crcType}
+}
+
+func (cw *crcWriter) Write(p []byte) (int, error) {
+	switch cw.crcType {
 	case CRCNo:
 
 	case CRC16:
-		binary.BigEndian.PutUint16(arr, crc16.Checksum(data, crc16table))
+		cw.crc16 = crc16.Update(cw.crc16, crc16table, p)
 
 	case CRC32:
-		binary.BigEndian.PutUint32(arr, crc32.Checksum(data, crc32table))
+		cw.crc32 = crc32.Update(cw.crc32, crc32table, p)
 
 	default:
 		panic("Unknown CRCType")
 	}
 
+	return len(p), nil
+}
+
+// Sum returns the CRC value of all written data in network byte order (big
+// endian).
+func (cw *crcWriter) Sum() []byte {
+	var arr = emptyCRC(cw.crcType)
+
+	switch cw.crcType {
+	case CRC16:
+		binary.BigEndian.PutUint16(arr, cw.crc16)
+
+	case CRC32:
+		binary.BigEndian.PutUint32(arr, cw.crc32)
+	}
+
 	return arr
 }
 
+// checksum calculates the CRC value of the CRCType for the data, a block's
+// CBOR encoding with a zeroed CRC value. The returned value is a byte array
+// containing the CRC in network byte order (big endian).
+func checksum(crcType CRCType, data []byte) []byte {
+	var cw = newCRCWriter(crcType)
+	cw.Write(data)
+
+	return cw.Sum()
+}
+
 // emptyCRC returns the "default" CRC value for the given CRC Type.
 func emptyCRC(crcType CRCType) (arr []byte) {
 	switch crcType {
@@ -97,6 +131,31 @@ func emptyCRC(crcType CRCType) (arr []byte) {
 	return
 }
 
+// checkRawCRC returns true if the CRC value at the end of a block's original
+// CBOR encoding matches the encoding. Thus, the block does not need to be
+// encoded again. The CRC value within raw will be zeroed.
+func checkRawCRC(crcType CRCType, raw []byte) bool {
+	var n = len(emptyCRC(crcType))
+	if n == 0 {
+		return true
+	}
+
+	// The CRC value is a definite-length byte string of n bytes.
+	var pos = len(raw) - n
+	if pos < 1 || raw[pos-1] != 0x40|byte(n) {
+		return false
+	}
+
+	var crc = make([]byte, n)
+	copy(crc, raw[pos:])
+
+	for i := pos; i < len(raw); i++ {
+		raw[i] = 0
+	}
+
+	return bytes.Equal(crc, checksum(crcType, raw))
+}
+
 // checkCRC returns true if the stored CRC value matches the calculated one or
 // the CRC Type is none.
 // This method changes the block's CRC value temporary and is not thread safe.
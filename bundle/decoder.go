@@ -0,0 +1,542 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	// cborMaxDepth limits the nesting of CBOR arrays, maps and tags within a
+	// block.
+	cborMaxDepth = 16
+
+	// cborMaxBlocks limits the length of a definite-length bundle array.
+	cborMaxBlocks = 1 << 16
+
+	cborMajorBytes = 2
+	cborMajorText  = 3
+	cborMajorArray = 4
+	cborMajorMap   = 5
+	cborMajorTag   = 6
+
+	cborIndefinite = 31
+)
+
+// DefaultMaxBlockSize is the default limit of a non-payload block's encoded
+// size in bytes, see SetMaxBlockSize.
+const DefaultMaxBlockSize = 1 << 20
+
+// maxBlockSize limits the encoded size of non-payload blocks, which are read
+// into memory by the Decoder. Zero disables this limit.
+var maxBlockSize = struct {
+	sync.RWMutex
+	size uint64
+}{size: DefaultMaxBlockSize}
+
+// SetMaxBlockSize configures the maximum encoded size in bytes of each
+// non-payload block, i.e., the primary block and all extension blocks, to be
+// decoded. A bundle containing a larger block results in a DecodeError. A size
+// of zero disables this limit. Payload blocks are not affected; large payloads
+// should be spooled, see SetPayloadSpool.
+func SetMaxBlockSize(size uint64) {
+	maxBlockSize.Lock()
+	defer maxBlockSize.Unlock()
+
+	maxBlockSize.size = size
+}
+
+// MaxBlockSize returns the maximum encoded size of non-payload blocks, see
+// SetMaxBlockSize.
+func MaxBlockSize() uint64 {
+	maxBlockSize.RLock()
+	defer maxBlockSize.RUnlock()
+
+	return maxBlockSize.size
+}
+
+// Decoder reads CBOR encoded bundles from an input stream. Successive bundles
+// are decoded one after another.
+//
+// Each block's CBOR data item is read once into a buffer and decoded from
+// there. The CRC values are checked against these original bytes. Thus, no
+// block is encoded again.
+//
+// A payload block's byte string reaching the threshold of SetPayloadSpool is
+// written into a new payload file and results in a FilePayload. Its CRC value
+// is calculated while reading. The caller owns this file afterwards, unless
+// Decode returns a DecodeError.
+//
+// Each other block must not exceed the size of SetMaxBlockSize.
+type Decoder struct {
+	r   *bufio.Reader
+	raw bytes.Buffer
+	dec *codec.Decoder
+
+	// limit is the maximum size of the current block's raw buffer; zero for
+	// no limit.
+	limit uint64
+}
+
+// NewDecoder creates a new Decoder reading from r. The Decoder buffers its
+// input and might read beyond a bundle's end.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:   bufio.NewReader(r),
+		dec: codec.NewDecoderBytes(nil, new(codec.CborHandle)),
+	}
+}
+
+// checkLimit returns an error if n more bytes would exceed the current block's
+// limit.
+func (d *Decoder) checkLimit(n uint64) error {
+	if d.limit > 0 && uint64(d.raw.Len())+n > d.limit {
+		return fmt.Errorf("block exceeds the maximum size of %d bytes", d.limit)
+	}
+
+	return nil
+}
+
+// readByte reads the next byte of the current block.
+func (d *Decoder) readByte() (byte, error) {
+	if err := d.checkLimit(1); err != nil {
+		return 0, err
+	}
+
+	b, err := d.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		d.raw.WriteByte(b)
+	}
+
+	return b, err
+}
+
+// readArgument reads the argument of a CBOR data item, following its initial
+// byte, as defined in RFC 7049, section 2.
+func (d *Decoder) readArgument(info byte) (uint64, error) {
+	var n int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, fmt.Errorf("reserved additional information %d", info)
+	}
+
+	var arg [8]byte
+	for i := 8 - n; i < 8; i++ {
+		b, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		arg[i] = b
+	}
+
+	return binary.BigEndian.Uint64(arg[:]), nil
+}
+
+// readItem reads a complete CBOR data item into the raw buffer.
+func (d *Decoder) readItem(depth int) error {
+	if depth > cborMaxDepth {
+		return fmt.Errorf("nesting exceeds %d levels", cborMaxDepth)
+	}
+
+	initial, err := d.readByte()
+	if err != nil {
+		return err
+	}
+
+	var major, info = initial >> 5, initial & 0x1f
+
+	if info == cborIndefinite {
+		switch major {
+		case cborMajorBytes, cborMajorText, cborMajorArray, cborMajorMap:
+			return d.readIndefinite(depth)
+		default:
+			return fmt.Errorf("unexpected indefinite length or break")
+		}
+	}
+
+	arg, err := d.readArgument(info)
+	if err != nil {
+		return err
+	}
+
+	switch major {
+	case cborMajorBytes, cborMajorText:
+		if err := d.checkLimit(arg); err != nil {
+			return err
+		}
+
+		if n, err := io.CopyN(&d.raw, d.r, int64(arg)); err == io.EOF || (err == nil && uint64(n) != arg) {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+
+	case cborMajorArray, cborMajorMap:
+		var items = arg
+		if major == cborMajorMap {
+			items *= 2
+		}
+
+		for i := uint64(0); i < items; i++ {
+			if err := d.readItem(depth + 1); err != nil {
+				return err
+			}
+		}
+
+	case cborMajorTag:
+		return d.readItem(depth + 1)
+	}
+
+	return nil
+}
+
+// readIndefinite reads the items of an indefinite-length data item up to and
+// including its break code.
+func (d *Decoder) readIndefinite(depth int) error {
+	for {
+		next, err := d.r.Peek(1)
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+
+		if next[0] == codec.CborStreamBreak {
+			_, err := d.readByte()
+			return err
+		}
+
+		if err := d.readItem(depth + 1); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeBlock decodes the block from the raw buffer.
+func (d *Decoder) decodeBlock(blck block) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	d.dec.ResetBytes(d.raw.Bytes())
+	if err = d.dec.Decode(blck); err != nil {
+		return
+	}
+
+	// Only the stores keep a FilePayload's path within a CanonicalBlock's CBOR
+	// representation. A received bundle must not reference local files.
+	if cb, ok := blck.(*CanonicalBlock); ok {
+		if _, ok := cb.Data.(FilePayload); ok {
+			err = fmt.Errorf("block %d's data is no byte string", cb.BlockNumber)
+		}
+	}
+
+	return
+}
+
+// readUint reads a CBOR unsigned integer into the raw buffer.
+func (d *Decoder) readUint() (uint64, error) {
+	initial, err := d.readByte()
+	if err != nil {
+		return 0, err
+	} else if initial>>5 != 0 {
+		return 0, fmt.Errorf("unexpected initial byte 0x%02x for an unsigned integer", initial)
+	}
+
+	return d.readArgument(initial & 0x1f)
+}
+
+// readCanonicalBlock reads a canonical block's CBOR data item into the raw
+// buffer and decodes it. A payload block's data reaching the spool threshold
+// is written into a payload file instead. The returned bool indicates a
+// matching CRC value.
+func (d *Decoder) readCanonicalBlock(cb *CanonicalBlock) (bool, error) {
+	next, err := d.r.Peek(1)
+	if err == io.EOF {
+		return false, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return false, err
+	}
+
+	// Only definite-length arrays of five or six items might be spooled.
+	var initial = next[0]
+	if initial != cborMajorArray<<5|5 && initial != cborMajorArray<<5|6 {
+		if err := d.readItem(0); err != nil {
+			return false, err
+		}
+		return d.decodeCanonicalBlock(cb)
+	}
+
+	d.readByte()
+
+	var fields [4]uint64
+	for i := range fields {
+		if fields[i], err = d.readUint(); err != nil {
+			return false, err
+		}
+	}
+
+	if next, err = d.r.Peek(1); err == io.EOF {
+		return false, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return false, err
+	}
+
+	// Only the payload block's data might exceed the maximum block size.
+	if CanonicalBlockType(fields[0]) == PayloadBlock {
+		d.limit = 0
+	}
+
+	if CanonicalBlockType(fields[0]) != PayloadBlock ||
+		next[0]>>5 != cborMajorBytes || next[0]&0x1f == cborIndefinite {
+		if err := d.readItem(1); err != nil {
+			return false, err
+		}
+		return d.readCanonicalBlockEnd(cb, initial)
+	}
+
+	byteHead, _ := d.readByte()
+	size, err := d.readArgument(byteHead & 0x1f)
+	if err != nil {
+		return false, err
+	}
+
+	if !spoolPayload(size) {
+		if n, err := io.CopyN(&d.raw, d.r, int64(size)); err == io.EOF || (err == nil && uint64(n) != size) {
+			return false, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return false, err
+		}
+		return d.readCanonicalBlockEnd(cb, initial)
+	}
+
+	*cb = CanonicalBlock{
+		BlockType:         CanonicalBlockType(fields[0]),
+		BlockNumber:       uint(fields[1]),
+		BlockControlFlags: BlockControlFlags(fields[2]),
+		CRCType:           CRCType(fields[3]),
+	}
+	return d.spoolPayloadBlock(cb, initial, size)
+}
+
+// readCanonicalBlockEnd reads the remaining CRC value of a canonical block, if
+// indicated by its initial byte, and decodes the block.
+func (d *Decoder) readCanonicalBlockEnd(cb *CanonicalBlock, initial byte) (bool, error) {
+	if initial&0x1f == 6 {
+		if err := d.readItem(1); err != nil {
+			return false, err
+		}
+	}
+
+	return d.decodeCanonicalBlock(cb)
+}
+
+// decodeCanonicalBlock decodes the canonical block from the raw buffer and
+// checks its CRC value.
+func (d *Decoder) decodeCanonicalBlock(cb *CanonicalBlock) (bool, error) {
+	if err := d.decodeBlock(cb); err != nil {
+		return false, err
+	}
+
+	return !cb.HasCRC() || checkRawCRC(cb.GetCRCType(), d.raw.Bytes()), nil
+}
+
+// spoolPayloadBlock writes the payload's byte string of the given size into a
+// new payload file, sets it as the block's FilePayload and reads the block's
+// CRC value. The raw buffer holds the block's encoding up to the byte string's
+// head.
+func (d *Decoder) spoolPayloadBlock(cb *CanonicalBlock, initial byte, size uint64) (crcOk bool, err error) {
+	if (initial&0x1f == 6) != cb.HasCRC() {
+		return false, fmt.Errorf("block %d's length mismatches its CRC type", cb.BlockNumber)
+	} else if cb.CRCType > CRC32 {
+		return false, fmt.Errorf("block %d has an unknown CRC type", cb.BlockNumber)
+	}
+
+	f, err := NewPayloadFile()
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	var crcW = newCRCWriter(cb.CRCType)
+	crcW.Write(d.raw.Bytes())
+
+	if n, copyErr := io.CopyN(io.MultiWriter(f, crcW), d.r, int64(size)); copyErr == io.EOF || (copyErr == nil && uint64(n) != size) {
+		return false, io.ErrUnexpectedEOF
+	} else if copyErr != nil {
+		return false, copyErr
+	}
+
+	cb.Data = FilePayload{Path: f.Name(), Size: size}
+
+	if !cb.HasCRC() {
+		return true, nil
+	}
+
+	d.raw.Reset()
+	if err = d.readItem(1); err != nil {
+		return false, err
+	}
+
+	var raw = d.raw.Bytes()
+	var crcLen = len(emptyCRC(cb.CRCType))
+	if len(raw) != 1+crcLen || raw[0] != cborMajorBytes<<5|byte(crcLen) {
+		return false, fmt.Errorf("block %d's CRC value is malformed", cb.BlockNumber)
+	}
+
+	cb.CRC = append([]byte{}, raw[1:]...)
+
+	crcW.Write(raw[:1])
+	crcW.Write(emptyCRC(cb.CRCType))
+
+	return bytes.Equal(cb.CRC, crcW.Sum()), nil
+}
+
+// atBreak checks if the next byte is a break code, which will be consumed.
+func (d *Decoder) atBreak() (bool, error) {
+	next, err := d.r.Peek(1)
+	if err == io.EOF {
+		return false, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return false, err
+	}
+
+	if next[0] != codec.CborStreamBreak {
+		return false, nil
+	}
+
+	_, err = d.r.ReadByte()
+	return true, err
+}
+
+// Decode reads the next bundle from the stream into b. At the stream's end,
+// io.EOF is returned.
+//
+// Malformed CBOR results in a DecodeError. For a CRCError or an
+// InvalidBundleError, b holds the decoded bundle and the next bundle might be
+// decoded.
+func (d *Decoder) Decode(b *Bundle) (err error) {
+	initial, err := d.r.ReadByte()
+	if err == io.EOF {
+		return io.EOF
+	} else if err != nil {
+		return newDecodeError("reading bundle failed", err)
+	}
+
+	// A bundle should be an indefinite-length array, but a definite-length
+	// array is accepted as well.
+	var blocks = -1
+	switch {
+	case initial == codec.CborStreamArray:
+
+	case initial>>5 == cborMajorArray:
+		d.raw.Reset()
+		d.limit = 0
+		arg, err := d.readArgument(initial & 0x1f)
+		if err != nil {
+			return newDecodeError("reading bundle's length failed", err)
+		}
+		if arg > cborMaxBlocks {
+			return newDecodeError(fmt.Sprintf("bundle exceeds %d blocks", cborMaxBlocks), nil)
+		}
+		blocks = int(arg)
+
+	default:
+		return newDecodeError(fmt.Sprintf("bundle is no CBOR array, initial byte 0x%02x", initial), nil)
+	}
+
+	var pb PrimaryBlock
+	var cbs []CanonicalBlock
+	var crcErr error
+	var limit = MaxBlockSize()
+
+	defer func() {
+		if _, ok := err.(*DecodeError); ok {
+			for _, cb := range cbs {
+				if fp, ok := cb.Data.(FilePayload); ok {
+					os.Remove(fp.Path)
+				}
+			}
+		}
+	}()
+
+	var n int
+	for ; blocks < 0 || n < blocks; n++ {
+		if blocks < 0 {
+			if end, err := d.atBreak(); err != nil {
+				return newDecodeError("reading bundle failed", err)
+			} else if end {
+				break
+			}
+		}
+
+		d.raw.Reset()
+		d.limit = limit
+
+		var crcOk bool
+		if n == 0 {
+			if err := d.readItem(0); err != nil {
+				return newDecodeError("reading block 0 failed", err)
+			}
+			if err := d.decodeBlock(&pb); err != nil {
+				return newDecodeError("decoding block 0 failed", err)
+			}
+			crcOk = !pb.HasCRC() || checkRawCRC(pb.GetCRCType(), d.raw.Bytes())
+		} else {
+			cbs = append(cbs, CanonicalBlock{})
+
+			var err error
+			if crcOk, err = d.readCanonicalBlock(&cbs[len(cbs)-1]); err != nil {
+				return newDecodeError(fmt.Sprintf("reading block %d failed", n), err)
+			}
+		}
+
+		if crcErr == nil && !crcOk {
+			crcErr = &CRCError{Index: n}
+		}
+	}
+
+	if n == 0 {
+		return newDecodeError("bundle has no primary block", nil)
+	}
+
+	*b = Bundle{PrimaryBlock: pb, CanonicalBlocks: cbs}
+
+	if crcErr != nil {
+		return crcErr
+	}
+
+	if err := b.checkValid(); err != nil {
+		return &InvalidBundleError{Err: err}
+	}
+
+	return nil
+}
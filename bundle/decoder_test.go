@@ -0,0 +1,228 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecoderStream(t *testing.T) {
+	var buf bytes.Buffer
+	var enc = NewEncoder(&buf)
+
+	var bndls []Bundle
+	for i := uint(0); i < 5; i++ {
+		bndl, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, i), 42000),
+			[]CanonicalBlock{
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bndl.SetCRCType(CRCType(i % 3))
+		bndl.AddExtensionBlock(NewPriorityBlock(0, 0, NewPriority(ExpeditedPriority, i)))
+
+		if err := enc.Encode(bndl); err != nil {
+			t.Fatal(err)
+		}
+
+		bndls = append(bndls, bndl)
+	}
+
+	var dec = NewDecoder(&buf)
+	for i, bndl := range bndls {
+		var decBndl Bundle
+		if err := dec.Decode(&decBndl); err != nil {
+			t.Fatalf("Decoding bundle %d failed: %v", i, err)
+		}
+
+		if decBndl.ID() != bndl.ID() {
+			t.Fatalf("Decoded bundle %d has ID %s instead of %s", i, decBndl.ID(), bndl.ID())
+		}
+
+		if prio := decBndl.Priority(); prio != bndl.Priority() {
+			t.Fatalf("Decoded bundle %d has priority %v instead of %v", i, prio, bndl.Priority())
+		}
+	}
+
+	var decBndl Bundle
+	if err := dec.Decode(&decBndl); err != io.EOF {
+		t.Fatalf("Decoding at the stream's end resulted in %v instead of io.EOF", err)
+	}
+}
+
+func TestDecoderCRCError(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC32)
+
+	var data = bndl.ToCbor()
+	var payloadPos = bytes.Index(data, []byte("hello world"))
+	data[payloadPos] = 'j'
+
+	// Another valid bundle follows the corrupted one.
+	bndl2, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 1), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl2.SetCRCType(CRC16)
+	data = append(data, bndl2.ToCbor()...)
+
+	var dec = NewDecoder(bytes.NewReader(data))
+
+	var decBndl Bundle
+	var crcErr *CRCError
+	if err := dec.Decode(&decBndl); !errors.As(err, &crcErr) {
+		t.Fatalf("Decoding a corrupted bundle resulted in %v instead of a CRCError", err)
+	} else if crcErr.Index != len(bndl.CanonicalBlocks) {
+		t.Fatalf("CRCError names block %d instead of %d", crcErr.Index, len(bndl.CanonicalBlocks))
+	}
+
+	if err := dec.Decode(&decBndl); err != nil {
+		t.Fatalf("Decoding the bundle after a CRCError failed: %v", err)
+	}
+}
+
+func TestDecoderInvalidBundle(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.CanonicalBlocks = append(bndl.CanonicalBlocks,
+		NewHopCountBlock(2, 0, NewHopCount(16)))
+
+	var invalidErr *InvalidBundleError
+	if _, err := NewBundleFromCbor(bndl.ToCbor()); !errors.As(err, &invalidErr) {
+		t.Fatalf("Decoding an invalid bundle resulted in %v instead of an InvalidBundleError", err)
+	}
+}
+
+func TestDecoderDecodeError(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC16)
+
+	var data = bndl.ToCbor()
+
+	tests := []struct {
+		name       string
+		data       []byte
+		unexpected bool
+	}{
+		{"truncated", data[:len(data)/2], true},
+		{"missing break", data[:len(data)-1], true},
+		{"no array", []byte{0x42, 0x00, 0x00}, false},
+		{"empty array", []byte{0x9f, 0xff}, false},
+		{"reserved information", []byte{0x9f, 0x1c}, false},
+		{"deep nesting", append([]byte{0x9f}, bytes.Repeat([]byte{0x81}, 64)...), false},
+	}
+
+	for _, test := range tests {
+		var decBndl Bundle
+		var decErr *DecodeError
+
+		err := NewDecoder(bytes.NewReader(test.data)).Decode(&decBndl)
+		if !errors.As(err, &decErr) {
+			t.Fatalf("Decoding %s data resulted in %v instead of a DecodeError", test.name, err)
+		}
+
+		if unexpected := errors.Is(err, io.ErrUnexpectedEOF); unexpected != test.unexpected {
+			t.Fatalf("Decoding %s data resulted in %v, unexpected EOF is %t", test.name, err, unexpected)
+		}
+	}
+}
+
+func TestDecoderDefiniteArray(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC32)
+
+	var data = bndl.ToCbor()
+	data[0] = 0x80 | byte(1+len(bndl.CanonicalBlocks))
+	data = data[:len(data)-1]
+
+	decBndl, err := NewBundleFromCbor(data)
+	if err != nil {
+		t.Fatalf("Decoding a definite-length array failed: %v", err)
+	}
+
+	if decBndl.ID() != bndl.ID() {
+		t.Fatalf("Decoded bundle has ID %s instead of %s", decBndl.ID(), bndl.ID())
+	}
+}
+
+func TestDecoderMaxBlockSize(t *testing.T) {
+	defer SetMaxBlockSize(DefaultMaxBlockSize)
+
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewCanonicalBlock(192, 2, 0, bytes.Repeat([]byte{0x23}, 512)),
+			NewPayloadBlock(0, bytes.Repeat([]byte{0x42}, 4096)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data = bndl.ToCbor()
+
+	SetMaxBlockSize(1024)
+	if _, err := NewBundleFromCbor(data); err != nil {
+		t.Fatalf("Decoding a bundle with a larger payload block failed: %v", err)
+	}
+
+	SetMaxBlockSize(256)
+	var decErr *DecodeError
+	if _, err := NewBundleFromCbor(data); !errors.As(err, &decErr) {
+		t.Fatalf("Decoding a bundle with a too large block resulted in %v instead of a DecodeError", err)
+	}
+
+	SetMaxBlockSize(0)
+	if _, err := NewBundleFromCbor(data); err != nil {
+		t.Fatalf("Decoding a bundle without a maximum block size failed: %v", err)
+	}
+}
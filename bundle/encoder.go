@@ -0,0 +1,219 @@
+package bundle
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/ugorji/go/codec"
+)
+
+// Encoder writes CBOR encoded bundles to an output stream. Each bundle is an
+// indefinite-length array of its blocks, as defined in section 4 of the Bundle
+// Protocol Version 7.
+//
+// The CRC value of each block with a CRCType is calculated while encoding.
+// Thus, the blocks are encoded only once and their present CRC values are
+// ignored. A FilePayload is streamed from its file.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+	enc *codec.Encoder
+
+	// counter is set for EncodedSize. Each FilePayload's size is only added
+	// to the counter, its file is not read.
+	counter *countingWriter
+}
+
+// NewEncoder creates a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	var e = &Encoder{
+		w:   w,
+		buf: make([]byte, 0, 256),
+	}
+	e.enc = codec.NewEncoderBytes(&e.buf, new(codec.CborHandle))
+
+	return e
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter uint64
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	*cw += countingWriter(len(p))
+	return len(p), nil
+}
+
+// cborHead creates the head of a CBOR data item of the major type with the
+// argument, as defined in RFC 7049, section 2.
+func cborHead(major byte, arg uint64) []byte {
+	var initial = major << 5
+
+	switch {
+	case arg < 24:
+		return []byte{initial | byte(arg)}
+
+	case arg <= math.MaxUint8:
+		return []byte{initial | 24, byte(arg)}
+
+	case arg <= math.MaxUint16:
+		var head = []byte{initial | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		return head
+
+	case arg <= math.MaxUint32:
+		var head = []byte{initial | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		return head
+
+	default:
+		var head = make([]byte, 9)
+		head[0] = initial | 27
+		binary.BigEndian.PutUint64(head[1:], arg)
+		return head
+	}
+}
+
+// encodeBlock writes a single block and returns its calculated CRC value. The
+// block is encoded with a zeroed CRC value into the buffer and the calculated
+// CRC value is set afterwards. The block must be a copy because its CRC value
+// is altered.
+func (e *Encoder) encodeBlock(blck block) (crc []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Bundle: Encoding CBOR failed, %v", r)
+		}
+	}()
+
+	blck.resetCRC()
+
+	if cb, ok := blck.(*CanonicalBlock); ok {
+		if fp, ok := cb.Data.(FilePayload); ok {
+			return e.encodeFilePayload(cb, fp)
+		}
+	}
+
+	e.buf = e.buf[:0]
+	e.enc.ResetBytes(&e.buf)
+
+	if err = e.enc.Encode(blck); err != nil {
+		return
+	}
+
+	if blck.HasCRC() {
+		crc = checksum(blck.GetCRCType(), e.buf)
+		copy(e.buf[len(e.buf)-len(crc):], crc)
+	}
+
+	_, err = e.w.Write(e.buf)
+	return
+}
+
+// encodeFilePayload writes a canonical block whose data is a FilePayload. The
+// block's fields are encoded into the buffer and written, followed by the
+// streamed file as a definite-length byte string. The CRC value is calculated on the fly.
+func (e *Encoder) encodeFilePayload(cb *CanonicalBlock, fp FilePayload) ([]byte, error) {
+	var fields = uint64(5)
+	if cb.HasCRC() {
+		fields = 6
+	}
+
+	e.buf = e.buf[:0]
+	e.enc.ResetBytes(&e.buf)
+
+	for _, field := range []uint64{
+		uint64(cb.BlockType), uint64(cb.BlockNumber), uint64(cb.BlockControlFlags), uint64(cb.CRCType)} {
+		if err := e.enc.Encode(field); err != nil {
+			return nil, err
+		}
+	}
+
+	var crcW = newCRCWriter(cb.CRCType)
+	var w = io.MultiWriter(e.w, crcW)
+
+	for _, part := range [][]byte{
+		cborHead(cborMajorArray, fields), e.buf, cborHead(cborMajorBytes, fp.Size)} {
+		if _, err := w.Write(part); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.counter != nil {
+		*e.counter += countingWriter(fp.Size)
+	} else {
+		f, err := fp.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if n, err := io.CopyN(w, f, int64(fp.Size)); err == io.EOF {
+			return nil, fmt.Errorf("Bundle: Payload file %s ended after %d bytes", fp.Path, n)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if !cb.HasCRC() {
+		return nil, nil
+	}
+
+	var crcHead = cborHead(cborMajorBytes, uint64(len(emptyCRC(cb.CRCType))))
+	crcW.Write(crcHead)
+	crcW.Write(emptyCRC(cb.CRCType))
+
+	var crc = crcW.Sum()
+	if _, err := e.w.Write(append(crcHead, crc...)); err != nil {
+		return nil, err
+	}
+
+	return crc, nil
+}
+
+// Encode writes the CBOR encoding of the bundle to the stream.
+func (e *Encoder) Encode(b Bundle) error {
+	if _, err := e.w.Write([]byte{codec.CborStreamArray}); err != nil {
+		return err
+	}
+
+	var pb = b.PrimaryBlock
+	if _, err := e.encodeBlock(&pb); err != nil {
+		return err
+	}
+
+	for _, cb := range b.CanonicalBlocks {
+		if _, err := e.encodeBlock(&cb); err != nil {
+			return err
+		}
+	}
+
+	_, err := e.w.Write([]byte{codec.CborStreamBreak})
+	return err
+}
+
+// EncodeByteString writes the bundle's CBOR encoding as a definite-length CBOR
+// byte string. This suits protocols which embed a serialized bundle, e.g., a
+// convergence layer, without holding its encoding in memory.
+func (e *Encoder) EncodeByteString(b Bundle) error {
+	if _, err := e.w.Write(cborHead(cborMajorBytes, b.EncodedSize())); err != nil {
+		return err
+	}
+
+	return e.Encode(b)
+}
+
+// EncodedSize returns the length of this Bundle's CBOR encoding, as created by
+// the Encoder. A FilePayload's file is not read.
+func (b Bundle) EncodedSize() uint64 {
+	var cw countingWriter
+
+	var e = NewEncoder(&cw)
+	e.counter = &cw
+
+	if err := e.Encode(b); err != nil {
+		panic(err)
+	}
+
+	return uint64(cw)
+}
@@ -0,0 +1,75 @@
+package bundle
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncoderCRC(t *testing.T) {
+	for _, crcType := range []CRCType{CRCNo, CRC16, CRC32} {
+		bndl, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 0), 42000),
+			[]CanonicalBlock{
+				NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bndl.SetCRCType(crcType)
+
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(bndl); err != nil {
+			t.Fatalf("Encoding with %v failed: %v", crcType, err)
+		}
+
+		bndl2, err := NewBundleFromCbor(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Decoding with %v failed: %v", crcType, err)
+		}
+
+		// The Encoder's CRC values must equal the separately calculated ones.
+		bndl.CalculateCRC()
+
+		if !bytes.Equal(bndl.PrimaryBlock.CRC, bndl2.PrimaryBlock.CRC) {
+			t.Fatalf("Primary block's CRC with %v differs: %x instead of %x",
+				crcType, bndl2.PrimaryBlock.CRC, bndl.PrimaryBlock.CRC)
+		}
+
+		for i := range bndl.CanonicalBlocks {
+			if crc1, crc2 := bndl.CanonicalBlocks[i].CRC, bndl2.CanonicalBlocks[i].CRC; !bytes.Equal(crc1, crc2) {
+				t.Fatalf("Block %d's CRC with %v differs: %x instead of %x", i, crcType, crc2, crc1)
+			}
+		}
+
+		if !bndl2.CheckCRC() {
+			t.Fatalf("Decoded bundle's CRC with %v mismatches", crcType)
+		}
+	}
+}
+
+func TestEncoderUnchangedBundle(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC32)
+
+	if err := NewEncoder(&bytes.Buffer{}).Encode(bndl); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, cb := range bndl.CanonicalBlocks {
+		if cb.CRC != nil {
+			t.Fatalf("Encoder altered the CRC value of block %d: %x", i, cb.CRC)
+		}
+	}
+}
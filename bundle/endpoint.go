@@ -24,11 +24,19 @@ type EndpointID struct {
 	SchemeSpecificPart interface{}
 }
 
+// dtnURIRegex matches a "dtn" SSP of the "//node/demux" form, containing a
+// node name and an optional demultiplexing part.
+var dtnURIRegex = regexp.MustCompile(`^//([^/]+)(?:/(.*))?$`)
+
 func newEndpointIDDTN(ssp string) (EndpointID, error) {
 	var sspRaw interface{}
 	if ssp == "none" {
 		sspRaw = uint(0)
 	} else {
+		if strings.HasPrefix(ssp, "//") && !dtnURIRegex.MatchString(ssp) {
+			return EndpointID{}, newBundleError("DTN URI has no node name")
+		}
+
 		sspRaw = string(ssp)
 	}
 
@@ -222,6 +230,81 @@ func (eid EndpointID) String() string {
 	return b.String()
 }
 
+// dtnURIParts returns the node name and the demultiplexing part of a "dtn"
+// endpoint ID of the "dtn://node/demux" form. The last return value is false
+// for any other endpoint ID.
+func (eid EndpointID) dtnURIParts() (node, demux string, ok bool) {
+	if eid.SchemeName != endpointURISchemeDTN {
+		return
+	}
+
+	ssp, isStr := eid.SchemeSpecificPart.(string)
+	if !isStr {
+		return
+	}
+
+	matches := dtnURIRegex.FindStringSubmatch(ssp)
+	if matches == nil {
+		return
+	}
+
+	return matches[1], matches[2], true
+}
+
+// NodeID returns the endpoint ID of the node this endpoint ID belongs to.
+//
+// For "dtn://node/demux", this is "dtn://node/". For "ipn:node.service", this
+// is "ipn:node.0". Every other endpoint ID, e.g., "dtn:foo" or "dtn:none", is
+// treated as its own node ID.
+func (eid EndpointID) NodeID() EndpointID {
+	if node, _, ok := eid.dtnURIParts(); ok {
+		return EndpointID{
+			SchemeName:         endpointURISchemeDTN,
+			SchemeSpecificPart: "//" + node + "/",
+		}
+	}
+
+	if ssp, ok := eid.SchemeSpecificPart.([2]uint64); ok && eid.SchemeName == endpointURISchemeIPN {
+		return EndpointID{
+			SchemeName:         endpointURISchemeIPN,
+			SchemeSpecificPart: [2]uint64{ssp[0], 0},
+		}
+	}
+
+	return eid
+}
+
+// Demux returns the demultiplexing part of this endpoint ID, which addresses a
+// service on its node.
+//
+// For "dtn://node/demux", this is "demux". For "ipn:node.service", this is the
+// service number. Every other endpoint ID has an empty demultiplexing part.
+func (eid EndpointID) Demux() string {
+	if _, demux, ok := eid.dtnURIParts(); ok {
+		return demux
+	}
+
+	if ssp, ok := eid.SchemeSpecificPart.([2]uint64); ok && eid.SchemeName == endpointURISchemeIPN {
+		return strconv.FormatUint(ssp[1], 10)
+	}
+
+	return ""
+}
+
+// SameNode returns true if both endpoint IDs belong to the same node.
+func (eid EndpointID) SameNode(other EndpointID) bool {
+	return eid.NodeID() == other.NodeID()
+}
+
+// IsSingleton returns false for a non-singleton endpoint ID, e.g., a group
+// endpoint. A "dtn" endpoint ID is a non-singleton one if its demultiplexing
+// part starts with a "~", e.g., "dtn://chat/~members". All other endpoint IDs
+// are singletons.
+func (eid EndpointID) IsSingleton() bool {
+	_, demux, ok := eid.dtnURIParts()
+	return !ok || !strings.HasPrefix(demux, "~")
+}
+
 // DtnNone returns the "null endpoint", "dtn:none".
 func DtnNone() EndpointID {
 	return EndpointID{
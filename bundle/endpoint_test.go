@@ -229,3 +229,71 @@ func TestEndpointCheckValid(t *testing.T) {
 		}
 	}
 }
+
+func TestEndpointDtnURI(t *testing.T) {
+	tests := []struct {
+		eid   string
+		node  string
+		demux string
+	}{
+		{"dtn://alpha/", "dtn://alpha/", ""},
+		{"dtn://alpha", "dtn://alpha/", ""},
+		{"dtn://alpha/mail", "dtn://alpha/", "mail"},
+		{"dtn://alpha/mail/inbox", "dtn://alpha/", "mail/inbox"},
+		{"dtn:foobar", "dtn:foobar", ""},
+		{"dtn:none", "dtn:none", ""},
+		{"ipn:23.42", "ipn:23.0", "42"},
+	}
+
+	for _, test := range tests {
+		eid, err := NewEndpointID(test.eid)
+		if err != nil {
+			t.Fatalf("%s resulted in an error: %v", test.eid, err)
+		}
+
+		if str := eid.String(); str != test.eid {
+			t.Fatalf("%s's string representation is %s", test.eid, str)
+		}
+
+		if node := eid.NodeID().String(); node != test.node {
+			t.Fatalf("%s's node ID is %s instead of %s", test.eid, node, test.node)
+		}
+
+		if demux := eid.Demux(); demux != test.demux {
+			t.Fatalf("%s's demux is %s instead of %s", test.eid, demux, test.demux)
+		}
+
+		if !eid.SameNode(eid.NodeID()) {
+			t.Fatalf("%s is not on the same node as its node ID", test.eid)
+		}
+	}
+
+	if MustNewEndpointID("dtn://alpha/mail").SameNode(MustNewEndpointID("dtn://beta/mail")) {
+		t.Fatalf("dtn://alpha/mail and dtn://beta/mail are on the same node")
+	}
+
+	if _, err := NewEndpointID("dtn:///mail"); err == nil {
+		t.Fatalf("DTN URI without a node name resulted in no error")
+	}
+}
+
+func TestEndpointIsSingleton(t *testing.T) {
+	tests := []struct {
+		eid       string
+		singleton bool
+	}{
+		{"dtn://chat/~members", false},
+		{"dtn://chat/~", false},
+		{"dtn://chat/members", true},
+		{"dtn://chat/", true},
+		{"dtn:~foo", true},
+		{"dtn:none", true},
+		{"ipn:23.42", true},
+	}
+
+	for _, test := range tests {
+		if s := MustNewEndpointID(test.eid).IsSingleton(); s != test.singleton {
+			t.Fatalf("%s's singleton state is %t", test.eid, s)
+		}
+	}
+}
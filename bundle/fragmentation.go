@@ -0,0 +1,274 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// fragmentSkeleton creates a fragment of this Bundle with an empty payload for
+// the given offset and total data length. The first fragment contains all
+// extension blocks, the following ones only those flagged to be replicated.
+func (b Bundle) fragmentSkeleton(first bool, offset, totalDataLength uint) Bundle {
+	var frag = Bundle{PrimaryBlock: b.PrimaryBlock}
+
+	frag.PrimaryBlock.BundleControlFlags |= IsFragment
+	frag.PrimaryBlock.FragmentOffset = offset
+	frag.PrimaryBlock.TotalDataLength = totalDataLength
+
+	for _, cb := range b.CanonicalBlocks {
+		if cb.BlockType == PayloadBlock {
+			cb.Data = []byte{}
+		} else if !first && !cb.BlockControlFlags.Has(ReplicateBlock) {
+			continue
+		}
+
+		frag.CanonicalBlocks = append(frag.CanonicalBlocks, cb)
+	}
+
+	return frag
+}
+
+// Fragment splits this Bundle into fragments, whose CBOR representation does
+// not exceed the given maximum size, as described in section 5.8. A Bundle
+// which is not exceeding the maximum size will be returned unchanged.
+// An error is returned if this Bundle must not be fragmented or if the
+// maximum size is too small to carry any payload.
+//
+// Fragmenting an already fragmented Bundle results in fragments relative to
+// the original Bundle's payload.
+func (b Bundle) Fragment(maxSize uint) ([]Bundle, error) {
+	var fragments []Bundle
+	err := b.ForEachFragment(maxSize, func(frag Bundle) error {
+		fragments = append(fragments, frag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fragments, nil
+}
+
+// ForEachFragment creates the fragments of this Bundle one after another, as
+// Fragment does, and passes each to the given function. Only the current
+// fragment's part of the payload is held in memory, which matters for a
+// FilePayload. An error of the function stops the fragmentation and is
+// returned.
+func (b Bundle) ForEachFragment(maxSize uint, f func(Bundle) error) error {
+	if b.EncodedSize() <= uint64(maxSize) {
+		return f(b)
+	}
+
+	if b.PrimaryBlock.BundleControlFlags.Has(MustNotFragmented) {
+		return newBundleError(fmt.Sprintf(
+			"Bundle exceeds the maximum size of %d bytes, but must not be fragmented", maxSize))
+	}
+
+	payloadLength, err := b.payloadLength()
+	if err != nil {
+		return err
+	}
+
+	var baseOffset, totalDataLength = uint(0), uint(payloadLength)
+	if b.PrimaryBlock.HasFragmentation() {
+		baseOffset = b.PrimaryBlock.FragmentOffset
+		totalDataLength = b.PrimaryBlock.TotalDataLength
+	}
+
+	for offset := uint(0); offset < uint(payloadLength); {
+		var frag = b.fragmentSkeleton(offset == 0, baseOffset+offset, totalDataLength)
+
+		// The empty payload's byte string head has a length of one byte, but
+		// might grow up to nine bytes.
+		var overhead = uint(frag.EncodedSize()) + 8
+		if overhead >= maxSize {
+			return newBundleError(fmt.Sprintf(
+				"Maximum size of %d bytes is too small for a fragment", maxSize))
+		}
+
+		var end = offset + maxSize - overhead
+		if end > uint(payloadLength) {
+			end = uint(payloadLength)
+		}
+
+		data, err := b.payloadRange(uint64(offset), uint64(end))
+		if err != nil {
+			return err
+		}
+
+		payloadBlock, _ := frag.PayloadBlock()
+		payloadBlock.Data = data
+		frag.CalculateCRC()
+
+		if err := f(frag); err != nil {
+			return err
+		}
+		offset = end
+	}
+
+	return nil
+}
+
+// IsFragmentOf returns true if this Bundle is a fragment of the other Bundle,
+// identified by the source node and creation timestamp. The other Bundle
+// might also be a fragment.
+func (b Bundle) IsFragmentOf(other Bundle) bool {
+	return b.PrimaryBlock.HasFragmentation() &&
+		b.PrimaryBlock.SourceNode == other.PrimaryBlock.SourceNode &&
+		b.PrimaryBlock.CreationTimestamp == other.PrimaryBlock.CreationTimestamp
+}
+
+// ReassembleFragments reassembles the original Bundle from the given
+// fragments. The fragments might be unordered and overlapping, but must
+// belong to the same Bundle and cover its whole payload. Otherwise an
+// error will be returned.
+func ReassembleFragments(fragments []Bundle) (b Bundle, err error) {
+	if len(fragments) == 0 {
+		err = newBundleError("No fragments were given for reassembly")
+		return
+	}
+
+	var sorted = make([]Bundle, len(fragments))
+	copy(sorted, fragments)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].PrimaryBlock.FragmentOffset < sorted[j].PrimaryBlock.FragmentOffset
+	})
+
+	var totalDataLength = sorted[0].PrimaryBlock.TotalDataLength
+
+	sink, err := newPayloadSink(uint64(totalDataLength))
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			sink.discard()
+		}
+	}()
+
+	for _, frag := range sorted {
+		if !frag.IsFragmentOf(sorted[0]) ||
+			frag.PrimaryBlock.TotalDataLength != totalDataLength {
+			err = newBundleError(fmt.Sprintf(
+				"Bundle %v is no matching fragment of %v", frag, sorted[0]))
+			return
+		}
+
+		if err = sink.appendFragment(frag); err != nil {
+			return
+		}
+	}
+
+	if sink.n != uint64(totalDataLength) {
+		err = newBundleError(fmt.Sprintf(
+			"Fragments are missing the payload from %d to %d", sink.n, totalDataLength))
+		return
+	}
+
+	payload, err := sink.data()
+	if err != nil {
+		return
+	}
+
+	b = Bundle{
+		PrimaryBlock:    sorted[0].PrimaryBlock,
+		CanonicalBlocks: make([]CanonicalBlock, len(sorted[0].CanonicalBlocks)),
+	}
+	copy(b.CanonicalBlocks, sorted[0].CanonicalBlocks)
+
+	b.PrimaryBlock.BundleControlFlags &^= IsFragment
+	b.PrimaryBlock.FragmentOffset = 0
+	b.PrimaryBlock.TotalDataLength = 0
+
+	payloadBlock, _ := b.PayloadBlock()
+	payloadBlock.Data = payload
+	b.CalculateCRC()
+
+	return
+}
+
+// payloadSink collects a reassembled payload. If its total length reaches the
+// spool threshold, it is written into a payload file instead of memory.
+type payloadSink struct {
+	buf  bytes.Buffer
+	file *os.File
+	n    uint64
+}
+
+// newPayloadSink creates a payloadSink for a payload of the total length.
+func newPayloadSink(totalDataLength uint64) (ps *payloadSink, err error) {
+	ps = new(payloadSink)
+	if spoolPayload(totalDataLength) {
+		ps.file, err = NewPayloadFile()
+	}
+	return
+}
+
+func (ps *payloadSink) Write(p []byte) (n int, err error) {
+	if ps.file != nil {
+		n, err = ps.file.Write(p)
+	} else {
+		n, err = ps.buf.Write(p)
+	}
+
+	ps.n += uint64(n)
+	return
+}
+
+// appendFragment appends the part of the fragment's payload which exceeds the
+// already collected payload. The fragments must be passed in the order of
+// their offsets.
+func (ps *payloadSink) appendFragment(frag Bundle) error {
+	r, length, err := frag.PayloadReader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var offset = uint64(frag.PrimaryBlock.FragmentOffset)
+	var end = offset + length
+
+	switch {
+	case end > uint64(frag.PrimaryBlock.TotalDataLength):
+		return newBundleError(fmt.Sprintf(
+			"Fragment %v exceeds the total data length of %d", frag, frag.PrimaryBlock.TotalDataLength))
+
+	case offset > ps.n:
+		return newBundleError(fmt.Sprintf(
+			"Fragments are missing the payload from %d to %d", ps.n, offset))
+
+	case end > ps.n:
+		if _, err := io.CopyN(ioutil.Discard, r, int64(ps.n-offset)); err != nil {
+			return err
+		}
+		_, err := io.Copy(ps, r)
+		return err
+
+	default:
+		return nil
+	}
+}
+
+// data returns the collected payload, either a byte array or a FilePayload.
+func (ps *payloadSink) data() (interface{}, error) {
+	if ps.file == nil {
+		return ps.buf.Bytes(), nil
+	}
+
+	if err := ps.file.Close(); err != nil {
+		return nil, err
+	}
+	return FilePayload{Path: ps.file.Name(), Size: ps.n}, nil
+}
+
+// discard removes an unfinished payload file.
+func (ps *payloadSink) discard() {
+	if ps.file != nil {
+		ps.file.Close()
+		os.Remove(ps.file.Name())
+	}
+}
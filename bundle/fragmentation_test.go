@@ -0,0 +1,263 @@
+package bundle
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBundleFragmentReassemble(t *testing.T) {
+	var payload = make([]byte, 4096)
+	rand.Read(payload)
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPreviousNodeBlock(24, 0, MustNewEndpointID("dtn://prev/")),
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetCRCType(CRC32)
+	b.CalculateCRC()
+
+	for _, maxSize := range []uint{128, 512, 1000, 4000} {
+		frags, err := b.Fragment(maxSize)
+		if err != nil {
+			t.Fatalf("Fragmenting for %d bytes errored: %v", maxSize, err)
+		}
+
+		if len(frags) < 2 {
+			t.Fatalf("Fragmenting for %d bytes resulted in %d fragments", maxSize, len(frags))
+		}
+
+		for i, frag := range frags {
+			if l := uint(len(frag.ToCbor())); l > maxSize {
+				t.Fatalf("Fragment %d has %d bytes, exceeding %d", i, l, maxSize)
+			}
+
+			if !frag.IsFragmentOf(b) {
+				t.Fatalf("Fragment %d is not a fragment of its bundle", i)
+			}
+
+			if !frag.CheckCRC() {
+				t.Fatalf("Fragment %d has an invalid CRC", i)
+			}
+
+			_, prevErr := frag.ExtensionBlock(PreviousNodeBlock)
+			if (i == 0) == (prevErr != nil) {
+				t.Fatalf("Fragment %d has a wrong Previous Node block state", i)
+			}
+
+			if _, err := frag.ExtensionBlock(HopCountBlock); err != nil {
+				t.Fatalf("Fragment %d misses the replicated Hop Count block", i)
+			}
+
+			// Serialization round trip
+			if _, err := NewBundleFromCbor(frag.ToCbor()); err != nil {
+				t.Fatalf("Fragment %d could not be decoded: %v", i, err)
+			}
+		}
+
+		rand.Shuffle(len(frags), func(i, j int) { frags[i], frags[j] = frags[j], frags[i] })
+
+		b2, err := ReassembleFragments(frags)
+		if err != nil {
+			t.Fatalf("Reassembling for %d bytes errored: %v", maxSize, err)
+		}
+
+		if !reflect.DeepEqual(b, b2) {
+			t.Fatalf("Reassembled bundle differs:\n%v\n%v", b, b2)
+		}
+	}
+}
+
+func TestBundleFragmentRefragment(t *testing.T) {
+	var payload = make([]byte, 2048)
+	rand.Read(payload)
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetCRCType(CRC32)
+	b.CalculateCRC()
+
+	frags, err := b.Fragment(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fragment the first fragment again, resulting in overlapping fragments.
+	refrags, err := frags[0].Fragment(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, refrag := range refrags {
+		if refrag.PrimaryBlock.TotalDataLength != 2048 {
+			t.Fatalf("Refragment has a total data length of %d",
+				refrag.PrimaryBlock.TotalDataLength)
+		}
+	}
+
+	b2, err := ReassembleFragments(append(refrags, frags...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(b, b2) {
+		t.Fatalf("Reassembled bundle differs:\n%v\n%v", b, b2)
+	}
+}
+
+func TestBundleFragmentErrors(t *testing.T) {
+	var payload = make([]byte, 2048)
+	rand.Read(payload)
+
+	b, err := NewBundle(
+		NewPrimaryBlock(MustNotFragmented,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frags, err := b.Fragment(4096); err != nil || len(frags) != 1 {
+		t.Fatalf("Small enough bundle was fragmented: %v, %v", frags, err)
+	}
+
+	if _, err := b.Fragment(1024); err == nil {
+		t.Fatal("Bundle was fragmented, despite its MustNotFragmented flag")
+	}
+
+	b, err = NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Fragment(32); err == nil {
+		t.Fatal("Bundle was fragmented into too small fragments")
+	}
+}
+
+func TestBundleForEachFragment(t *testing.T) {
+	b, err := NewBundle(
+		NewPrimaryBlock(
+			0,
+			MustNewEndpointID("dtn:dest"),
+			MustNewEndpointID("dtn:src"),
+			NewCreationTimestamp(DtnTimeNow(), 0),
+			60*1000000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, make([]byte, 4096)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frags, err := b.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stop = newBundleError("stop")
+	var calls int
+	err = b.ForEachFragment(512, func(frag Bundle) error {
+		if !reflect.DeepEqual(frag, frags[calls]) {
+			t.Fatalf("Fragment %d differs from Fragment's", calls)
+		}
+
+		if calls++; calls == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop {
+		t.Fatalf("ForEachFragment returned %v instead of the function's error", err)
+	} else if calls != 2 {
+		t.Fatalf("ForEachFragment continued after an error, %d calls", calls)
+	}
+}
+
+func TestReassembleFragmentsMissing(t *testing.T) {
+	var payload = make([]byte, 2048)
+	rand.Read(payload)
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetCRCType(CRC32)
+	b.CalculateCRC()
+
+	frags, err := b.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range frags {
+		var missing = append(append([]Bundle{}, frags[:i]...), frags[i+1:]...)
+		if _, err := ReassembleFragments(missing); err == nil {
+			t.Fatalf("Reassembling without fragment %d did not error", i)
+		}
+	}
+
+	other, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 42), 42000),
+		[]CanonicalBlock{
+			NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.SetCRCType(CRC32)
+	other.CalculateCRC()
+
+	otherFrags, err := other.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReassembleFragments(append(frags[1:], otherFrags[0])); err == nil {
+		t.Fatal("Reassembling fragments of different bundles did not error")
+	}
+
+	if _, err := ReassembleFragments(nil); err == nil {
+		t.Fatal("Reassembling no fragments did not error")
+	}
+
+	if b2, err := ReassembleFragments(frags); err != nil {
+		t.Fatal(err)
+	} else if p1, p2 := b.CanonicalBlocks[0].Data.([]byte), b2.CanonicalBlocks[0].Data.([]byte); !bytes.Equal(p1, p2) {
+		t.Fatal("Reassembled payload differs")
+	}
+}
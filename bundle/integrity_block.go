@@ -0,0 +1,161 @@
+package bundle
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ugorji/go/codec"
+)
+
+// NewIntegrityBlock creates a new Block Integrity Block (BIB) for the given
+// AbstractSecurityBlock. One might prefer the Bundle's AddIntegrityBlock
+// method, which also creates the security results.
+func NewIntegrityBlock(blockNumber uint, blockControlFlags BlockControlFlags,
+	asb AbstractSecurityBlock) CanonicalBlock {
+	return NewCanonicalBlock(IntegrityBlock, blockNumber, blockControlFlags, asb)
+}
+
+// canonicalBlockByNumber returns a pointer to the canonical block with the
+// requested block number or an error, if there is no such block.
+func (b *Bundle) canonicalBlockByNumber(blockNumber uint) (*CanonicalBlock, error) {
+	for i := 0; i < len(b.CanonicalBlocks); i++ {
+		if b.CanonicalBlocks[i].BlockNumber == blockNumber {
+			return &b.CanonicalBlocks[i], nil
+		}
+	}
+
+	return nil, newBundleError(fmt.Sprintf(
+		"No CanonicalBlock with block number %d was found in Bundle", blockNumber))
+}
+
+// integrityProtectedPlainText creates the Integrity-Protected Plain Text
+// (IPPT) of a security target for the given BIB. The IPPT is a CBOR array of
+// the primary block, the targeted block and the BIB's header. All CRC values
+// are omitted and the primary block's fragmentation fields are reset to keep
+// the IPPT stable for fragments.
+func (b *Bundle) integrityProtectedPlainText(target uint, bib CanonicalBlock) ([]byte, error) {
+	targetBlock, err := b.canonicalBlockByNumber(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var cb = *targetBlock
+	cb.CRCType = CRCNo
+	cb.CRC = nil
+
+	// A FilePayload is protected by its content, not by its path.
+	if _, ok := cb.Data.(FilePayload); ok {
+		if cb.Data, err = blockData(cb); err != nil {
+			return nil, err
+		}
+	}
+
+	var ippt []byte
+	err = codec.NewEncoderBytes(&ippt, new(codec.CborHandle)).Encode(
+		[]interface{}{b.securityPrimaryBlock(), cb, securityBlockHeader(bib)})
+
+	return ippt, err
+}
+
+// AddIntegrityBlock adds a new Block Integrity Block (BIB) with the given block
+// number to this Bundle, which protects the targeted canonical blocks. The
+// security results are created by the IntegrityContext. The security source
+// might be dtn:none to be omitted.
+//
+// The primary block is always part of the IPPT. Blocks which are modified in
+// transit, e.g., the Hop Count block, should not be targeted.
+func (b *Bundle) AddIntegrityBlock(blockNumber uint, targets []uint,
+	source EndpointID, ctx IntegrityContext) error {
+	if _, err := b.canonicalBlockByNumber(blockNumber); err == nil {
+		return newBundleError(fmt.Sprintf(
+			"Bundle: Block number %d is already in use", blockNumber))
+	}
+
+	var asb = NewAbstractSecurityBlock(targets, ctx.ContextId(), source, ctx.Parameters())
+	var bib = NewIntegrityBlock(blockNumber, 0, asb)
+
+	for _, target := range targets {
+		if cb, err := b.canonicalBlockByNumber(target); err != nil {
+			return err
+		} else if cb.BlockType == IntegrityBlock {
+			return newBundleError(fmt.Sprintf(
+				"Bundle: Security target %d is itself a BIB", target))
+		}
+
+		ippt, err := b.integrityProtectedPlainText(target, bib)
+		if err != nil {
+			return err
+		}
+
+		results, err := ctx.Sign(ippt)
+		if err != nil {
+			return err
+		}
+
+		asb.SecurityResults = append(asb.SecurityResults, results)
+	}
+
+	if err := asb.checkValid(); err != nil {
+		return err
+	}
+
+	bib.Data = asb
+	b.CanonicalBlocks = append(b.CanonicalBlocks, bib)
+
+	return nil
+}
+
+// VerifyIntegrity checks the security results of each Block Integrity Block
+// with the given IntegrityContexts. A result is valid if at least one
+// IntegrityContext of the BIB's security context verifies it. A BIB whose
+// security context is unavailable cannot be verified and is skipped.
+// An error is returned for each failed verification.
+func (b *Bundle) VerifyIntegrity(contexts []IntegrityContext) (errs error) {
+	for _, bib := range b.CanonicalBlocks {
+		if bib.BlockType != IntegrityBlock {
+			continue
+		}
+
+		asb, ok := bib.Data.(AbstractSecurityBlock)
+		if !ok {
+			errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+				"Bundle: BIB %d contains no AbstractSecurityBlock", bib.BlockNumber)))
+			continue
+		}
+
+		var candidates []IntegrityContext
+		for _, ctx := range contexts {
+			if ctx.ContextId() == asb.SecurityContextId {
+				candidates = append(candidates, ctx)
+			}
+		}
+
+		if len(candidates) == 0 {
+			continue
+		}
+
+		for i, target := range asb.SecurityTargets {
+			ippt, err := b.integrityProtectedPlainText(target, bib)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+
+			var verified = false
+			for _, ctx := range candidates {
+				if i < len(asb.SecurityResults) &&
+					ctx.Verify(ippt, asb.SecurityContextParameters, asb.SecurityResults[i]) == nil {
+					verified = true
+					break
+				}
+			}
+
+			if !verified {
+				errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+					"Bundle: BIB %d failed to verify security target %d", bib.BlockNumber, target)))
+			}
+		}
+	}
+
+	return
+}
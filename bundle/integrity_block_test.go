@@ -0,0 +1,131 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestBundleIntegrity(t *testing.T) {
+	hmacCtx, err := NewHMACSHA2Context(HMAC384, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherHmacCtx, _ := NewHMACSHA2Context(HMAC384, []byte("other secret"))
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		signer   IntegrityContext
+		verifier IntegrityContext
+		other    IntegrityContext
+	}{
+		{hmacCtx, hmacCtx, otherHmacCtx},
+		{NewEd25519Context(priv), NewEd25519VerifyContext(pub), NewEd25519VerifyContext(otherPub)},
+	}
+
+	for _, test := range tests {
+		b, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 23), 42000),
+			[]CanonicalBlock{
+				NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+				NewPreviousNodeBlock(24, 0, MustNewEndpointID("dtn://prev/")),
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.AddIntegrityBlock(42, []uint{0, 24}, MustNewEndpointID("dtn:src"), test.signer); err != nil {
+			t.Fatal(err)
+		}
+
+		b.SetCRCType(CRC32)
+		b.CalculateCRC()
+
+		b2, err := NewBundleFromCbor(b.ToCbor())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b2.VerifyIntegrity([]IntegrityContext{test.verifier}); err != nil {
+			t.Fatalf("Verification failed: %v", err)
+		}
+
+		if err := b2.VerifyIntegrity([]IntegrityContext{test.other, test.verifier}); err != nil {
+			t.Fatalf("Verification with multiple contexts failed: %v", err)
+		}
+
+		if err := b2.VerifyIntegrity([]IntegrityContext{test.other}); err == nil {
+			t.Fatal("Verification with another key did not fail")
+		}
+
+		if err := b2.VerifyIntegrity(nil); err != nil {
+			t.Fatalf("Verification without any context failed: %v", err)
+		}
+
+		// The unprotected Hop Count block might be altered.
+		hcBlock, _ := b2.ExtensionBlock(HopCountBlock)
+		hc := hcBlock.Data.(HopCount)
+		hc.Increment()
+		hcBlock.Data = hc
+
+		if err := b2.VerifyIntegrity([]IntegrityContext{test.verifier}); err != nil {
+			t.Fatalf("Verification failed after altering an unprotected block: %v", err)
+		}
+
+		payload, _ := b2.PayloadBlock()
+		payload.Data = []byte("hello wörld")
+
+		if err := b2.VerifyIntegrity([]IntegrityContext{test.verifier}); err == nil {
+			t.Fatal("Verification did not fail for an altered payload")
+		}
+	}
+}
+
+func TestBundleAddIntegrityBlockErrors(t *testing.T) {
+	ctx, _ := NewHMACSHA2Context(HMAC256, []byte("secret"))
+
+	b, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 23), 42000),
+		[]CanonicalBlock{
+			NewHopCountBlock(23, ReplicateBlock, NewHopCount(16)),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddIntegrityBlock(23, []uint{0}, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BIB with an used block number did not fail")
+	}
+
+	if err := b.AddIntegrityBlock(42, []uint{1337}, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BIB with an unknown target did not fail")
+	}
+
+	if err := b.AddIntegrityBlock(42, []uint{0}, DtnNone(), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.AddIntegrityBlock(43, []uint{42}, DtnNone(), ctx); err == nil {
+		t.Fatal("Adding a BIB targeting another BIB did not fail")
+	}
+
+	if err := b.AddIntegrityBlock(43, []uint{0}, DtnNone(), NewEd25519VerifyContext(nil)); err == nil {
+		t.Fatal("Adding a BIB without a private key did not fail")
+	}
+
+	if _, err := NewHMACSHA2Context(23, []byte("secret")); err == nil {
+		t.Fatal("Creating an HMACSHA2Context with an unknown variant did not fail")
+	}
+}
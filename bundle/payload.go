@@ -0,0 +1,254 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// payloadFilePrefix prefixes the names of payload files in the spool
+// directory.
+const payloadFilePrefix = "payload-"
+
+// payloadSpool is the directory for payload files and the size threshold, from
+// which on the Decoder writes a payload into a file. An empty directory
+// disables this spooling.
+var payloadSpool struct {
+	sync.RWMutex
+	dir       string
+	threshold uint64
+}
+
+// SetPayloadSpool configures the directory for payload files and the size in
+// bytes, from which on decoded or reassembled payloads are written into such a
+// file instead of being held in memory. An empty directory disables spooling.
+// The directory must exist.
+func SetPayloadSpool(dir string, threshold uint64) error {
+	if dir != "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+
+		if fi, err := os.Stat(absDir); err != nil {
+			return err
+		} else if !fi.IsDir() {
+			return newBundleError(fmt.Sprintf("Payload spool %s is no directory", absDir))
+		}
+
+		dir = absDir
+	}
+
+	payloadSpool.Lock()
+	defer payloadSpool.Unlock()
+
+	payloadSpool.dir = dir
+	payloadSpool.threshold = threshold
+
+	return nil
+}
+
+// PayloadSpool returns the directory for payload files and the size threshold
+// for spooling. An empty directory indicates disabled spooling.
+func PayloadSpool() (dir string, threshold uint64) {
+	payloadSpool.RLock()
+	defer payloadSpool.RUnlock()
+
+	return payloadSpool.dir, payloadSpool.threshold
+}
+
+// spoolPayload returns true if a payload of the given size should be written
+// into a payload file.
+func spoolPayload(size uint64) bool {
+	dir, threshold := PayloadSpool()
+	return dir != "" && size >= threshold
+}
+
+// NewPayloadFile creates a new, empty payload file within the spool directory.
+// An error is returned if no spool directory is configured.
+func NewPayloadFile() (*os.File, error) {
+	dir, _ := PayloadSpool()
+	if dir == "" {
+		return nil, newBundleError("No payload spool directory is configured")
+	}
+
+	return ioutil.TempFile(dir, payloadFilePrefix+"*")
+}
+
+// PayloadFiles returns the paths of all payload files within the spool
+// directory. This includes files which are no longer referenced by any
+// bundle and should be removed by their owner.
+func PayloadFiles() ([]string, error) {
+	dir, _ := PayloadSpool()
+	if dir == "" {
+		return nil, nil
+	}
+
+	return filepath.Glob(filepath.Join(dir, payloadFilePrefix+"*"))
+}
+
+// FilePayload is a payload block's data which is stored in a file instead of
+// memory. The Encoder streams the file's content as the block's byte string
+// and the Decoder might create a FilePayload for large payloads, see
+// SetPayloadSpool.
+//
+// The file must not be altered while being referenced. Within the CBOR
+// encoding of a bundle, a FilePayload is never represented by its path. Only
+// the CanonicalBlock's own codec methods, used by the stores, keep the path.
+type FilePayload struct {
+	_struct struct{} `codec:",toarray"`
+
+	Path string
+	Size uint64
+}
+
+// NewFilePayload creates a FilePayload for the existing file at the path.
+func NewFilePayload(path string) (fp FilePayload, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	} else if !fi.Mode().IsRegular() {
+		err = newBundleError(fmt.Sprintf("Payload file %s is no regular file", path))
+		return
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return
+	}
+
+	fp = FilePayload{Path: path, Size: uint64(fi.Size())}
+	return
+}
+
+// Open opens the payload file for reading. An error is returned if the file's
+// size has changed.
+func (fp FilePayload) Open() (*os.File, error) {
+	f, err := os.Open(fp.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi, err := f.Stat(); err != nil {
+		f.Close()
+		return nil, err
+	} else if uint64(fi.Size()) != fp.Size {
+		f.Close()
+		return nil, newBundleError(fmt.Sprintf(
+			"Payload file %s has %d instead of %d bytes", fp.Path, fi.Size(), fp.Size))
+	}
+
+	return f, nil
+}
+
+// Bytes reads the whole payload file into memory.
+func (fp FilePayload) Bytes() ([]byte, error) {
+	f, err := fp.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data = make([]byte, fp.Size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (fp FilePayload) String() string {
+	return fmt.Sprintf("file %s (%d bytes)", fp.Path, fp.Size)
+}
+
+// blockData returns the byte array of a block's data. A FilePayload is read
+// into memory. An error is returned for any other kind of data.
+func blockData(cb CanonicalBlock) ([]byte, error) {
+	switch data := cb.Data.(type) {
+	case []byte:
+		return data, nil
+
+	case FilePayload:
+		return data.Bytes()
+
+	default:
+		return nil, newBundleError(fmt.Sprintf(
+			"Bundle: Block %d's data is no byte array", cb.BlockNumber))
+	}
+}
+
+// PayloadData returns the byte array of this Bundle's payload block or an
+// error, if there is no such block or its data is no byte array. A
+// FilePayload is read into memory; PayloadReader should be used instead.
+func (b *Bundle) PayloadData() ([]byte, error) {
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return blockData(*payloadBlock)
+}
+
+// PayloadReader returns a reader of this Bundle's payload and its length
+// without reading a FilePayload into memory. The reader must be closed.
+func (b *Bundle) PayloadReader() (io.ReadCloser, uint64, error) {
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch data := payloadBlock.Data.(type) {
+	case []byte:
+		return ioutil.NopCloser(bytes.NewReader(data)), uint64(len(data)), nil
+
+	case FilePayload:
+		f, err := data.Open()
+		return f, data.Size, err
+
+	default:
+		return nil, 0, newBundleError("Bundle's payload block contains no byte array")
+	}
+}
+
+// payloadLength returns the length of this Bundle's payload.
+func (b *Bundle) payloadLength() (uint64, error) {
+	r, n, err := b.PayloadReader()
+	if err != nil {
+		return 0, err
+	}
+
+	return n, r.Close()
+}
+
+// payloadRange returns the payload's bytes from offset up to end. Only this
+// range of a FilePayload is read.
+func (b *Bundle) payloadRange(offset, end uint64) ([]byte, error) {
+	payloadBlock, err := b.PayloadBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch data := payloadBlock.Data.(type) {
+	case []byte:
+		return data[offset:end], nil
+
+	case FilePayload:
+		f, err := data.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		var buf = make([]byte, end-offset)
+		if _, err := f.ReadAt(buf, int64(offset)); err != nil {
+			return nil, err
+		}
+		return buf, nil
+
+	default:
+		return nil, newBundleError("Bundle's payload block contains no byte array")
+	}
+}
@@ -0,0 +1,319 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+)
+
+func TestFilePayloadStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPayloadSpool("", 0)
+
+	var data = make([]byte, 100000)
+	rand.Read(data)
+
+	var path = filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := NewFilePayload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, crcType := range []CRCType{CRCNo, CRC16, CRC32} {
+		bndl, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 0), 42000),
+			[]CanonicalBlock{
+				NewFilePayloadBlock(0, fp),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bndl.SetCRCType(crcType)
+
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(bndl); err != nil {
+			t.Fatalf("Encoding with %v failed: %v", crcType, err)
+		}
+
+		if size := bndl.EncodedSize(); size != uint64(buf.Len()) {
+			t.Fatalf("EncodedSize with %v is %d instead of %d", crcType, size, buf.Len())
+		}
+
+		// The encoding must equal the one of an in-memory payload.
+		memBndl, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+				NewCreationTimestamp(4200, 0), 42000),
+			[]CanonicalBlock{
+				NewPayloadBlock(0, data),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		memBndl.SetCRCType(crcType)
+
+		if !bytes.Equal(buf.Bytes(), memBndl.ToCbor()) {
+			t.Fatalf("Encoding with %v differs from an in-memory payload", crcType)
+		}
+
+		decBndl, err := NewBundleFromCbor(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Decoding with %v failed: %v", crcType, err)
+		}
+
+		payloadBlock, _ := decBndl.PayloadBlock()
+		fp, ok := payloadBlock.Data.(FilePayload)
+		if !ok {
+			t.Fatalf("Decoded payload with %v is no FilePayload: %T", crcType, payloadBlock.Data)
+		} else if filepath.Dir(fp.Path) != dir {
+			t.Fatalf("Payload file %s is not within the spool %s", fp.Path, dir)
+		}
+
+		if decData, err := decBndl.PayloadData(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(decData, data) {
+			t.Fatalf("Decoded payload with %v differs", crcType)
+		}
+
+		if !decBndl.CheckCRC() {
+			t.Fatalf("Decoded bundle's CRC with %v mismatches", crcType)
+		}
+	}
+
+	if files, _ := PayloadFiles(); len(files) != 3 {
+		t.Fatalf("Spool contains %d instead of 3 payload files", len(files))
+	}
+}
+
+func TestFilePayloadBelowThreshold(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPayloadSpool("", 0)
+
+	var data = make([]byte, 1023)
+	rand.Read(data)
+
+	var path = filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := NewFilePayload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewFilePayloadBlock(0, fp),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC32)
+
+	decBndl, err := NewBundleFromCbor(bndl.ToCbor())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadBlock, _ := decBndl.PayloadBlock()
+	if _, ok := payloadBlock.Data.([]byte); !ok {
+		t.Fatalf("Payload below the threshold is no byte array: %T", payloadBlock.Data)
+	}
+}
+
+func TestFilePayloadCRCError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPayloadSpool("", 0)
+
+	var data = bytes.Repeat([]byte("hello world"), 1000)
+
+	var path = filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := NewFilePayload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewFilePayloadBlock(0, fp),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC16)
+
+	var encoded = bndl.ToCbor()
+	encoded[bytes.Index(encoded, data)+42] = 'j'
+
+	var decBndl Bundle
+	var crcErr *CRCError
+	if err := NewDecoder(bytes.NewReader(encoded)).Decode(&decBndl); !errors.As(err, &crcErr) {
+		t.Fatalf("Decoding a corrupted payload resulted in %v instead of a CRCError", err)
+	}
+
+	// A truncated payload must not leave a payload file behind.
+	if _, err := NewBundleFromCbor(encoded[:len(encoded)/2]); err == nil {
+		t.Fatalf("Decoding a truncated payload did not error")
+	}
+
+	if files, _ := PayloadFiles(); len(files) != 1 {
+		t.Fatalf("Spool contains %d instead of 1 payload file", len(files))
+	}
+}
+
+func TestFilePayloadNotFromWire(t *testing.T) {
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewCanonicalBlock(PayloadBlock, 0, 0, []interface{}{"/etc/passwd", uint64(42)}),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decErr *DecodeError
+	if _, err := NewBundleFromCbor(bndl.ToCbor()); !errors.As(err, &decErr) {
+		t.Fatalf("Decoding a referenced file resulted in %v instead of a DecodeError", err)
+	}
+}
+
+func TestFilePayloadCodec(t *testing.T) {
+	var cb = NewFilePayloadBlock(0, FilePayload{Path: "/tmp/payload-23", Size: 42})
+	cb.SetCRCType(CRC32)
+	cb.CRC = []byte{0xde, 0xad, 0xbe, 0xef}
+
+	var buf []byte
+	if err := codec.NewEncoderBytes(&buf, new(codec.CborHandle)).Encode(cb); err != nil {
+		t.Fatal(err)
+	}
+
+	var cb2 CanonicalBlock
+	if err := codec.NewDecoderBytes(buf, new(codec.CborHandle)).Decode(&cb2); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cb, cb2) {
+		t.Fatalf("Decoded block %v differs from %v", cb2, cb)
+	}
+}
+
+func TestFilePayloadFragmentation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPayloadSpool("", 0)
+
+	var data = make([]byte, 10000)
+	rand.Read(data)
+
+	var path = filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := NewFilePayload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bndl, err := NewBundle(
+		NewPrimaryBlock(0,
+			MustNewEndpointID("dtn://dest/"), MustNewEndpointID("dtn://src/"),
+			NewCreationTimestamp(4200, 0), 42000),
+		[]CanonicalBlock{
+			NewFilePayloadBlock(0, fp),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bndl.SetCRCType(CRC32)
+
+	frags, err := bndl.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, frag := range frags {
+		if size := len(frag.ToCbor()); size > 512 {
+			t.Fatalf("Fragment %d has %d bytes", i, size)
+		}
+	}
+
+	rand.Shuffle(len(frags), func(i, j int) { frags[i], frags[j] = frags[j], frags[i] })
+
+	reBndl, err := ReassembleFragments(frags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadBlock, _ := reBndl.PayloadBlock()
+	if _, ok := payloadBlock.Data.(FilePayload); !ok {
+		t.Fatalf("Reassembled payload is no FilePayload: %T", payloadBlock.Data)
+	}
+
+	if reData, err := reBndl.PayloadData(); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(reData, data) {
+		t.Fatalf("Reassembled payload differs")
+	}
+
+	if !reBndl.CheckCRC() {
+		t.Fatalf("Reassembled bundle's CRC mismatches")
+	}
+}
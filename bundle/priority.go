@@ -0,0 +1,101 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PriorityClass is a bundle's class of service, as known from RFC 5050's
+// bulk, normal and expedited priorities. Greater values are more important.
+type PriorityClass uint
+
+const (
+	// BulkPriority is the class for bundles which should be handled after all
+	// others, e.g., large log uploads.
+	BulkPriority PriorityClass = 0
+
+	// NormalPriority is the default class of bundles without a Priority block.
+	NormalPriority PriorityClass = 1
+
+	// ExpeditedPriority is the class for the most important bundles, e.g.,
+	// emergency alerts.
+	ExpeditedPriority PriorityClass = 2
+)
+
+// isValid returns true if this PriorityClass is one of the known classes.
+func (pc PriorityClass) isValid() bool {
+	return pc <= ExpeditedPriority
+}
+
+func (pc PriorityClass) String() string {
+	switch pc {
+	case BulkPriority:
+		return "bulk"
+	case NormalPriority:
+		return "normal"
+	case ExpeditedPriority:
+		return "expedited"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint(pc))
+	}
+}
+
+// ParsePriorityClass returns the PriorityClass for its name, as returned by
+// its String method.
+func ParsePriorityClass(name string) (PriorityClass, error) {
+	for _, pc := range []PriorityClass{BulkPriority, NormalPriority, ExpeditedPriority} {
+		if strings.EqualFold(name, pc.String()) {
+			return pc, nil
+		}
+	}
+
+	return 0, newBundleError(fmt.Sprintf("Unknown priority class %s", name))
+}
+
+// Priority represents the tuple of a PriorityClass and an ordinal for the
+// Priority block. The ordinal orders bundles within the same class, greater
+// values are more important.
+type Priority struct {
+	_struct struct{} `codec:",toarray"`
+
+	Class   PriorityClass
+	Ordinal uint
+}
+
+// NewPriority returns a new Priority of the given class and ordinal.
+func NewPriority(class PriorityClass, ordinal uint) Priority {
+	return Priority{
+		Class:   class,
+		Ordinal: ordinal,
+	}
+}
+
+// DefaultPriority returns the Priority of bundles without a Priority block.
+func DefaultPriority() Priority {
+	return NewPriority(NormalPriority, 0)
+}
+
+// Higher returns true if this Priority is more important than the other one.
+func (p Priority) Higher(other Priority) bool {
+	if p.Class != other.Class {
+		return p.Class > other.Class
+	}
+
+	return p.Ordinal > other.Ordinal
+}
+
+func (p Priority) String() string {
+	return fmt.Sprintf("(%v, %d)", p.Class, p.Ordinal)
+}
+
+// Priority returns the Priority of this Bundle's Priority block or the
+// DefaultPriority, if there is no such block.
+func (b *Bundle) Priority() Priority {
+	if cb, err := b.ExtensionBlock(PriorityBlock); err == nil {
+		if prio, ok := cb.Data.(Priority); ok {
+			return prio
+		}
+	}
+
+	return DefaultPriority()
+}
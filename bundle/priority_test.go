@@ -0,0 +1,79 @@
+package bundle
+
+import "testing"
+
+func TestPriorityHigher(t *testing.T) {
+	tests := []struct {
+		a      Priority
+		b      Priority
+		higher bool
+	}{
+		{NewPriority(ExpeditedPriority, 0), NewPriority(NormalPriority, 0), true},
+		{NewPriority(NormalPriority, 0), NewPriority(ExpeditedPriority, 0), false},
+		{NewPriority(BulkPriority, 100), NewPriority(NormalPriority, 0), false},
+		{NewPriority(NormalPriority, 2), NewPriority(NormalPriority, 1), true},
+		{NewPriority(NormalPriority, 1), NewPriority(NormalPriority, 1), false},
+		{DefaultPriority(), NewPriority(BulkPriority, 0), true},
+	}
+
+	for _, test := range tests {
+		if higher := test.a.Higher(test.b); higher != test.higher {
+			t.Errorf("%v higher than %v is %t instead of %t",
+				test.a, test.b, higher, test.higher)
+		}
+	}
+}
+
+func TestParsePriorityClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		class PriorityClass
+		valid bool
+	}{
+		{"bulk", BulkPriority, true},
+		{"normal", NormalPriority, true},
+		{"Expedited", ExpeditedPriority, true},
+		{"urgent", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		class, err := ParsePriorityClass(test.name)
+		if (err == nil) != test.valid {
+			t.Errorf("Parsing %s resulted in %v", test.name, err)
+		} else if test.valid && class != test.class {
+			t.Errorf("Parsing %s resulted in %v instead of %v", test.name, class, test.class)
+		}
+	}
+}
+
+func TestBundlePriority(t *testing.T) {
+	var bndl, err = NewBundle(
+		NewPrimaryBlock(
+			MustNotFragmented,
+			MustNewEndpointID("dtn:some"), DtnNone(),
+			NewCreationTimestamp(DtnTimeEpoch, 0), 3600),
+		[]CanonicalBlock{
+			NewBundleAgeBlock(1, 0, 420),
+			NewPayloadBlock(0, []byte("hello world")),
+		})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prio := bndl.Priority(); prio != DefaultPriority() {
+		t.Errorf("Bundle without a Priority block has priority %v", prio)
+	}
+
+	var expedited = NewPriority(ExpeditedPriority, 23)
+	bndl.AddExtensionBlock(NewPriorityBlock(0, 0, expedited))
+
+	if prio := bndl.Priority(); prio != expedited {
+		t.Errorf("Bundle has priority %v instead of %v", prio, expedited)
+	}
+
+	if err := bndl.checkValid(); err != nil {
+		t.Errorf("Bundle with a Priority block is invalid: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ugorji/go/codec"
+)
+
+// SecurityContextId identifies a BPSec security context, as defined in section
+// 3.10 of draft-ietf-dtn-bpsec. Negative values are reserved for local or
+// experimental use.
+type SecurityContextId int
+
+// SecurityContextFlags are the flags of an AbstractSecurityBlock, indicating
+// the presence of optional fields.
+type SecurityContextFlags uint
+
+const (
+	// SecurityContextParametersPresent indicates the presence of security
+	// context parameters.
+	SecurityContextParametersPresent SecurityContextFlags = 0x01
+
+	// SecuritySourcePresent indicates the presence of a security source.
+	SecuritySourcePresent SecurityContextFlags = 0x02
+)
+
+// Has returns true if a given flag or mask of flags is set.
+func (scf SecurityContextFlags) Has(flag SecurityContextFlags) bool {
+	return (scf & flag) != 0
+}
+
+// SecurityItem is a tuple of an identifier and a value, which is used for both
+// security context parameters and security results. The value's meaning is
+// defined by the security context.
+type SecurityItem struct {
+	_struct struct{} `codec:",toarray"`
+
+	Id    uint
+	Value interface{}
+}
+
+// NewSecurityItem creates a new SecurityItem.
+func NewSecurityItem(id uint, value interface{}) SecurityItem {
+	return SecurityItem{
+		Id:    id,
+		Value: value,
+	}
+}
+
+// securityItemFromCbor creates a SecurityItem from its generic CBOR decoding.
+// The codec library decodes uints as uint64, which will be converted.
+func securityItemFromCbor(data interface{}) SecurityItem {
+	var tuple = data.([]interface{})
+	var si = SecurityItem{Id: uint(tuple[0].(uint64))}
+
+	switch value := tuple[1].(type) {
+	case uint64:
+		si.Value = uint(value)
+	default:
+		si.Value = value
+	}
+
+	return si
+}
+
+// findSecurityItem returns the value of the first SecurityItem with the
+// requested id and true or nil and false, if there is no such item.
+func findSecurityItem(items []SecurityItem, id uint) (interface{}, bool) {
+	for _, item := range items {
+		if item.Id == id {
+			return item.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// securityPrimaryBlock returns a copy of this Bundle's primary block, used
+// within the input of security operations. The CRC value is omitted and the
+// fragmentation fields are reset to keep the result stable for fragments.
+func (b Bundle) securityPrimaryBlock() PrimaryBlock {
+	var pb = b.PrimaryBlock
+	pb.BundleControlFlags &^= IsFragment
+	pb.FragmentOffset = 0
+	pb.TotalDataLength = 0
+	pb.CRCType = CRCNo
+	pb.CRC = nil
+
+	return pb
+}
+
+// securityBlockHeader returns the header of a canonical block, its block type,
+// block number and block processing control flags, which is used within the
+// input of security operations.
+func securityBlockHeader(cb CanonicalBlock) []interface{} {
+	return []interface{}{cb.BlockType, cb.BlockNumber, cb.BlockControlFlags}
+}
+
+// AbstractSecurityBlock is the block-type-specific data of the BPSec security
+// blocks, both Block Integrity Blocks and Block Confidentiality Blocks, as
+// defined in section 3.6 of draft-ietf-dtn-bpsec. The security targets
+// reference canonical blocks by their block number and each target has its
+// own list of security results.
+type AbstractSecurityBlock struct {
+	SecurityTargets           []uint
+	SecurityContextId         SecurityContextId
+	SecuritySource            EndpointID
+	SecurityContextParameters []SecurityItem
+	SecurityResults           [][]SecurityItem
+}
+
+// NewAbstractSecurityBlock creates a new AbstractSecurityBlock without any
+// security results. The security source might be dtn:none to be omitted.
+func NewAbstractSecurityBlock(targets []uint, contextId SecurityContextId,
+	source EndpointID, parameters []SecurityItem) AbstractSecurityBlock {
+	return AbstractSecurityBlock{
+		SecurityTargets:           targets,
+		SecurityContextId:         contextId,
+		SecuritySource:            source,
+		SecurityContextParameters: parameters,
+		SecurityResults:           make([][]SecurityItem, 0, len(targets)),
+	}
+}
+
+// Flags returns the SecurityContextFlags, based on the present fields.
+func (asb AbstractSecurityBlock) Flags() (flags SecurityContextFlags) {
+	if len(asb.SecurityContextParameters) > 0 {
+		flags |= SecurityContextParametersPresent
+	}
+
+	if asb.SecuritySource != DtnNone() {
+		flags |= SecuritySourcePresent
+	}
+
+	return
+}
+
+// HasTarget returns true if the block number is a security target.
+func (asb AbstractSecurityBlock) HasTarget(blockNumber uint) bool {
+	for _, target := range asb.SecurityTargets {
+		if target == blockNumber {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (asb AbstractSecurityBlock) CodecEncodeSelf(enc *codec.Encoder) {
+	var flags = asb.Flags()
+	var blockArr = []interface{}{
+		asb.SecurityTargets,
+		asb.SecurityContextId,
+		flags}
+
+	if flags.Has(SecuritySourcePresent) {
+		blockArr = append(blockArr, asb.SecuritySource)
+	}
+
+	if flags.Has(SecurityContextParametersPresent) {
+		blockArr = append(blockArr, asb.SecurityContextParameters)
+	}
+
+	blockArr = append(blockArr, asb.SecurityResults)
+
+	enc.MustEncode(blockArr)
+}
+
+// setAbstractSecurityBlockFromCborArray sets the fields of the
+// AbstractSecurityBlock addressed by the pointer based on the given array.
+// This function is used for the CBOR decoding of the security blocks.
+func setAbstractSecurityBlockFromCborArray(asb *AbstractSecurityBlock, arr []interface{}) {
+	if len(arr) < 4 {
+		panic("AbstractSecurityBlock's array is too short")
+	}
+
+	var targets = arr[0].([]interface{})
+	asb.SecurityTargets = make([]uint, len(targets))
+	for i, target := range targets {
+		asb.SecurityTargets[i] = uint(target.(uint64))
+	}
+
+	switch contextId := arr[1].(type) {
+	case uint64:
+		asb.SecurityContextId = SecurityContextId(contextId)
+	case int64:
+		asb.SecurityContextId = SecurityContextId(contextId)
+	default:
+		panic("AbstractSecurityBlock's security context id is no integer")
+	}
+
+	var flags = SecurityContextFlags(arr[2].(uint64))
+	var pos = 3
+
+	asb.SecuritySource = DtnNone()
+	if flags.Has(SecuritySourcePresent) {
+		setEndpointIDFromCborArray(&asb.SecuritySource, arr[pos].([]interface{}))
+		pos++
+	}
+
+	asb.SecurityContextParameters = nil
+	if flags.Has(SecurityContextParametersPresent) {
+		for _, param := range arr[pos].([]interface{}) {
+			asb.SecurityContextParameters = append(
+				asb.SecurityContextParameters, securityItemFromCbor(param))
+		}
+		pos++
+	}
+
+	var results = arr[pos].([]interface{})
+	asb.SecurityResults = make([][]SecurityItem, len(results))
+	for i, targetResults := range results {
+		asb.SecurityResults[i] = []SecurityItem{}
+		for _, result := range targetResults.([]interface{}) {
+			asb.SecurityResults[i] = append(asb.SecurityResults[i], securityItemFromCbor(result))
+		}
+	}
+}
+
+func (asb *AbstractSecurityBlock) CodecDecodeSelf(dec *codec.Decoder) {
+	var blockArrPt = new([]interface{})
+	dec.MustDecode(blockArrPt)
+
+	setAbstractSecurityBlockFromCborArray(asb, *blockArrPt)
+}
+
+func (asb AbstractSecurityBlock) checkValid() (errs error) {
+	if len(asb.SecurityTargets) == 0 {
+		errs = multierror.Append(errs,
+			newBundleError("AbstractSecurityBlock: No security targets are present"))
+	}
+
+	if len(asb.SecurityTargets) != len(asb.SecurityResults) {
+		errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+			"AbstractSecurityBlock: %d security targets, but %d security results",
+			len(asb.SecurityTargets), len(asb.SecurityResults))))
+	}
+
+	var targets = make(map[uint]bool)
+	for _, target := range asb.SecurityTargets {
+		if targets[target] {
+			errs = multierror.Append(errs, newBundleError(fmt.Sprintf(
+				"AbstractSecurityBlock: Security target %d occurred multiple times", target)))
+		}
+		targets[target] = true
+	}
+
+	if asb.SecuritySource != DtnNone() {
+		if srcErr := asb.SecuritySource.checkValid(); srcErr != nil {
+			errs = multierror.Append(errs, srcErr)
+		}
+	}
+
+	return
+}
+
+func (asb AbstractSecurityBlock) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "targets: %v, ", asb.SecurityTargets)
+	fmt.Fprintf(&b, "context: %d", asb.SecurityContextId)
+
+	if asb.SecuritySource != DtnNone() {
+		fmt.Fprintf(&b, ", source: %v", asb.SecuritySource)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package bundle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAbstractSecurityBlockCbor(t *testing.T) {
+	tests := []AbstractSecurityBlock{
+		{
+			SecurityTargets:           []uint{0},
+			SecurityContextId:         SecContextHMACSHA2,
+			SecuritySource:            DtnNone(),
+			SecurityContextParameters: nil,
+			SecurityResults:           [][]SecurityItem{{NewSecurityItem(1, []byte{0x23, 0x42})}},
+		},
+		{
+			SecurityTargets:   []uint{0, 23},
+			SecurityContextId: SecContextEd25519,
+			SecuritySource:    MustNewEndpointID("dtn:src"),
+			SecurityContextParameters: []SecurityItem{
+				NewSecurityItem(1, uint(5)), NewSecurityItem(2, []byte("foo"))},
+			SecurityResults: [][]SecurityItem{
+				{NewSecurityItem(1, []byte{0x23})}, {NewSecurityItem(1, []byte{0x42})}},
+		},
+	}
+
+	for _, asb := range tests {
+		b, err := NewBundle(
+			NewPrimaryBlock(0,
+				MustNewEndpointID("dtn:dest"), MustNewEndpointID("dtn:src"),
+				NewCreationTimestamp(4200, 23), 42000),
+			[]CanonicalBlock{
+				NewHopCountBlock(23, 0, NewHopCount(16)),
+				NewIntegrityBlock(42, 0, asb),
+				NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b2, err := NewBundleFromCbor(b.ToCbor())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(b, b2) {
+			t.Fatalf("Bundles differ:\n%v\n%v", b.CanonicalBlocks[1], b2.CanonicalBlocks[1])
+		}
+	}
+}
+
+func TestAbstractSecurityBlockCheckValid(t *testing.T) {
+	tests := []struct {
+		asb   AbstractSecurityBlock
+		valid bool
+	}{
+		{NewAbstractSecurityBlock([]uint{0}, SecContextHMACSHA2, DtnNone(), nil), false},
+		{AbstractSecurityBlock{
+			SecurityTargets: []uint{0}, SecuritySource: DtnNone(),
+			SecurityResults: [][]SecurityItem{{}}}, true},
+		{AbstractSecurityBlock{
+			SecurityTargets: []uint{}, SecuritySource: DtnNone(),
+			SecurityResults: [][]SecurityItem{}}, false},
+		{AbstractSecurityBlock{
+			SecurityTargets: []uint{0, 0}, SecuritySource: DtnNone(),
+			SecurityResults: [][]SecurityItem{{}, {}}}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.asb.checkValid(); (err == nil) != test.valid {
+			t.Fatalf("AbstractSecurityBlock %v: expected valid = %t, got %v", test.asb, test.valid, err)
+		}
+	}
+}
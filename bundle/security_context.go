@@ -0,0 +1,346 @@
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+const (
+	// SecContextHMACSHA2 is the security context identifier of BIB-HMAC-SHA2,
+	// as defined in draft-ietf-dtn-bpsec-default-sc.
+	SecContextHMACSHA2 SecurityContextId = 1
+
+	// SecContextAESGCM is the security context identifier of BCB-AES-GCM, as
+	// defined in draft-ietf-dtn-bpsec-default-sc.
+	SecContextAESGCM SecurityContextId = 2
+
+	// SecContextEd25519 is the security context identifier of this
+	// implementation's Ed25519 signature context. There is no specified Ed25519
+	// security context; therefore, an identifier from the negative range,
+	// reserved for local use, was chosen.
+	SecContextEd25519 SecurityContextId = -1
+)
+
+// IntegrityContext is a security context for Block Integrity Blocks. It
+// creates and verifies the security results of a single security target,
+// based on its Integrity-Protected Plain Text (IPPT).
+type IntegrityContext interface {
+	// ContextId returns the identifier of this security context.
+	ContextId() SecurityContextId
+
+	// Parameters returns the security context parameters, which will be
+	// stored in a created BIB.
+	Parameters() []SecurityItem
+
+	// Sign creates the security results for the given IPPT.
+	Sign(ippt []byte) ([]SecurityItem, error)
+
+	// Verify checks the security results of a received BIB against the IPPT.
+	// The parameters are taken from this BIB. An error is returned if the
+	// verification fails.
+	Verify(ippt []byte, parameters, results []SecurityItem) error
+}
+
+// HMACSHA2Variant is the SHA variant of the BIB-HMAC-SHA2 security context.
+type HMACSHA2Variant uint
+
+const (
+	// HMAC256 is HMAC 256/256, using SHA-256.
+	HMAC256 HMACSHA2Variant = 5
+
+	// HMAC384 is HMAC 384/384, using SHA-384.
+	HMAC384 HMACSHA2Variant = 6
+
+	// HMAC512 is HMAC 512/512, using SHA-512.
+	HMAC512 HMACSHA2Variant = 7
+)
+
+const (
+	// hmacSHA2ParamVariant is the parameter id of the SHA variant.
+	hmacSHA2ParamVariant uint = 1
+
+	// hmacSHA2ResultHMAC is the result id of the expected HMAC.
+	hmacSHA2ResultHMAC uint = 1
+)
+
+// hashFunc returns the hash function of this variant or nil, if unknown.
+func (v HMACSHA2Variant) hashFunc() func() hash.Hash {
+	switch v {
+	case HMAC256:
+		return sha256.New
+	case HMAC384:
+		return sha512.New384
+	case HMAC512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// HMACSHA2Context is an IntegrityContext for BIB-HMAC-SHA2, using a pre-shared
+// key. The key is neither wrapped nor transmitted.
+type HMACSHA2Context struct {
+	variant HMACSHA2Variant
+	key     []byte
+}
+
+// NewHMACSHA2Context creates a new HMACSHA2Context for the given SHA variant
+// and pre-shared key.
+func NewHMACSHA2Context(variant HMACSHA2Variant, key []byte) (*HMACSHA2Context, error) {
+	if variant.hashFunc() == nil {
+		return nil, newBundleError(fmt.Sprintf("HMACSHA2Context: Unknown variant %d", variant))
+	}
+
+	return &HMACSHA2Context{
+		variant: variant,
+		key:     key,
+	}, nil
+}
+
+// ContextId returns SecContextHMACSHA2.
+func (hc *HMACSHA2Context) ContextId() SecurityContextId {
+	return SecContextHMACSHA2
+}
+
+// Parameters returns the SHA variant as the only parameter.
+func (hc *HMACSHA2Context) Parameters() []SecurityItem {
+	return []SecurityItem{NewSecurityItem(hmacSHA2ParamVariant, uint(hc.variant))}
+}
+
+func (hc *HMACSHA2Context) hmac(variant HMACSHA2Variant, ippt []byte) []byte {
+	var mac = hmac.New(variant.hashFunc(), hc.key)
+	mac.Write(ippt)
+
+	return mac.Sum(nil)
+}
+
+// Sign creates the expected HMAC as the only security result.
+func (hc *HMACSHA2Context) Sign(ippt []byte) ([]SecurityItem, error) {
+	return []SecurityItem{NewSecurityItem(hmacSHA2ResultHMAC, hc.hmac(hc.variant, ippt))}, nil
+}
+
+// Verify compares the expected HMAC against the IPPT's HMAC. The SHA variant
+// defaults to HMAC256 if no such parameter is present.
+func (hc *HMACSHA2Context) Verify(ippt []byte, parameters, results []SecurityItem) error {
+	var variant = HMAC256
+	if value, ok := findSecurityItem(parameters, hmacSHA2ParamVariant); ok {
+		if v, ok := value.(uint); ok {
+			variant = HMACSHA2Variant(v)
+		}
+	}
+
+	if variant.hashFunc() == nil {
+		return newBundleError(fmt.Sprintf("HMACSHA2Context: Unknown variant %d", variant))
+	}
+
+	value, ok := findSecurityItem(results, hmacSHA2ResultHMAC)
+	if !ok {
+		return newBundleError("HMACSHA2Context: No expected HMAC is present")
+	}
+
+	expected, ok := value.([]byte)
+	if !ok || !hmac.Equal(expected, hc.hmac(variant, ippt)) {
+		return newBundleError("HMACSHA2Context: HMAC mismatches")
+	}
+
+	return nil
+}
+
+// ed25519ResultSignature is the result id of an Ed25519 signature.
+const ed25519ResultSignature uint = 1
+
+// Ed25519Context is an IntegrityContext creating Ed25519 signatures. A context
+// without a private key is only able to verify signatures.
+type Ed25519Context struct {
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+// NewEd25519Context creates a new Ed25519Context for the given private key,
+// which is able to both sign and verify.
+func NewEd25519Context(privateKey ed25519.PrivateKey) *Ed25519Context {
+	return &Ed25519Context{
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+		privateKey: privateKey,
+	}
+}
+
+// NewEd25519VerifyContext creates a new Ed25519Context for the given public
+// key, which is only able to verify.
+func NewEd25519VerifyContext(publicKey ed25519.PublicKey) *Ed25519Context {
+	return &Ed25519Context{
+		publicKey: publicKey,
+	}
+}
+
+// ContextId returns SecContextEd25519.
+func (ec *Ed25519Context) ContextId() SecurityContextId {
+	return SecContextEd25519
+}
+
+// Parameters returns no parameters.
+func (ec *Ed25519Context) Parameters() []SecurityItem {
+	return nil
+}
+
+// Sign creates the IPPT's signature as the only security result.
+func (ec *Ed25519Context) Sign(ippt []byte) ([]SecurityItem, error) {
+	if ec.privateKey == nil {
+		return nil, newBundleError("Ed25519Context: No private key is available for signing")
+	}
+
+	return []SecurityItem{
+		NewSecurityItem(ed25519ResultSignature, ed25519.Sign(ec.privateKey, ippt))}, nil
+}
+
+// Verify checks the signature against the IPPT.
+func (ec *Ed25519Context) Verify(ippt []byte, _, results []SecurityItem) error {
+	if len(ec.publicKey) != ed25519.PublicKeySize {
+		return newBundleError("Ed25519Context: Public key has an invalid length")
+	}
+
+	value, ok := findSecurityItem(results, ed25519ResultSignature)
+	if !ok {
+		return newBundleError("Ed25519Context: No signature is present")
+	}
+
+	signature, ok := value.([]byte)
+	if !ok || !ed25519.Verify(ec.publicKey, ippt, signature) {
+		return newBundleError("Ed25519Context: Signature mismatches")
+	}
+
+	return nil
+}
+
+func (ec *Ed25519Context) String() string {
+	return fmt.Sprintf("Ed25519Context(%x)", []byte(ec.publicKey))
+}
+
+// ConfidentialityContext is a security context for Block Confidentiality
+// Blocks. It encrypts and decrypts the block-type-specific data of a single
+// security target, while authenticating additional data.
+type ConfidentialityContext interface {
+	// ContextId returns the identifier of this security context.
+	ContextId() SecurityContextId
+
+	// Encrypt encrypts the plaintext and authenticates the additional data. The
+	// ciphertext, the security context parameters and the security results are
+	// returned.
+	Encrypt(plaintext, aad []byte) (ciphertext []byte, parameters, results []SecurityItem, err error)
+
+	// Decrypt decrypts the ciphertext of a received BCB and authenticates the
+	// additional data. The parameters and results are taken from this BCB. An
+	// error is returned if the decryption or authentication fails.
+	Decrypt(ciphertext, aad []byte, parameters, results []SecurityItem) ([]byte, error)
+}
+
+const (
+	// aesGCMParamIV is the parameter id of the initialization vector.
+	aesGCMParamIV uint = 1
+
+	// aesGCMParamVariant is the parameter id of the AES variant.
+	aesGCMParamVariant uint = 2
+
+	// aesGCMResultTag is the result id of the authentication tag.
+	aesGCMResultTag uint = 1
+)
+
+// AESGCMContext is a ConfidentialityContext for BCB-AES-GCM, using a pre-shared
+// key. The key is neither wrapped nor transmitted. Its length selects the AES
+// variant: 16 bytes for A128GCM or 32 bytes for A256GCM.
+type AESGCMContext struct {
+	aead    cipher.AEAD
+	variant uint
+}
+
+// NewAESGCMContext creates a new AESGCMContext for the given pre-shared key.
+func NewAESGCMContext(key []byte) (*AESGCMContext, error) {
+	var variant uint
+	switch len(key) {
+	case 16:
+		variant = 1 // A128GCM
+	case 32:
+		variant = 3 // A256GCM
+	default:
+		return nil, newBundleError(fmt.Sprintf(
+			"AESGCMContext: Key length of %d bytes is unsupported", len(key)))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESGCMContext{
+		aead:    aead,
+		variant: variant,
+	}, nil
+}
+
+// ContextId returns SecContextAESGCM.
+func (ac *AESGCMContext) ContextId() SecurityContextId {
+	return SecContextAESGCM
+}
+
+// Encrypt encrypts the plaintext with a random initialization vector, which is
+// returned as a parameter next to the AES variant. The authentication tag is
+// the only security result.
+func (ac *AESGCMContext) Encrypt(plaintext, aad []byte) ([]byte, []SecurityItem, []SecurityItem, error) {
+	var iv = make([]byte, ac.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var sealed = ac.aead.Seal(nil, iv, plaintext, aad)
+	var tagPos = len(sealed) - ac.aead.Overhead()
+
+	var parameters = []SecurityItem{
+		NewSecurityItem(aesGCMParamIV, iv),
+		NewSecurityItem(aesGCMParamVariant, ac.variant),
+	}
+	var results = []SecurityItem{NewSecurityItem(aesGCMResultTag, sealed[tagPos:])}
+
+	return sealed[:tagPos], parameters, results, nil
+}
+
+// Decrypt decrypts the ciphertext, based on the initialization vector
+// parameter and the authentication tag result.
+func (ac *AESGCMContext) Decrypt(ciphertext, aad []byte, parameters, results []SecurityItem) ([]byte, error) {
+	if value, ok := findSecurityItem(parameters, aesGCMParamVariant); ok && value != ac.variant {
+		return nil, newBundleError(fmt.Sprintf("AESGCMContext: AES variant %v mismatches", value))
+	}
+
+	ivValue, _ := findSecurityItem(parameters, aesGCMParamIV)
+	iv, ok := ivValue.([]byte)
+	if !ok || len(iv) != ac.aead.NonceSize() {
+		return nil, newBundleError("AESGCMContext: No valid initialization vector is present")
+	}
+
+	tagValue, _ := findSecurityItem(results, aesGCMResultTag)
+	tag, ok := tagValue.([]byte)
+	if !ok {
+		return nil, newBundleError("AESGCMContext: No authentication tag is present")
+	}
+
+	var sealed = make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(append(sealed, ciphertext...), tag...)
+
+	plaintext, err := ac.aead.Open(nil, iv, sealed, aad)
+	if err != nil {
+		return nil, newBundleError(fmt.Sprintf("AESGCMContext: %v", err))
+	}
+
+	return plaintext, nil
+}
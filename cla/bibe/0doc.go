@@ -0,0 +1,10 @@
+// Package bibe provides a convergence layer for Bundle-in-Bundle Encapsulation
+// (BIBE), as defined in draft-ietf-dtn-bibect-02.
+//
+// BIBE tunnels bundles across DTN segments by encapsulating each bundle as an
+// administrative record into another bundle, which is addressed to a remote
+// BIBE endpoint. The BIBE type implements the ConvergenceSender interface of
+// the parent cla package and passes the encapsulating bundles to the Core.
+// The remote node's Core decapsulates them and processes the inner bundles as
+// received ones. Thus, there is no matching ConvergenceReceiver.
+package bibe
@@ -0,0 +1,122 @@
+package bibe
+
+import (
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/core"
+)
+
+// BIBE is a ConvergenceSender, encapsulating each sent bundle into a bundle
+// from the local endpoint to the remote BIBE endpoint. Those encapsulating
+// bundles are transmitted by the Core.
+//
+// Because the Core might pass an encapsulating bundle back to this BIBE, e.g.,
+// for epidemic routing, bundles encapsulated by this node are not encapsulated
+// again. Thus, the peer's endpoint ID should differ from the remote endpoint,
+// whose bundles must be forwarded through other CLAs.
+type BIBE struct {
+	c *core.Core
+
+	local  bundle.EndpointID
+	remote bundle.EndpointID
+	peer   bundle.EndpointID
+
+	permanent bool
+}
+
+// NewBIBE creates a new BIBE for the Core. Encapsulating bundles are sent from
+// the local endpoint, which must be an endpoint of this node, to the remote
+// BIBE endpoint. The peer is the endpoint ID assigned to this CLA's peer. The
+// permanent flag indicates if this BIBE should never be removed from the core.
+func NewBIBE(c *core.Core, local, remote, peer bundle.EndpointID, permanent bool) *BIBE {
+	return &BIBE{
+		c:         c,
+		local:     local,
+		remote:    remote,
+		peer:      peer,
+		permanent: permanent,
+	}
+}
+
+// Start starts this BIBE. Because there is no connection to establish, this
+// never fails.
+func (b *BIBE) Start() (error, bool) {
+	return nil, true
+}
+
+// isEncapsulation checks if the bundle was encapsulated by this node.
+func (b *BIBE) isEncapsulation(bndl bundle.Bundle) bool {
+	if !bndl.IsAdministrativeRecord() || bndl.PrimaryBlock.SourceNode != b.local {
+		return false
+	}
+
+	// Only the administrative record's head is read, as an encapsulating
+	// bundle's payload might be a large file. The record is a CBOR array of two
+	// items, starting with its type code.
+	payload, _, err := bndl.PayloadReader()
+	if err != nil {
+		return false
+	}
+	defer payload.Close()
+
+	var head [2]byte
+	if _, err := io.ReadFull(payload, head[:]); err != nil {
+		return false
+	}
+
+	return head[0] == 0x82 && core.AdministrativeRecordTypeCode(head[1]) == core.BIBEProtocolDataUnitTypeCode
+}
+
+// Accepts returns false for bundles encapsulated by this node. Thus, the Core
+// does not pass those to this BIBE, as defined in cla.ConvergenceFilter.
+func (b *BIBE) Accepts(bndl bundle.Bundle) bool {
+	return !b.isEncapsulation(bndl)
+}
+
+// Send encapsulates a bundle and passes the encapsulating bundle to the Core.
+// An error is returned for a bundle encapsulated by this node.
+func (b *BIBE) Send(bndl bundle.Bundle) error {
+	if b.isEncapsulation(bndl) {
+		return fmt.Errorf("BIBE %v does not encapsulate bundle %v again", b, bndl)
+	}
+
+	outer, err := core.NewBIBEBundle(bndl, b.local, b.remote)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"cla":          b,
+		"bundle":       outer,
+		"encapsulated": bndl,
+	}).Debug("BIBE encapsulated bundle")
+
+	return b.c.SendBundle(outer)
+}
+
+// Close closes this BIBE. Because there is no connection, nothing happens.
+func (b *BIBE) Close() {}
+
+// GetPeerEndpointID returns the endpoint ID assigned to this CLA's peer.
+func (b *BIBE) GetPeerEndpointID() bundle.EndpointID {
+	return b.peer
+}
+
+// Address should return a unique address string to both identify this
+// ConvergenceSender and ensure it will not opened twice.
+func (b *BIBE) Address() string {
+	return fmt.Sprintf("bibe://%v", b.remote)
+}
+
+// IsPermanent returns true, if this CLA should not be removed after failures.
+func (b *BIBE) IsPermanent() bool {
+	return b.permanent
+}
+
+func (b *BIBE) String() string {
+	return b.Address()
+}
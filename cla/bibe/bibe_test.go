@@ -0,0 +1,93 @@
+package bibe
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/core"
+)
+
+// agent is a dummy ApplicationAgent, forwarding delivered bundles to a channel.
+type agent struct {
+	eid     bundle.EndpointID
+	bundles chan bundle.Bundle
+}
+
+func (a agent) EndpointID() bundle.EndpointID { return a.eid }
+
+func (a agent) Deliver(bndl *bundle.Bundle) error {
+	a.bundles <- *bndl
+	return nil
+}
+
+func TestBIBE(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := core.NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// No agent is registered for the node ID, which is the encapsulating
+	// bundles' source and destination.
+	var nodeId = bundle.MustNewEndpointID("dtn://a/")
+	c.SetNodeId(nodeId)
+
+	var app = agent{bundle.MustNewEndpointID("dtn://a/app"), make(chan bundle.Bundle, 10)}
+	c.RegisterApplicationAgent(app)
+
+	// This BIBE tunnels bundles back to its own node.
+	var b = NewBIBE(c, nodeId, nodeId, bundle.MustNewEndpointID("dtn://a/bibe"), false)
+
+	inner, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(bundle.MustNotFragmented,
+			app.eid, bundle.MustNewEndpointID("dtn://z/"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.Accepts(inner) {
+		t.Fatalf("BIBE does not accept bundle %v", inner)
+	} else if err := b.Send(inner); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case bndl := <-app.bundles:
+		if bndl.ID() != inner.ID() {
+			t.Fatalf("Decapsulated bundle %v differs from %v", bndl, inner)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Decapsulated bundle was not delivered")
+	}
+
+	// An encapsulation of this node is neither accepted nor sent again.
+	outer, err := core.NewBIBEBundle(inner, nodeId, nodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.isEncapsulation(outer) {
+		t.Fatalf("Encapsulating bundle is no encapsulation: %v", outer)
+	} else if b.Accepts(outer) {
+		t.Fatalf("BIBE accepts its own encapsulation")
+	} else if err := b.Send(outer); err == nil {
+		t.Fatalf("Sending its own encapsulation did not error")
+	}
+}
@@ -77,3 +77,20 @@ type ConvergenceSender interface {
 	// if it's known. Otherwise the zero endpoint will be returned.
 	GetPeerEndpointID() bundle.EndpointID
 }
+
+// ConvergenceMaxSize is an optional interface for a ConvergenceSender whose
+// transmissions are limited in size. The core fragments bundles exceeding
+// this size before sending them, if their flags allow fragmentation.
+type ConvergenceMaxSize interface {
+	// MaxBundleSize returns the maximum size of a CBOR serialized bundle, which
+	// might be transmitted by this CLA.
+	MaxBundleSize() uint
+}
+
+// ConvergenceFilter is an optional interface for a ConvergenceSender which
+// must not send some bundles. The core does not pass those bundles to this
+// CLA and does not count them as sent.
+type ConvergenceFilter interface {
+	// Accepts returns false if this CLA must not send the bundle.
+	Accepts(bndl bundle.Bundle) bool
+}
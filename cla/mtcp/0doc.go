@@ -0,0 +1,12 @@
+// Package mtcp provides a library for the Minimal TCP Convergence-Layer
+// Protocol as defined in draft-ietf-dtn-mtcpcl-01.txt, as used by other
+// implementations for interoperability.
+//
+// Each bundle is serialized into CBOR and transmitted over TCP as a CBOR byte
+// string. In contrast to STCP, there is no surrounding array and no separate
+// length field.
+//
+// Because of the unidirectional design of MTCP, both MTCPServer and MTCPClient
+// exists. The MTCPServer implements the ConvergenceReceiver and the MTCPClient
+// the ConvergenceSender interfaces defined in the parent cla package.
+package mtcp
@@ -0,0 +1,50 @@
+package mtcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// cborMajorBytes is the CBOR major type of a byte string.
+const cborMajorBytes = 2
+
+// readByteStringHead reads the head of a definite-length CBOR byte string and
+// returns its length. At the stream's end, io.EOF is returned.
+func readByteStringHead(r io.ByteReader) (uint64, error) {
+	initial, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if initial>>5 != cborMajorBytes {
+		return 0, fmt.Errorf("expected a CBOR byte string, initial byte 0x%02x", initial)
+	}
+
+	var n int
+	switch info := initial & 0x1f; {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	default:
+		return 0, fmt.Errorf("unsupported CBOR byte string length, initial byte 0x%02x", initial)
+	}
+
+	var arg [8]byte
+	for i := 8 - n; i < 8; i++ {
+		if arg[i], err = r.ReadByte(); err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	return binary.BigEndian.Uint64(arg[:]), nil
+}
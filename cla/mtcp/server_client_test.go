@@ -0,0 +1,214 @@
+package mtcp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/ugorji/go/codec"
+)
+
+func getRandomPort(t *testing.T) int {
+	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+	}
+
+	l, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func createBundle(t *testing.T) bundle.Bundle {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeEpoch, 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewBundleAgeBlock(1, bundle.DeleteBundle, 0),
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bndl
+}
+
+func TestMTCPServerClient(t *testing.T) {
+	const (
+		clients  = 10
+		packages = 100
+	)
+
+	var port = getRandomPort(t)
+	var bndl = createBundle(t)
+
+	serv := NewMTCPServer(
+		fmt.Sprintf(":%d", port), bundle.MustNewEndpointID("dtn:mtcpcla"), false)
+	if err, _ := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(clients)
+
+	for c := 0; c < clients; c++ {
+		go func() {
+			defer wg.Done()
+
+			client := NewAnonymousMTCPClient(fmt.Sprintf("localhost:%d", port), false)
+			if err, _ := client.Start(); err != nil {
+				t.Error(err)
+				return
+			}
+			defer client.Close()
+
+			for i := 0; i < packages; i++ {
+				if err := client.Send(bndl); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < clients*packages; i++ {
+		select {
+		case recBndl := <-serv.Channel():
+			if !reflect.DeepEqual(recBndl.Bundle, bndl) {
+				t.Fatalf("Received bundle differs: %v, %v", recBndl.Bundle, bndl)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("Server timed out after %d bundles", i)
+		}
+	}
+
+	wg.Wait()
+}
+
+func TestMTCPFraming(t *testing.T) {
+	var port = getRandomPort(t)
+	var bndl = createBundle(t)
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client := NewMTCPClient(fmt.Sprintf("localhost:%d", port), bundle.MustNewEndpointID("dtn:mtcpcla"), false)
+	if err, _ := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := client.Send(bndl); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each bundle must be framed as a plain CBOR byte string.
+	var data interface{}
+	if err := codec.NewDecoder(conn, new(codec.CborHandle)).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	if encBndl, ok := data.([]byte); !ok {
+		t.Fatalf("Received %T instead of a CBOR byte string", data)
+	} else if !reflect.DeepEqual(encBndl, bndl.ToCbor()) {
+		t.Fatalf("Received byte string differs from the bundle's CBOR")
+	}
+}
+
+func TestMTCPFilePayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := bundle.SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.SetPayloadSpool("", 0)
+
+	var payload = make([]byte, 1<<20)
+	rand.Read(payload)
+
+	var path = filepath.Join(dir, "upload")
+	if err := ioutil.WriteFile(path, payload, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fp, err := bundle.NewFilePayload(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bndl = createBundle(t)
+	bndl.CanonicalBlocks[1] = bundle.NewFilePayloadBlock(0, fp)
+	bndl.SetCRCType(bundle.CRC32)
+
+	var port = getRandomPort(t)
+
+	serv := NewMTCPServer(
+		fmt.Sprintf(":%d", port), bundle.MustNewEndpointID("dtn:mtcpcla"), false)
+	if err, _ := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	client := NewAnonymousMTCPClient(fmt.Sprintf("localhost:%d", port), false)
+	if err, _ := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Send(bndl); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case recBndl := <-serv.Channel():
+		payloadBlock, _ := recBndl.Bundle.PayloadBlock()
+		recFp, ok := payloadBlock.Data.(bundle.FilePayload)
+		if !ok {
+			t.Fatalf("Received payload is no FilePayload: %T", payloadBlock.Data)
+		} else if recFp.Path == fp.Path {
+			t.Fatalf("Received payload references the sent file")
+		}
+
+		if recPayload, err := recBndl.Bundle.PayloadData(); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(recPayload, payload) {
+			t.Fatalf("Received payload differs")
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Server timed out")
+	}
+}
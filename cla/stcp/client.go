@@ -1,13 +1,13 @@
 package stcp
 
 import (
+	"bufio"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/geistesk/dtn7/bundle"
-	"github.com/ugorji/go/codec"
 )
 
 // STCPClient is an implementation of a Simple TCP Convergence-Layer client
@@ -61,9 +61,12 @@ func (client *STCPClient) Send(bndl bundle.Bundle) (err error) {
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 
-	var enc = codec.NewEncoder(client.conn, new(codec.CborHandle))
-	err = enc.Encode(newDataUnit(bndl))
+	var w = bufio.NewWriter(client.conn)
+	if err = writeDataUnit(w, bndl); err != nil {
+		return
+	}
 
+	err = w.Flush()
 	return
 }
 
@@ -1,7 +1,10 @@
 package stcp
 
 import (
+	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
 
 	"github.com/geistesk/dtn7/bundle"
 )
@@ -15,13 +18,55 @@ type DataUnit struct {
 	EncBundle []byte
 }
 
-// newDataUnit creates a STCP Data Unit (SPDU) based on a given bundle.
-func newDataUnit(bndl bundle.Bundle) DataUnit {
-	encBundle := bndl.ToCbor()
+// writeDataUnit writes a STCP Data Unit (SPDU) of the given bundle. The bundle
+// is streamed by an Encoder, so a payload file is not read into memory.
+func writeDataUnit(w io.Writer, bndl bundle.Bundle) error {
+	var size = bndl.EncodedSize()
 
-	return DataUnit{
-		Length:    uint(len(encBundle)),
-		EncBundle: encBundle,
+	// The SPDU is a CBOR array of the length and the bundle's byte string.
+	var head = []byte{0x82}
+	head = append(head, cborHead(cborMajorUint, size)...)
+	head = append(head, cborHead(cborMajorBytes, size)...)
+
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	return bundle.NewEncoder(w).Encode(bndl)
+}
+
+const (
+	cborMajorUint  = 0
+	cborMajorBytes = 2
+)
+
+// cborHead creates the head of a CBOR data item of the major type with the
+// argument, as defined in RFC 7049, section 2.
+func cborHead(major byte, arg uint64) []byte {
+	var initial = major << 5
+
+	switch {
+	case arg < 24:
+		return []byte{initial | byte(arg)}
+
+	case arg <= math.MaxUint8:
+		return []byte{initial | 24, byte(arg)}
+
+	case arg <= math.MaxUint16:
+		var head = []byte{initial | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		return head
+
+	case arg <= math.MaxUint32:
+		var head = []byte{initial | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		return head
+
+	default:
+		var head = make([]byte, 9)
+		head[0] = initial | 27
+		binary.BigEndian.PutUint64(head[1:], arg)
+		return head
 	}
 }
 
@@ -0,0 +1,13 @@
+// Package tcpcl provides a library for the Delay-Tolerant Networking TCP
+// Convergence Layer Protocol Version 4, draft-ietf-dtn-tcpclv4-10.
+//
+// A TCPCL session is bidirectional. The TCPCLClient actively establishes a
+// session and implements both the ConvergenceReceiver and ConvergenceSender
+// interfaces of the parent cla package. The TCPCLServer accepts incoming
+// sessions and implements the ConvergenceReceiver interface.
+//
+// Transferred bundles are split into XFER_SEGMENT messages, which must be
+// acknowledged by the peer's XFER_ACK messages. Thus, in contrast to STCP, a
+// successful Send is only reported after the peer has acknowledged the whole
+// bundle.
+package tcpcl
@@ -0,0 +1,750 @@
+package tcpcl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+const (
+	// keepaliveInterval is the proposed keepalive interval in seconds.
+	keepaliveInterval uint16 = 30
+
+	// segmentMru is the maximum size of a received segment's data.
+	segmentMru uint64 = 1 << 16
+
+	// transferMru is the maximum size of a received transfer's data, if the
+	// payload spool is configured and large transfers are written into a file.
+	transferMru uint64 = 1 << 32
+
+	// memoryTransferMru is the maximum size of a received transfer's data
+	// without a payload spool, as such a transfer is held in memory.
+	memoryTransferMru uint64 = 1 << 26
+
+	// handshakeTimeout limits the contact header and SESS_INIT exchange.
+	handshakeTimeout = 5 * time.Second
+
+	// writeTimeout limits writing a single message.
+	writeTimeout = 10 * time.Second
+
+	// ackTimeout limits waiting on the final XFER_ACK of a transfer.
+	ackTimeout = 30 * time.Second
+
+	// terminationTimeout limits waiting on a SESS_TERM reply.
+	terminationTimeout = 2 * time.Second
+)
+
+// TCPCLClient is an implementation of a TCPCLv4 session. It might be created
+// actively to connect to a TCPCL server or passively by a TCPCLServer for an
+// incoming connection. Because TCPCL sessions are bidirectional, a TCPCLClient
+// is both a ConvergenceSender and a ConvergenceReceiver.
+type TCPCLClient struct {
+	address        string
+	endpointID     bundle.EndpointID
+	peerEndpointID bundle.EndpointID
+	permanent      bool
+	active         bool
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// Negotiated session parameters
+	keepalive       uint16
+	transferMru     uint64
+	peerSegmentMru  uint64
+	peerTransferMru uint64
+
+	// Outgoing transfers, serialized by sendMutex
+	sendMutex  sync.Mutex
+	transferId uint64
+	ackChan    chan Message
+
+	// Incoming transfers, only accessed by the handler. A transfer exceeding
+	// the payload spool's threshold is written into inFile instead of inData.
+	// inActive is false after the transfer was refused or completed, until the
+	// next SegmentStart.
+	inTransferId uint64
+	inActive     bool
+	inData       bytes.Buffer
+	inFile       *os.File
+	inLen        uint64
+
+	writeMutex sync.Mutex
+	lastWrite  time.Time
+
+	reportChan chan cla.RecBundle
+	deliveryWg sync.WaitGroup
+
+	stopMutex   sync.Mutex
+	stopSyn     chan struct{}
+	handlerDone chan struct{}
+}
+
+// NewTCPCLClient creates a new TCPCLClient, connecting to the given address.
+// The endpointID is this node's ID, announced within the session. The peer is
+// the expected endpoint ID of the remote node; it will be replaced by the
+// announced one after establishing the session. The permanent flag indicates
+// if this TCPCLClient should never be removed from the core.
+func NewTCPCLClient(address string, endpointID, peer bundle.EndpointID, permanent bool) *TCPCLClient {
+	return &TCPCLClient{
+		address:        address,
+		endpointID:     endpointID,
+		peerEndpointID: peer,
+		permanent:      permanent,
+		active:         true,
+	}
+}
+
+// NewAnonymousTCPCLClient creates a new TCPCLClient, connecting to the given
+// address, without knowing the peer's endpoint ID in advance. The permanent
+// flag indicates if this TCPCLClient should never be removed from the core.
+func NewAnonymousTCPCLClient(address string, endpointID bundle.EndpointID, permanent bool) *TCPCLClient {
+	return NewTCPCLClient(address, endpointID, bundle.DtnNone(), permanent)
+}
+
+// newPassiveTCPCLClient creates a new TCPCLClient for an accepted connection.
+// This is used by the TCPCLServer.
+func newPassiveTCPCLClient(conn net.Conn, endpointID bundle.EndpointID) *TCPCLClient {
+	return &TCPCLClient{
+		address:        conn.RemoteAddr().String(),
+		endpointID:     endpointID,
+		peerEndpointID: bundle.DtnNone(),
+		permanent:      false,
+		active:         false,
+		conn:           conn,
+	}
+}
+
+// Start starts this TCPCLClient's session and might return an error and a
+// boolean indicating if another Start should be tried later. A passive
+// session, created by a TCPCLServer, cannot be restarted.
+func (client *TCPCLClient) Start() (err error, retry bool) {
+	retry = client.active
+
+	if client.active {
+		conn, dialErr := net.DialTimeout("tcp", client.address, time.Second)
+		if dialErr != nil {
+			return dialErr, retry
+		}
+
+		client.conn = conn
+	}
+
+	client.reader = bufio.NewReader(client.conn)
+	client.ackChan = make(chan Message, 16)
+	client.reportChan = make(chan cla.RecBundle)
+	client.stopSyn = make(chan struct{})
+	client.handlerDone = make(chan struct{})
+
+	if err = client.handshake(); err != nil {
+		client.conn.Close()
+		client.stopSyn = nil
+		client.handlerDone = nil
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"cla":       client,
+		"peer":      client.peerEndpointID,
+		"keepalive": client.keepalive,
+	}).Debug("TCPCL session established")
+
+	go client.handler()
+	go client.keepaliveHandler()
+
+	return
+}
+
+// handshake exchanges the contact headers and SESS_INIT messages and
+// negotiates the session's parameters.
+func (client *TCPCLClient) handshake() error {
+	client.conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer client.conn.SetDeadline(time.Time{})
+
+	var ch = NewContactHeader(ContactNoFlags)
+	var peerCh ContactHeader
+
+	if client.active {
+		if err := ch.Marshal(client.conn); err != nil {
+			return err
+		}
+		if err := peerCh.Unmarshal(client.reader); err != nil {
+			return err
+		}
+	} else {
+		if err := peerCh.Unmarshal(client.reader); err != nil {
+			return err
+		}
+		if err := ch.Marshal(client.conn); err != nil {
+			return err
+		}
+	}
+
+	client.transferMru = transferMru
+	if dir, _ := bundle.PayloadSpool(); dir == "" {
+		client.transferMru = memoryTransferMru
+	}
+
+	var si = NewSessInitMessage(
+		keepaliveInterval, segmentMru, client.transferMru, client.endpointID.String())
+	var peerMsg Message
+	var err error
+
+	if client.active {
+		if err = si.Marshal(client.conn); err != nil {
+			return err
+		}
+		if peerMsg, err = ReadMessage(client.reader); err != nil {
+			return err
+		}
+	} else {
+		if peerMsg, err = ReadMessage(client.reader); err != nil {
+			return err
+		}
+		if err = si.Marshal(client.conn); err != nil {
+			return err
+		}
+	}
+
+	peerSi, ok := peerMsg.(*SessInitMessage)
+	if !ok {
+		NewMsgRejectMessage(RejectionUnexpected, peerMsg.Type()).Marshal(client.conn)
+		return fmt.Errorf("Expected SESS_INIT, got %v", peerMsg.Type())
+	}
+
+	if peerSi.SegmentMru == 0 {
+		NewSessTermMessage(TerminationNoFlags, TerminationContactFailure).Marshal(client.conn)
+		return fmt.Errorf("Peer's Segment MRU is zero")
+	}
+
+	peerEndpointID, err := bundle.NewEndpointID(peerSi.NodeId)
+	if err != nil {
+		NewSessTermMessage(TerminationNoFlags, TerminationContactFailure).Marshal(client.conn)
+		return fmt.Errorf("Peer's Node ID %s is invalid: %v", peerSi.NodeId, err)
+	}
+
+	if client.peerEndpointID != bundle.DtnNone() && client.peerEndpointID != peerEndpointID {
+		log.WithFields(log.Fields{
+			"cla":      client,
+			"expected": client.peerEndpointID,
+			"received": peerEndpointID,
+		}).Warn("TCPCL peer announced an unexpected Node ID")
+	}
+	client.peerEndpointID = peerEndpointID
+
+	client.keepalive = keepaliveInterval
+	if peerSi.KeepaliveInterval < client.keepalive {
+		client.keepalive = peerSi.KeepaliveInterval
+	}
+
+	client.peerSegmentMru = peerSi.SegmentMru
+	client.peerTransferMru = peerSi.TransferMru
+
+	return nil
+}
+
+// Send transmits a bundle to this TCPCLClient's peer. The bundle is split into
+// segments and this method blocks until the peer acknowledged the transfer.
+//
+// The bundle is encoded while being sent. Thus, a payload file is streamed
+// segment by segment. Segments are limited to both the peer's and the own
+// Segment MRU.
+func (client *TCPCLClient) Send(bndl bundle.Bundle) error {
+	client.sendMutex.Lock()
+	defer client.sendMutex.Unlock()
+
+	if client.isStopped() {
+		return fmt.Errorf("TCPCLClient.Send: session is terminated")
+	}
+
+	var dataLen = bndl.EncodedSize()
+
+	if dataLen > client.peerTransferMru {
+		return fmt.Errorf("TCPCLClient.Send: bundle's length %d exceeds peer's Transfer MRU %d",
+			dataLen, client.peerTransferMru)
+	}
+
+	var data, dataWriter = io.Pipe()
+	defer data.Close()
+
+	go func() {
+		dataWriter.CloseWithError(bundle.NewEncoder(dataWriter).Encode(bndl))
+	}()
+
+	var segmentLen = client.peerSegmentMru
+	if segmentLen > segmentMru {
+		segmentLen = segmentMru
+	}
+	var segment = make([]byte, segmentLen)
+
+	client.transferId++
+	var transferId = client.transferId
+
+	for offset := uint64(0); offset < dataLen; offset += segmentLen {
+		var flags SegmentFlags
+		if offset == 0 {
+			flags |= SegmentStart
+		}
+
+		var end = offset + segmentLen
+		if end >= dataLen {
+			end = dataLen
+			flags |= SegmentEnd
+		}
+
+		if _, err := io.ReadFull(data, segment[:end-offset]); err != nil {
+			return fmt.Errorf("TCPCLClient.Send: encoding bundle failed, %v", err)
+		}
+
+		var msg = NewXferSegmentMessage(flags, transferId, segment[:end-offset])
+		if err := client.writeMessage(msg); err != nil {
+			return fmt.Errorf("TCPCLClient.Send: %v", err)
+		}
+	}
+
+	var timeout = time.NewTimer(ackTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case msg := <-client.ackChan:
+			switch msg := msg.(type) {
+			case *XferAckMessage:
+				if msg.TransferId == transferId && msg.AckLen == dataLen {
+					return nil
+				}
+
+			case *XferRefuseMessage:
+				if msg.TransferId == transferId {
+					return fmt.Errorf("TCPCLClient.Send: transfer %d was refused, %v",
+						transferId, msg.ReasonCode)
+				}
+			}
+
+		case <-client.stopSyn:
+			return fmt.Errorf("TCPCLClient.Send: session was terminated during transfer")
+
+		case <-timeout.C:
+			return fmt.Errorf("TCPCLClient.Send: transfer %d was not acknowledged", transferId)
+		}
+	}
+}
+
+// writeMessage writes a Message to the connection, thread safe. Because only
+// the Marshal method is required, both Message values and pointers are valid.
+func (client *TCPCLClient) writeMessage(msg interface{ Marshal(io.Writer) error }) error {
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	client.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	client.lastWrite = time.Now()
+
+	return msg.Marshal(client.conn)
+}
+
+// isStopped returns true if the session's termination has begun or if the
+// session was never started.
+func (client *TCPCLClient) isStopped() bool {
+	if client.stopSyn == nil {
+		return true
+	}
+
+	select {
+	case <-client.stopSyn:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop marks this session as terminated. The return value indicates if this
+// call has stopped the session, or if it was already stopped.
+func (client *TCPCLClient) stop() bool {
+	client.stopMutex.Lock()
+	defer client.stopMutex.Unlock()
+
+	if client.isStopped() {
+		return false
+	}
+
+	close(client.stopSyn)
+	return true
+}
+
+// terminate initiates the session's termination by sending a SESS_TERM. The
+// handler waits on the peer's reply afterwards.
+func (client *TCPCLClient) terminate(reason SessTermReason) {
+	if !client.stop() {
+		return
+	}
+
+	if err := client.writeMessage(NewSessTermMessage(TerminationNoFlags, reason)); err != nil {
+		client.conn.Close()
+		return
+	}
+
+	client.stopMutex.Lock()
+	client.conn.SetReadDeadline(time.Now().Add(terminationTimeout))
+	client.stopMutex.Unlock()
+}
+
+// refreshReadDeadline sets the connection's read deadline based on the
+// negotiated keepalive interval.
+func (client *TCPCLClient) refreshReadDeadline() {
+	client.stopMutex.Lock()
+	defer client.stopMutex.Unlock()
+
+	if client.isStopped() {
+		return
+	}
+
+	if client.keepalive == 0 {
+		client.conn.SetReadDeadline(time.Time{})
+	} else {
+		client.conn.SetReadDeadline(
+			time.Now().Add(2 * time.Duration(client.keepalive) * time.Second))
+	}
+}
+
+// handler reads and handles incoming messages until the session ends.
+func (client *TCPCLClient) handler() {
+	defer func() {
+		client.stop()
+		client.conn.Close()
+		client.resetIncoming()
+
+		client.deliveryWg.Wait()
+		close(client.reportChan)
+		close(client.handlerDone)
+	}()
+
+	for {
+		client.refreshReadDeadline()
+
+		msg, err := ReadMessage(client.reader)
+		if err != nil {
+			if client.isStopped() {
+				return
+			}
+
+			if ume, ok := err.(*UnknownMessageError); ok {
+				log.WithFields(log.Fields{
+					"cla":  client,
+					"type": ume.TypeCode,
+				}).Warn("TCPCL peer sent an unknown message type, terminating session")
+
+				client.writeMessage(NewMsgRejectMessage(RejectionTypeUnknown, ume.TypeCode))
+				client.terminate(TerminationUnknown)
+				continue
+			}
+
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.WithFields(log.Fields{
+					"cla": client,
+				}).Info("TCPCL session is idle, terminating session")
+
+				client.terminate(TerminationIdleTimeout)
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"cla":   client,
+				"error": err,
+			}).Warn("Reading TCPCL message failed, closing session")
+
+			return
+		}
+
+		switch msg := msg.(type) {
+		case *XferSegmentMessage:
+			client.handleXferSegment(msg)
+
+		case *XferAckMessage:
+			// Only the final acknowledgement is relevant for the sender.
+			if msg.Flags.Has(SegmentEnd) {
+				client.handleAck(msg)
+			}
+
+		case *XferRefuseMessage:
+			client.handleAck(msg)
+
+		case *KeepaliveMessage:
+			// The read deadline is refreshed for each message.
+
+		case *SessTermMessage:
+			if !msg.IsReply() && client.stop() {
+				log.WithFields(log.Fields{
+					"cla":    client,
+					"reason": msg.ReasonCode,
+				}).Info("TCPCL peer terminated the session")
+
+				client.writeMessage(NewSessTermMessage(TerminationReply, msg.ReasonCode))
+			}
+			return
+
+		case *MsgRejectMessage:
+			log.WithFields(log.Fields{
+				"cla":     client,
+				"message": msg,
+			}).Warn("TCPCL peer rejected a message")
+
+		default:
+			log.WithFields(log.Fields{
+				"cla":     client,
+				"message": msg,
+			}).Warn("TCPCL peer sent an unexpected message")
+
+			client.writeMessage(NewMsgRejectMessage(RejectionUnexpected, msg.Type()))
+		}
+	}
+}
+
+// handleXferSegment handles an incoming XFER_SEGMENT, acknowledges it and
+// delivers a bundle for a completed transfer.
+func (client *TCPCLClient) handleXferSegment(msg *XferSegmentMessage) {
+	if msg.Flags.Has(SegmentStart) {
+		client.inTransferId = msg.TransferId
+		client.inActive = true
+		client.resetIncoming()
+	} else if msg.TransferId == client.inTransferId && !client.inActive {
+		log.WithFields(log.Fields{
+			"cla":     client,
+			"message": msg,
+		}).Debug("Ignoring TCPCL segment of a refused or finished transfer")
+		return
+	} else if msg.TransferId != client.inTransferId {
+		log.WithFields(log.Fields{
+			"cla":     client,
+			"message": msg,
+		}).Warn("TCPCL peer sent a segment for an unknown transfer")
+
+		client.writeMessage(NewXferRefuseMessage(RefusalUnknown, msg.TransferId))
+		return
+	}
+
+	if client.inLen+uint64(len(msg.Data)) > client.transferMru {
+		log.WithFields(log.Fields{
+			"cla":     client,
+			"message": msg,
+		}).Warn("TCPCL peer's transfer exceeds the Transfer MRU")
+
+		client.inActive = false
+		client.resetIncoming()
+		client.writeMessage(NewXferRefuseMessage(RefusalNoResources, msg.TransferId))
+		return
+	}
+
+	if err := client.writeIncoming(msg.Data); err != nil {
+		log.WithFields(log.Fields{
+			"cla":   client,
+			"error": err,
+		}).Warn("Storing TCPCL transfer's segment failed")
+
+		client.inActive = false
+		client.resetIncoming()
+		client.writeMessage(NewXferRefuseMessage(RefusalNoResources, msg.TransferId))
+		return
+	}
+
+	var ack = NewXferAckMessage(msg.Flags, msg.TransferId, client.inLen)
+	if err := client.writeMessage(ack); err != nil {
+		log.WithFields(log.Fields{
+			"cla":   client,
+			"error": err,
+		}).Warn("Sending TCPCL XFER_ACK failed")
+		return
+	}
+
+	if !msg.Flags.Has(SegmentEnd) {
+		return
+	}
+
+	bndl, err := client.decodeIncoming()
+	client.inActive = false
+	client.resetIncoming()
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"cla":   client,
+			"error": err,
+		}).Warn("TCPCL transfer contains an invalid bundle")
+		return
+	}
+
+	// The bundle is delivered asynchronously. Otherwise a blocking receiver
+	// might prevent this handler from reading acknowledgements.
+	client.deliveryWg.Add(1)
+	go func(bndl bundle.Bundle) {
+		defer client.deliveryWg.Done()
+
+		select {
+		case client.reportChan <- cla.NewRecBundle(bndl, client.endpointID):
+		case <-client.stopSyn:
+		}
+	}(bndl)
+}
+
+// handleAck passes a XFER_ACK or XFER_REFUSE to a waiting Send.
+func (client *TCPCLClient) handleAck(msg Message) {
+	select {
+	case client.ackChan <- msg:
+	default:
+		log.WithFields(log.Fields{
+			"cla":     client,
+			"message": msg,
+		}).Debug("Dropping TCPCL acknowledgement, nobody is waiting")
+	}
+}
+
+// keepaliveHandler sends KEEPALIVE messages if the session is idle.
+func (client *TCPCLClient) keepaliveHandler() {
+	if client.keepalive == 0 {
+		return
+	}
+
+	var interval = time.Duration(client.keepalive) * time.Second / 2
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.stopSyn:
+			return
+
+		case <-ticker.C:
+			client.writeMutex.Lock()
+			var idle = time.Since(client.lastWrite) >= interval
+			client.writeMutex.Unlock()
+
+			if !idle {
+				continue
+			}
+
+			if err := client.writeMessage(NewKeepaliveMessage()); err != nil {
+				log.WithFields(log.Fields{
+					"cla":   client,
+					"error": err,
+				}).Warn("Sending TCPCL KEEPALIVE failed")
+			}
+		}
+	}
+}
+
+// Close terminates this TCPCLClient's session.
+func (client *TCPCLClient) Close() {
+	if client.handlerDone == nil {
+		return
+	}
+
+	client.terminate(TerminationUnknown)
+
+	select {
+	case <-client.handlerDone:
+	case <-time.After(terminationTimeout):
+		client.conn.Close()
+		<-client.handlerDone
+	}
+}
+
+// Channel returns a channel of received bundles.
+func (client *TCPCLClient) Channel() chan cla.RecBundle {
+	return client.reportChan
+}
+
+// GetEndpointID returns the endpoint ID assigned to this CLA, which is also
+// announced to the peer.
+func (client *TCPCLClient) GetEndpointID() bundle.EndpointID {
+	return client.endpointID
+}
+
+// GetPeerEndpointID returns the endpoint ID assigned to this CLA's peer,
+// if it's known. Otherwise the zero endpoint will be returned.
+func (client *TCPCLClient) GetPeerEndpointID() bundle.EndpointID {
+	return client.peerEndpointID
+}
+
+// MaxBundleSize returns the peer's Transfer MRU, negotiated at the session's
+// initialization. This implements the cla.ConvergenceMaxSize interface.
+func (client *TCPCLClient) MaxBundleSize() uint {
+	return uint(client.peerTransferMru)
+}
+
+// Address should return a unique address string to both identify this
+// CLA and ensure it will not opened twice.
+func (client *TCPCLClient) Address() string {
+	return fmt.Sprintf("tcpcl://%s", client.address)
+}
+
+// IsPermanent returns true, if this CLA should not be removed after failures.
+func (client *TCPCLClient) IsPermanent() bool {
+	return client.permanent
+}
+
+func (client *TCPCLClient) String() string {
+	return client.Address()
+}
+
+// writeIncoming appends a segment's data to the incoming transfer. If the
+// transfer reaches the payload spool's threshold, it is moved into a file.
+func (client *TCPCLClient) writeIncoming(data []byte) (err error) {
+	if dir, threshold := bundle.PayloadSpool(); client.inFile == nil && dir != "" &&
+		client.inLen+uint64(len(data)) >= threshold {
+		if client.inFile, err = bundle.NewPayloadFile(); err != nil {
+			return
+		}
+
+		if _, err = client.inFile.Write(client.inData.Bytes()); err != nil {
+			return
+		}
+		client.inData.Reset()
+	}
+
+	if client.inFile != nil {
+		_, err = client.inFile.Write(data)
+	} else {
+		_, err = client.inData.Write(data)
+	}
+
+	if err == nil {
+		client.inLen += uint64(len(data))
+	}
+	return
+}
+
+// decodeIncoming decodes the bundle of the completed incoming transfer.
+func (client *TCPCLClient) decodeIncoming() (bndl bundle.Bundle, err error) {
+	if client.inFile == nil {
+		return bundle.NewBundleFromCbor(client.inData.Bytes())
+	}
+
+	if _, err = client.inFile.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+
+	if err = bundle.NewDecoder(client.inFile).Decode(&bndl); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+// resetIncoming discards the incoming transfer's data.
+func (client *TCPCLClient) resetIncoming() {
+	client.inData.Reset()
+	client.inLen = 0
+
+	if client.inFile != nil {
+		client.inFile.Close()
+		os.Remove(client.inFile.Name())
+		client.inFile = nil
+	}
+}
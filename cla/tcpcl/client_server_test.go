@@ -0,0 +1,272 @@
+package tcpcl
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func getRandomPort(t *testing.T) int {
+	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
+	if err != nil {
+		t.Error(err)
+	}
+
+	l, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestTCPCLServerClient(t *testing.T) {
+	// Address
+	port := getRandomPort(t)
+
+	// Bundle
+	const (
+		clients  = 10
+		packages = 100
+	)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeEpoch, 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewBundleAgeBlock(1, bundle.DeleteBundle, 0),
+			// The payload exceeds a single segment
+			bundle.NewPayloadBlock(0, make([]byte, 3*segmentMru)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(clients + 1) // 1 for the server
+
+	// Fatal errors of the goroutines are reported back to the test
+	var errChan = make(chan error, clients+1)
+
+	// Server
+	serv := NewTCPCLServer(
+		fmt.Sprintf(":%d", port), bundle.MustNewEndpointID("dtn:tcpclserv"), false)
+	if err, _ := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var counter sync.Map
+	counter.Store("counter", clients*packages)
+
+	go func() {
+		var chnl = serv.Channel()
+
+		for {
+			select {
+			case b := <-chnl:
+				c, _ := counter.Load("counter")
+				cVal := c.(int) - 1
+				counter.Store("counter", cVal)
+
+				if !reflect.DeepEqual(b.Bundle, bndl) {
+					t.Errorf("Received bundle differs: %v, %v", b, bndl)
+				}
+
+				if cVal == 0 {
+					serv.Close()
+					wg.Done()
+					return
+				}
+
+			case <-time.After(5 * time.Second):
+				errChan <- fmt.Errorf("Server timed out")
+				wg.Done()
+				return
+			}
+		}
+	}()
+
+	// Client
+	for c := 0; c < clients; c++ {
+		go func(c int) {
+			client := NewAnonymousTCPCLClient(
+				fmt.Sprintf("localhost:%d", port),
+				bundle.MustNewEndpointID(fmt.Sprintf("dtn:tcpclclient%d", c)), false)
+			defer wg.Done()
+
+			if err, _ := client.Start(); err != nil {
+				errChan <- err
+				return
+			}
+
+			if peer := client.GetPeerEndpointID(); peer != serv.GetEndpointID() {
+				t.Errorf("Client's peer endpoint is %v instead of %v", peer, serv.GetEndpointID())
+			}
+
+			for i := 0; i < packages; i++ {
+				if err := client.Send(bndl); err != nil {
+					errChan <- err
+					break
+				}
+			}
+
+			client.Close()
+		}(c)
+	}
+
+	wg.Wait()
+
+	close(errChan)
+	for err := range errChan {
+		t.Fatal(err)
+	}
+
+	c, _ := counter.Load("counter")
+	if c.(int) != 0 {
+		t.Fatalf("Counter is not zero: %d", c.(int))
+	}
+}
+
+func TestTCPCLClientBidirectional(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var passiveChan = make(chan *TCPCLClient)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		passive := newPassiveTCPCLClient(conn, bundle.MustNewEndpointID("dtn:passive"))
+		if err, _ := passive.Start(); err != nil {
+			t.Error(err)
+			return
+		}
+
+		passiveChan <- passive
+	}()
+
+	active := NewTCPCLClient(ln.Addr().String(),
+		bundle.MustNewEndpointID("dtn:active"), bundle.MustNewEndpointID("dtn:passive"), false)
+	if err, _ := active.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var passive *TCPCLClient
+	select {
+	case passive = <-passiveChan:
+	case <-time.After(time.Second):
+		t.Fatal("Passive session was not established")
+	}
+
+	if peer := passive.GetPeerEndpointID(); peer != active.GetEndpointID() {
+		t.Fatalf("Passive's peer is %v instead of %v", peer, active.GetEndpointID())
+	}
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeEpoch, 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewBundleAgeBlock(1, bundle.DeleteBundle, 0),
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range []struct{ from, to *TCPCLClient }{
+		{active, passive}, {passive, active}} {
+		var errChan = make(chan error)
+		go func(from *TCPCLClient) { errChan <- from.Send(bndl) }(pair.from)
+
+		select {
+		case b := <-pair.to.Channel():
+			if !reflect.DeepEqual(b.Bundle, bndl) {
+				t.Fatalf("Received bundle differs: %v, %v", b, bndl)
+			}
+
+			if b.Receiver != pair.to.GetEndpointID() {
+				t.Fatalf("Receiver is %v instead of %v", b.Receiver, pair.to.GetEndpointID())
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("Bundle was not received")
+		}
+
+		if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	active.Close()
+
+	select {
+	case _, ok := <-passive.Channel():
+		if ok {
+			t.Fatal("Passive session's channel received a bundle after termination")
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("Passive session was not terminated")
+	}
+
+	if err := passive.Send(bndl); err == nil {
+		t.Fatal("Sending over a terminated session did not error")
+	}
+}
+
+func TestTCPCLClientRefusedTransfer(t *testing.T) {
+	conn, peerConn := net.Pipe()
+	defer conn.Close()
+	defer peerConn.Close()
+
+	client := newPassiveTCPCLClient(conn, bundle.MustNewEndpointID("dtn:passive"))
+	client.transferMru = 4
+
+	var start = NewXferSegmentMessage(SegmentStart, 23, []byte("hello world!"))
+	go client.handleXferSegment(&start)
+
+	peerConn.SetReadDeadline(time.Now().Add(time.Second))
+	if msg, err := ReadMessage(peerConn); err != nil {
+		t.Fatal(err)
+	} else if refuse, ok := msg.(*XferRefuseMessage); !ok || refuse.TransferId != 23 {
+		t.Fatalf("Transfer exceeding the MRU was not refused: %v", msg)
+	}
+
+	// The refused transfer's following segments are neither acknowledged nor
+	// decoded. Any reply would block on the unread pipe.
+	var done = make(chan struct{})
+	go func() {
+		var end = NewXferSegmentMessage(SegmentEnd, 23, []byte("ab"))
+		client.handleXferSegment(&end)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Segment of a refused transfer was answered")
+	}
+
+	if client.inLen != 0 {
+		t.Fatalf("Segment of a refused transfer was stored: %d bytes", client.inLen)
+	}
+}
@@ -0,0 +1,95 @@
+package tcpcl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ContactFlags are single-bit flags used in a ContactHeader.
+type ContactFlags uint8
+
+const (
+	// ContactNoFlags is the default for no set flags.
+	ContactNoFlags ContactFlags = 0x00
+
+	// ContactCanTLS indicates that the sending peer is capable of TLS security.
+	ContactCanTLS ContactFlags = 0x01
+
+	contactReservedFields ContactFlags = 0xFE
+)
+
+func (cf ContactFlags) String() string {
+	switch cf {
+	case ContactNoFlags:
+		return "NONE"
+	case ContactCanTLS:
+		return "CAN_TLS"
+	default:
+		return "INVALID"
+	}
+}
+
+// contactHeaderMagic is the magic "dtn!" string, starting each ContactHeader.
+var contactHeaderMagic = []byte("dtn!")
+
+// tcpclVersion is the supported and sent version of this TCPCL.
+const tcpclVersion uint8 = 4
+
+// ContactHeader appears at the beginning of a TCPCL session, as defined in
+// section 4.2. Both sides send their ContactHeader after establishing the
+// TCP connection.
+type ContactHeader struct {
+	Flags ContactFlags
+}
+
+// NewContactHeader creates a new ContactHeader with the given ContactFlags.
+func NewContactHeader(flags ContactFlags) ContactHeader {
+	return ContactHeader{
+		Flags: flags,
+	}
+}
+
+func (ch ContactHeader) String() string {
+	return fmt.Sprintf("ContactHeader(Version=%d, Flags=%v)", tcpclVersion, ch.Flags)
+}
+
+// Marshal writes this ContactHeader's binary representation to the writer.
+func (ch ContactHeader) Marshal(w io.Writer) error {
+	var data = make([]byte, 0, 6)
+	data = append(data, contactHeaderMagic...)
+	data = append(data, tcpclVersion, uint8(ch.Flags))
+
+	if n, err := w.Write(data); err != nil {
+		return err
+	} else if n != len(data) {
+		return fmt.Errorf("ContactHeader: wrote %d instead of %d bytes", n, len(data))
+	}
+
+	return nil
+}
+
+// Unmarshal reads a ContactHeader's binary representation from the reader
+// and sets this ContactHeader's fields.
+func (ch *ContactHeader) Unmarshal(r io.Reader) error {
+	var data = make([]byte, 6)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(data[:4], contactHeaderMagic) {
+		return fmt.Errorf("ContactHeader: magic mismatch, %x", data[:4])
+	}
+
+	if version := data[4]; version != tcpclVersion {
+		return fmt.Errorf("ContactHeader: unsupported version %d", version)
+	}
+
+	var flags = ContactFlags(data[5])
+	if flags&contactReservedFields != 0 {
+		return fmt.Errorf("ContactHeader: flags contain reserved bits, %x", uint8(flags))
+	}
+
+	ch.Flags = flags
+	return nil
+}
@@ -0,0 +1,56 @@
+package tcpcl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestContactHeaderMarshal(t *testing.T) {
+	tests := []struct {
+		ch   ContactHeader
+		data []byte
+	}{
+		{ContactHeader{Flags: ContactNoFlags}, []byte{0x64, 0x74, 0x6E, 0x21, 0x04, 0x00}},
+		{ContactHeader{Flags: ContactCanTLS}, []byte{0x64, 0x74, 0x6E, 0x21, 0x04, 0x01}},
+	}
+
+	for _, test := range tests {
+		var buf = new(bytes.Buffer)
+		if err := test.ch.Marshal(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if data := buf.Bytes(); !bytes.Equal(data, test.data) {
+			t.Fatalf("Data does not match, expected %x and got %x", test.data, data)
+		}
+	}
+}
+
+func TestContactHeaderUnmarshal(t *testing.T) {
+	tests := []struct {
+		valid bool
+		data  []byte
+		ch    ContactHeader
+	}{
+		{true, []byte{0x64, 0x74, 0x6E, 0x21, 0x04, 0x00}, ContactHeader{Flags: ContactNoFlags}},
+		{true, []byte{0x64, 0x74, 0x6E, 0x21, 0x04, 0x01}, ContactHeader{Flags: ContactCanTLS}},
+		{false, []byte{0x64, 0x74, 0x6E, 0x21, 0x04, 0x02}, ContactHeader{}},
+		{false, []byte{0x64, 0x74, 0x6E, 0x21, 0x03, 0x00}, ContactHeader{}},
+		{false, []byte{0x64, 0x74, 0x6E, 0x20, 0x04, 0x00}, ContactHeader{}},
+		{false, []byte{0x64, 0x74, 0x6E, 0x21, 0x04}, ContactHeader{}},
+	}
+
+	for _, test := range tests {
+		var ch ContactHeader
+
+		if err := ch.Unmarshal(bytes.NewBuffer(test.data)); (err == nil) != test.valid {
+			t.Fatalf("Error state was not expected; valid := %t, got := %v", test.valid, err)
+		} else if !test.valid {
+			continue
+		}
+
+		if ch != test.ch {
+			t.Fatalf("ContactHeader does not match, expected %v and got %v", test.ch, ch)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tcpcl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessageType is the one-octet message type code, prefixing each message
+// after the contact header exchange, as defined in section 4.1.
+type MessageType uint8
+
+const (
+	// XferSegmentType is the XFER_SEGMENT message type code.
+	XferSegmentType MessageType = 0x01
+
+	// XferAckType is the XFER_ACK message type code.
+	XferAckType MessageType = 0x02
+
+	// XferRefuseType is the XFER_REFUSE message type code.
+	XferRefuseType MessageType = 0x03
+
+	// KeepaliveType is the KEEPALIVE message type code.
+	KeepaliveType MessageType = 0x04
+
+	// SessTermType is the SESS_TERM message type code.
+	SessTermType MessageType = 0x05
+
+	// MsgRejectType is the MSG_REJECT message type code.
+	MsgRejectType MessageType = 0x06
+
+	// SessInitType is the SESS_INIT message type code.
+	SessInitType MessageType = 0x07
+)
+
+func (mt MessageType) String() string {
+	switch mt {
+	case XferSegmentType:
+		return "XFER_SEGMENT"
+	case XferAckType:
+		return "XFER_ACK"
+	case XferRefuseType:
+		return "XFER_REFUSE"
+	case KeepaliveType:
+		return "KEEPALIVE"
+	case SessTermType:
+		return "SESS_TERM"
+	case MsgRejectType:
+		return "MSG_REJECT"
+	case SessInitType:
+		return "SESS_INIT"
+	default:
+		return "unknown"
+	}
+}
+
+// Message describes all kinds of TCPCL messages, which have their message
+// type code in common.
+type Message interface {
+	// Type returns this Message's type code.
+	Type() MessageType
+
+	// Marshal writes this Message's binary representation, including its
+	// leading message type code, to the writer.
+	Marshal(w io.Writer) error
+
+	// Unmarshal reads this Message's binary representation from the reader.
+	// The leading message type code must have been consumed before.
+	Unmarshal(r io.Reader) error
+}
+
+// NewMessage creates a new, empty Message for the given MessageType. An error
+// is returned for an unknown type code.
+func NewMessage(typeCode MessageType) (Message, error) {
+	switch typeCode {
+	case XferSegmentType:
+		return &XferSegmentMessage{}, nil
+	case XferAckType:
+		return &XferAckMessage{}, nil
+	case XferRefuseType:
+		return &XferRefuseMessage{}, nil
+	case KeepaliveType:
+		return &KeepaliveMessage{}, nil
+	case SessTermType:
+		return &SessTermMessage{}, nil
+	case MsgRejectType:
+		return &MsgRejectMessage{}, nil
+	case SessInitType:
+		return &SessInitMessage{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown message type code 0x%x", uint8(typeCode))
+	}
+}
+
+// ReadMessage reads the next Message from the reader. If the message type
+// code is unknown, an *UnknownMessageError is returned.
+func ReadMessage(r io.Reader) (Message, error) {
+	var typeCode MessageType
+	if err := binary.Read(r, binary.BigEndian, &typeCode); err != nil {
+		return nil, err
+	}
+
+	msg, err := NewMessage(typeCode)
+	if err != nil {
+		return nil, &UnknownMessageError{typeCode}
+	}
+
+	if err := msg.Unmarshal(r); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// UnknownMessageError is returned by ReadMessage for an unknown message type
+// code. The peer should be informed by a MSG_REJECT.
+type UnknownMessageError struct {
+	TypeCode MessageType
+}
+
+func (ume *UnknownMessageError) Error() string {
+	return fmt.Sprintf("Unknown message type code 0x%x", uint8(ume.TypeCode))
+}
+
+// writeFields writes the message type code and the given fields in network
+// byte order to the writer. Byte slices are written as they are.
+func writeFields(w io.Writer, typeCode MessageType, fields ...interface{}) error {
+	var bw = bufio.NewWriter(w)
+
+	if err := binary.Write(bw, binary.BigEndian, typeCode); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		var err error
+		if b, ok := field.([]byte); ok {
+			_, err = bw.Write(b)
+		} else {
+			err = binary.Write(bw, binary.BigEndian, field)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// readFields reads the given pointers of fixed-size fields in network byte
+// order from the reader.
+func readFields(r io.Reader, fields ...interface{}) error {
+	for _, field := range fields {
+		if err := binary.Read(r, binary.BigEndian, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readBytes reads exactly n bytes from the reader. A length exceeding max is
+// rejected before reading anything. Furthermore, the buffer grows while
+// reading, so a forged length field does not result in a huge allocation.
+func readBytes(r io.Reader, n, max uint64) ([]byte, error) {
+	if n > max || n > math.MaxInt64 {
+		return nil, fmt.Errorf("Length of %d bytes exceeds the maximum of %d bytes", n, max)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
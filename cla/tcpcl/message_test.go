@@ -0,0 +1,114 @@
+package tcpcl
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestMessageMarshalUnmarshal(t *testing.T) {
+	tests := []Message{
+		&SessInitMessage{KeepaliveInterval: 30, SegmentMru: 1 << 16, TransferMru: 1 << 32, NodeId: "dtn:foo"},
+		&SessInitMessage{KeepaliveInterval: 0, SegmentMru: 1, TransferMru: 1, NodeId: ""},
+		&XferSegmentMessage{Flags: SegmentStart | SegmentEnd, TransferId: 1, Data: []byte("hello world")},
+		&XferSegmentMessage{Flags: SegmentStart, TransferId: 2, Data: []byte("hello ")},
+		&XferSegmentMessage{Flags: SegmentEnd, TransferId: 2, Data: []byte("world")},
+		&XferAckMessage{Flags: SegmentEnd, TransferId: 23, AckLen: 42},
+		&XferRefuseMessage{ReasonCode: RefusalNoResources, TransferId: 23},
+		&KeepaliveMessage{},
+		&SessTermMessage{Flags: TerminationReply, ReasonCode: TerminationIdleTimeout},
+		&MsgRejectMessage{ReasonCode: RejectionUnexpected, MsgHeader: SessInitType},
+	}
+
+	for _, msg := range tests {
+		var buf = new(bytes.Buffer)
+		if err := msg.Marshal(buf); err != nil {
+			t.Fatalf("Marshalling %v failed: %v", msg, err)
+		}
+
+		if typeCode := MessageType(buf.Bytes()[0]); typeCode != msg.Type() {
+			t.Fatalf("Message type code mismatches: %v != %v", typeCode, msg.Type())
+		}
+
+		msg2, err := ReadMessage(buf)
+		if err != nil {
+			t.Fatalf("Unmarshalling %v failed: %v", msg, err)
+		}
+
+		if !reflect.DeepEqual(msg, msg2) {
+			t.Fatalf("Message differs: %v became %v", msg, msg2)
+		}
+
+		if buf.Len() != 0 {
+			t.Fatalf("Unmarshalling %v left %d bytes", msg, buf.Len())
+		}
+	}
+}
+
+func TestXferSegmentMessageExtensionItems(t *testing.T) {
+	// XFER_SEGMENT, START|END, Transfer ID 1, 3 bytes of extension items,
+	// 2 bytes of data
+	var data = []byte{
+		0x01, 0x03,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x03, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x23, 0x42,
+	}
+
+	msg, err := ReadMessage(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected = &XferSegmentMessage{
+		Flags:      SegmentStart | SegmentEnd,
+		TransferId: 1,
+		Data:       []byte{0x23, 0x42},
+	}
+
+	if !reflect.DeepEqual(msg, expected) {
+		t.Fatalf("Message differs: %v instead of %v", msg, expected)
+	}
+}
+
+func TestReadMessageUnknown(t *testing.T) {
+	_, err := ReadMessage(bytes.NewBuffer([]byte{0x23}))
+	if err == nil {
+		t.Fatal("Reading an unknown message type did not error")
+	}
+
+	if ume, ok := err.(*UnknownMessageError); !ok {
+		t.Fatalf("Error is not an UnknownMessageError: %v", err)
+	} else if ume.TypeCode != 0x23 {
+		t.Fatalf("UnknownMessageError has wrong type code: %x", ume.TypeCode)
+	}
+}
+
+func TestXferSegmentMessageOversized(t *testing.T) {
+	// XFER_SEGMENT, END, Transfer ID 1, data lengths exceeding the Segment MRU
+	// and the range of an int64, followed by two bytes of data
+	for _, dataLen := range [][]byte{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01},
+		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+	} {
+		var data = []byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
+		data = append(data, dataLen...)
+		data = append(data, 0x23, 0x42)
+
+		if msg, err := ReadMessage(bytes.NewBuffer(data)); err == nil {
+			t.Fatalf("Reading an oversized segment did not error: %v", msg)
+		}
+	}
+
+	// A truncated segment must not be read as a shorter one
+	var data = []byte{
+		0x01, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x23, 0x42,
+	}
+
+	if _, err := ReadMessage(bytes.NewBuffer(data)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Reading a truncated segment resulted in %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package tcpcl
+
+import "io"
+
+// KeepaliveMessage is the KEEPALIVE message, as defined in section 5.1.1. It
+// has no further fields next to its message type code.
+type KeepaliveMessage struct{}
+
+// NewKeepaliveMessage creates a new KeepaliveMessage.
+func NewKeepaliveMessage() KeepaliveMessage {
+	return KeepaliveMessage{}
+}
+
+func (_ KeepaliveMessage) String() string {
+	return "KEEPALIVE"
+}
+
+// Type returns KeepaliveType.
+func (_ KeepaliveMessage) Type() MessageType {
+	return KeepaliveType
+}
+
+// Marshal writes this KeepaliveMessage's binary representation to the writer.
+func (_ KeepaliveMessage) Marshal(w io.Writer) error {
+	return writeFields(w, KeepaliveType)
+}
+
+// Unmarshal reads a KeepaliveMessage's binary representation from the reader,
+// which is a no-op.
+func (_ *KeepaliveMessage) Unmarshal(_ io.Reader) error {
+	return nil
+}
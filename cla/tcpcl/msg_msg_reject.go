@@ -0,0 +1,68 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgRejectReason is the one-octet rejection reason code of a MSG_REJECT.
+type MsgRejectReason uint8
+
+const (
+	// RejectionTypeUnknown indicates an unknown message type code.
+	RejectionTypeUnknown MsgRejectReason = 0x01
+
+	// RejectionUnsupported indicates an unsupported message.
+	RejectionUnsupported MsgRejectReason = 0x02
+
+	// RejectionUnexpected indicates a message in an unexpected session state.
+	RejectionUnexpected MsgRejectReason = 0x03
+)
+
+func (mrr MsgRejectReason) String() string {
+	switch mrr {
+	case RejectionTypeUnknown:
+		return "Message Type Unknown"
+	case RejectionUnsupported:
+		return "Message Unsupported"
+	case RejectionUnexpected:
+		return "Message Unexpected"
+	default:
+		return "invalid"
+	}
+}
+
+// MsgRejectMessage is the MSG_REJECT message, as defined in section 5.1.2. It
+// informs the peer about a rejected message.
+type MsgRejectMessage struct {
+	ReasonCode MsgRejectReason
+	MsgHeader  MessageType
+}
+
+// NewMsgRejectMessage creates a new MsgRejectMessage with the given fields.
+func NewMsgRejectMessage(reason MsgRejectReason, header MessageType) MsgRejectMessage {
+	return MsgRejectMessage{
+		ReasonCode: reason,
+		MsgHeader:  header,
+	}
+}
+
+func (mr MsgRejectMessage) String() string {
+	return fmt.Sprintf("MSG_REJECT(Reason Code=%v, Rejected Message Header=0x%x)",
+		mr.ReasonCode, uint8(mr.MsgHeader))
+}
+
+// Type returns MsgRejectType.
+func (mr MsgRejectMessage) Type() MessageType {
+	return MsgRejectType
+}
+
+// Marshal writes this MsgRejectMessage's binary representation to the writer.
+func (mr MsgRejectMessage) Marshal(w io.Writer) error {
+	return writeFields(w, MsgRejectType, mr.ReasonCode, mr.MsgHeader)
+}
+
+// Unmarshal reads a MsgRejectMessage's binary representation from the reader.
+func (mr *MsgRejectMessage) Unmarshal(r io.Reader) error {
+	return readFields(r, &mr.ReasonCode, &mr.MsgHeader)
+}
@@ -0,0 +1,76 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// SessInitMessage is the SESS_INIT message, as defined in section 4.6. It
+// negotiates the session's parameters and carries each peer's node ID.
+// Session extension items are neither sent nor interpreted.
+type SessInitMessage struct {
+	KeepaliveInterval uint16
+	SegmentMru        uint64
+	TransferMru       uint64
+	NodeId            string
+}
+
+// NewSessInitMessage creates a new SessInitMessage with the given fields.
+func NewSessInitMessage(keepaliveInterval uint16, segmentMru, transferMru uint64, nodeId string) SessInitMessage {
+	return SessInitMessage{
+		KeepaliveInterval: keepaliveInterval,
+		SegmentMru:        segmentMru,
+		TransferMru:       transferMru,
+		NodeId:            nodeId,
+	}
+}
+
+func (si SessInitMessage) String() string {
+	return fmt.Sprintf(
+		"SESS_INIT(Keepalive Interval=%d, Segment MRU=%d, Transfer MRU=%d, Node ID=%s)",
+		si.KeepaliveInterval, si.SegmentMru, si.TransferMru, si.NodeId)
+}
+
+// Type returns SessInitType.
+func (si SessInitMessage) Type() MessageType {
+	return SessInitType
+}
+
+// Marshal writes this SessInitMessage's binary representation to the writer.
+func (si SessInitMessage) Marshal(w io.Writer) error {
+	var nodeId = []byte(si.NodeId)
+	if len(nodeId) > 0xFFFF {
+		return fmt.Errorf("SESS_INIT: Node ID is too long, %d bytes", len(nodeId))
+	}
+
+	return writeFields(w, SessInitType,
+		si.KeepaliveInterval, si.SegmentMru, si.TransferMru,
+		uint16(len(nodeId)), nodeId,
+		uint32(0))
+}
+
+// Unmarshal reads a SessInitMessage's binary representation from the reader.
+func (si *SessInitMessage) Unmarshal(r io.Reader) error {
+	var nodeIdLen uint16
+	if err := readFields(r,
+		&si.KeepaliveInterval, &si.SegmentMru, &si.TransferMru, &nodeIdLen); err != nil {
+		return err
+	}
+
+	nodeId, err := readBytes(r, uint64(nodeIdLen), math.MaxUint16)
+	if err != nil {
+		return err
+	}
+	si.NodeId = string(nodeId)
+
+	var extLen uint32
+	if err := readFields(r, &extLen); err != nil {
+		return err
+	}
+
+	// Session extension items are not supported and will be skipped.
+	_, err = io.CopyN(ioutil.Discard, r, int64(extLen))
+	return err
+}
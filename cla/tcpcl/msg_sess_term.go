@@ -0,0 +1,101 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+)
+
+// SessTermFlags are single-bit flags used in the SESS_TERM message.
+type SessTermFlags uint8
+
+const (
+	// TerminationNoFlags is the default for no set flags.
+	TerminationNoFlags SessTermFlags = 0x00
+
+	// TerminationReply indicates an acknowledgement of a previously received
+	// SESS_TERM message.
+	TerminationReply SessTermFlags = 0x01
+)
+
+// SessTermReason is the one-octet termination reason code of a SESS_TERM.
+type SessTermReason uint8
+
+const (
+	// TerminationUnknown indicates an unknown or not specified reason.
+	TerminationUnknown SessTermReason = 0x00
+
+	// TerminationIdleTimeout indicates a session being closed due to
+	// idleness.
+	TerminationIdleTimeout SessTermReason = 0x01
+
+	// TerminationVersionMismatch indicates an unsupported TCPCL version.
+	TerminationVersionMismatch SessTermReason = 0x02
+
+	// TerminationBusy indicates a too busy entity.
+	TerminationBusy SessTermReason = 0x03
+
+	// TerminationContactFailure indicates unacceptable contact header or
+	// SESS_INIT parameters.
+	TerminationContactFailure SessTermReason = 0x04
+
+	// TerminationResourceExhaustion indicates exhausted resources.
+	TerminationResourceExhaustion SessTermReason = 0x05
+)
+
+func (str SessTermReason) String() string {
+	switch str {
+	case TerminationUnknown:
+		return "Unknown"
+	case TerminationIdleTimeout:
+		return "Idle timeout"
+	case TerminationVersionMismatch:
+		return "Version mismatch"
+	case TerminationBusy:
+		return "Busy"
+	case TerminationContactFailure:
+		return "Contact Failure"
+	case TerminationResourceExhaustion:
+		return "Resource Exhaustion"
+	default:
+		return "invalid"
+	}
+}
+
+// SessTermMessage is the SESS_TERM message, as defined in section 6.1. It
+// initiates or acknowledges the termination of a session.
+type SessTermMessage struct {
+	Flags      SessTermFlags
+	ReasonCode SessTermReason
+}
+
+// NewSessTermMessage creates a new SessTermMessage with the given fields.
+func NewSessTermMessage(flags SessTermFlags, reason SessTermReason) SessTermMessage {
+	return SessTermMessage{
+		Flags:      flags,
+		ReasonCode: reason,
+	}
+}
+
+// IsReply returns true if this SESS_TERM acknowledges a previous one.
+func (st SessTermMessage) IsReply() bool {
+	return st.Flags&TerminationReply != 0
+}
+
+func (st SessTermMessage) String() string {
+	return fmt.Sprintf("SESS_TERM(Reply=%t, Reason Code=%v)", st.IsReply(), st.ReasonCode)
+}
+
+// Type returns SessTermType.
+func (st SessTermMessage) Type() MessageType {
+	return SessTermType
+}
+
+// Marshal writes this SessTermMessage's binary representation to the writer.
+func (st SessTermMessage) Marshal(w io.Writer) error {
+	return writeFields(w, SessTermType, st.Flags, st.ReasonCode)
+}
+
+// Unmarshal reads a SessTermMessage's binary representation from the reader.
+func (st *SessTermMessage) Unmarshal(r io.Reader) error {
+	return readFields(r, &st.Flags, &st.ReasonCode)
+}
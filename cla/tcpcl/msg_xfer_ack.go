@@ -0,0 +1,43 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+)
+
+// XferAckMessage is the XFER_ACK message, as defined in section 5.2.3. It
+// acknowledges the cumulative amount of received data of a transfer.
+type XferAckMessage struct {
+	Flags      SegmentFlags
+	TransferId uint64
+	AckLen     uint64
+}
+
+// NewXferAckMessage creates a new XferAckMessage with the given fields.
+func NewXferAckMessage(flags SegmentFlags, transferId, ackLen uint64) XferAckMessage {
+	return XferAckMessage{
+		Flags:      flags,
+		TransferId: transferId,
+		AckLen:     ackLen,
+	}
+}
+
+func (xa XferAckMessage) String() string {
+	return fmt.Sprintf("XFER_ACK(Flags=%v, Transfer ID=%d, Acknowledged Length=%d)",
+		xa.Flags, xa.TransferId, xa.AckLen)
+}
+
+// Type returns XferAckType.
+func (xa XferAckMessage) Type() MessageType {
+	return XferAckType
+}
+
+// Marshal writes this XferAckMessage's binary representation to the writer.
+func (xa XferAckMessage) Marshal(w io.Writer) error {
+	return writeFields(w, XferAckType, xa.Flags, xa.TransferId, xa.AckLen)
+}
+
+// Unmarshal reads a XferAckMessage's binary representation from the reader.
+func (xa *XferAckMessage) Unmarshal(r io.Reader) error {
+	return readFields(r, &xa.Flags, &xa.TransferId, &xa.AckLen)
+}
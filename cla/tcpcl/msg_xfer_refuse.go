@@ -0,0 +1,81 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+)
+
+// XferRefuseReason is the one-octet refusal reason code of a XFER_REFUSE.
+type XferRefuseReason uint8
+
+const (
+	// RefusalUnknown indicates an unknown or not specified reason.
+	RefusalUnknown XferRefuseReason = 0x00
+
+	// RefusalExtensionFailure indicates a failure processing the transfer
+	// extension items.
+	RefusalExtensionFailure XferRefuseReason = 0x01
+
+	// RefusalCompleted indicates that the receiver already has the complete
+	// bundle.
+	RefusalCompleted XferRefuseReason = 0x02
+
+	// RefusalNoResources indicates the receiver's resources are exhausted.
+	RefusalNoResources XferRefuseReason = 0x03
+
+	// RefusalRetransmit indicates a problem with the bundle data and requests
+	// a retransmission.
+	RefusalRetransmit XferRefuseReason = 0x04
+)
+
+func (xrr XferRefuseReason) String() string {
+	switch xrr {
+	case RefusalUnknown:
+		return "Unknown"
+	case RefusalExtensionFailure:
+		return "Extension Failure"
+	case RefusalCompleted:
+		return "Completed"
+	case RefusalNoResources:
+		return "No Resources"
+	case RefusalRetransmit:
+		return "Retransmit"
+	default:
+		return "invalid"
+	}
+}
+
+// XferRefuseMessage is the XFER_REFUSE message, as defined in section 5.2.4.
+// It is sent by the receiver to interrupt a transfer.
+type XferRefuseMessage struct {
+	ReasonCode XferRefuseReason
+	TransferId uint64
+}
+
+// NewXferRefuseMessage creates a new XferRefuseMessage with the given fields.
+func NewXferRefuseMessage(reason XferRefuseReason, transferId uint64) XferRefuseMessage {
+	return XferRefuseMessage{
+		ReasonCode: reason,
+		TransferId: transferId,
+	}
+}
+
+func (xr XferRefuseMessage) String() string {
+	return fmt.Sprintf("XFER_REFUSE(Reason Code=%v, Transfer ID=%d)",
+		xr.ReasonCode, xr.TransferId)
+}
+
+// Type returns XferRefuseType.
+func (xr XferRefuseMessage) Type() MessageType {
+	return XferRefuseType
+}
+
+// Marshal writes this XferRefuseMessage's binary representation to the writer.
+func (xr XferRefuseMessage) Marshal(w io.Writer) error {
+	return writeFields(w, XferRefuseType, xr.ReasonCode, xr.TransferId)
+}
+
+// Unmarshal reads a XferRefuseMessage's binary representation from the reader.
+func (xr *XferRefuseMessage) Unmarshal(r io.Reader) error {
+	return readFields(r, &xr.ReasonCode, &xr.TransferId)
+}
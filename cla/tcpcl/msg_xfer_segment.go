@@ -0,0 +1,119 @@
+package tcpcl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// SegmentFlags are single-bit flags used in the XFER_SEGMENT and XFER_ACK
+// messages.
+type SegmentFlags uint8
+
+const (
+	// SegmentEnd indicates the last segment of a transfer.
+	SegmentEnd SegmentFlags = 0x01
+
+	// SegmentStart indicates the first segment of a transfer.
+	SegmentStart SegmentFlags = 0x02
+
+	segmentReservedFields SegmentFlags = 0xFC
+)
+
+// Has returns true if a given flag or mask of flags is set.
+func (sf SegmentFlags) Has(flag SegmentFlags) bool {
+	return (sf & flag) != 0
+}
+
+func (sf SegmentFlags) String() string {
+	switch {
+	case sf.Has(SegmentStart) && sf.Has(SegmentEnd):
+		return "START|END"
+	case sf.Has(SegmentStart):
+		return "START"
+	case sf.Has(SegmentEnd):
+		return "END"
+	default:
+		return "NONE"
+	}
+}
+
+// XferSegmentMessage is the XFER_SEGMENT message, as defined in section 5.2.2.
+// Each transfer of a bundle is split into one or more segments. Transfer
+// extension items are neither sent nor interpreted.
+type XferSegmentMessage struct {
+	Flags      SegmentFlags
+	TransferId uint64
+	Data       []byte
+}
+
+// NewXferSegmentMessage creates a new XferSegmentMessage with the given fields.
+func NewXferSegmentMessage(flags SegmentFlags, transferId uint64, data []byte) XferSegmentMessage {
+	return XferSegmentMessage{
+		Flags:      flags,
+		TransferId: transferId,
+		Data:       data,
+	}
+}
+
+func (xs XferSegmentMessage) String() string {
+	return fmt.Sprintf("XFER_SEGMENT(Flags=%v, Transfer ID=%d, Data Length=%d)",
+		xs.Flags, xs.TransferId, len(xs.Data))
+}
+
+// Type returns XferSegmentType.
+func (xs XferSegmentMessage) Type() MessageType {
+	return XferSegmentType
+}
+
+// Marshal writes this XferSegmentMessage's binary representation to the writer.
+func (xs XferSegmentMessage) Marshal(w io.Writer) error {
+	if xs.Flags&segmentReservedFields != 0 {
+		return fmt.Errorf("XFER_SEGMENT: flags contain reserved bits, %x", uint8(xs.Flags))
+	}
+
+	var fields = []interface{}{xs.Flags, xs.TransferId}
+	if xs.Flags.Has(SegmentStart) {
+		// Empty transfer extension items
+		fields = append(fields, uint32(0))
+	}
+	fields = append(fields, uint64(len(xs.Data)), xs.Data)
+
+	return writeFields(w, XferSegmentType, fields...)
+}
+
+// Unmarshal reads a XferSegmentMessage's binary representation from the reader.
+func (xs *XferSegmentMessage) Unmarshal(r io.Reader) error {
+	if err := readFields(r, &xs.Flags, &xs.TransferId); err != nil {
+		return err
+	}
+
+	if xs.Flags&segmentReservedFields != 0 {
+		return fmt.Errorf("XFER_SEGMENT: flags contain reserved bits, %x", uint8(xs.Flags))
+	}
+
+	if xs.Flags.Has(SegmentStart) {
+		var extLen uint32
+		if err := readFields(r, &extLen); err != nil {
+			return err
+		}
+
+		// Transfer extension items are not supported and will be skipped.
+		if _, err := io.CopyN(ioutil.Discard, r, int64(extLen)); err != nil {
+			return err
+		}
+	}
+
+	var dataLen uint64
+	if err := readFields(r, &dataLen); err != nil {
+		return err
+	}
+
+	data, err := readBytes(r, dataLen, segmentMru)
+	if err != nil {
+		return err
+	}
+	xs.Data = data
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package tcpcl
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// TCPCLServer is an implementation of a TCPCLv4 server which accepts incoming
+// sessions and forwards their received bundles to the given channel. The
+// accepted sessions are only used for receiving bundles.
+type TCPCLServer struct {
+	listenAddress string
+	reportChan    chan cla.RecBundle
+	endpointID    bundle.EndpointID
+	permanent     bool
+
+	sessions     map[*TCPCLClient]struct{}
+	sessionMutex sync.Mutex
+	sessionWg    sync.WaitGroup
+
+	stopSyn chan struct{}
+	stopAck chan struct{}
+}
+
+// NewTCPCLServer creates a new TCPCLServer for the given listen address. The
+// endpointID is announced to each connecting peer. The permanent flag
+// indicates if this TCPCLServer should never be removed from the core.
+func NewTCPCLServer(listenAddress string, endpointID bundle.EndpointID, permanent bool) *TCPCLServer {
+	return &TCPCLServer{
+		listenAddress: listenAddress,
+		reportChan:    make(chan cla.RecBundle),
+		endpointID:    endpointID,
+		permanent:     permanent,
+		sessions:      make(map[*TCPCLClient]struct{}),
+		stopSyn:       make(chan struct{}),
+		stopAck:       make(chan struct{}),
+	}
+}
+
+// Start starts this TCPCLServer and might return an error and a boolean
+// indicating if another Start should be tried later.
+func (serv *TCPCLServer) Start() (error, bool) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", serv.listenAddress)
+	if err != nil {
+		return err, false
+	}
+
+	ln, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return err, true
+	}
+
+	go func(ln *net.TCPListener) {
+		for {
+			select {
+			case <-serv.stopSyn:
+				ln.Close()
+
+				serv.sessionMutex.Lock()
+				for session := range serv.sessions {
+					session.Close()
+				}
+				serv.sessionMutex.Unlock()
+
+				serv.sessionWg.Wait()
+
+				close(serv.reportChan)
+				close(serv.stopAck)
+
+				return
+
+			default:
+				ln.SetDeadline(time.Now().Add(50 * time.Millisecond))
+				if conn, err := ln.Accept(); err == nil {
+					serv.sessionWg.Add(1)
+					go serv.handleSession(conn)
+				}
+			}
+		}
+	}(ln)
+
+	return nil, true
+}
+
+// handleSession establishes a passive session for an accepted connection and
+// forwards its bundles until the session ends.
+func (serv *TCPCLServer) handleSession(conn net.Conn) {
+	defer serv.sessionWg.Done()
+
+	var session = newPassiveTCPCLClient(conn, serv.endpointID)
+	if err, _ := session.Start(); err != nil {
+		log.WithFields(log.Fields{
+			"cla":   serv,
+			"conn":  conn.RemoteAddr(),
+			"error": err,
+		}).Warn("TCPCLServer failed to establish a session")
+
+		return
+	}
+
+	serv.sessionMutex.Lock()
+	select {
+	case <-serv.stopSyn:
+		serv.sessionMutex.Unlock()
+		session.Close()
+		return
+
+	default:
+		serv.sessions[session] = struct{}{}
+		serv.sessionMutex.Unlock()
+	}
+
+	log.WithFields(log.Fields{
+		"cla":     serv,
+		"session": session,
+		"peer":    session.GetPeerEndpointID(),
+	}).Info("TCPCLServer accepted a new session")
+
+	for recBndl := range session.Channel() {
+		select {
+		case serv.reportChan <- recBndl:
+		case <-serv.stopSyn:
+		}
+	}
+
+	serv.sessionMutex.Lock()
+	delete(serv.sessions, session)
+	serv.sessionMutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"cla":     serv,
+		"session": session,
+	}).Debug("TCPCLServer's session was closed")
+}
+
+// Channel returns a channel of received bundles.
+func (serv *TCPCLServer) Channel() chan cla.RecBundle {
+	return serv.reportChan
+}
+
+// Close shuts this TCPCLServer and all its sessions down.
+func (serv *TCPCLServer) Close() {
+	close(serv.stopSyn)
+	<-serv.stopAck
+}
+
+// GetEndpointID returns the endpoint ID assigned to this CLA.
+func (serv *TCPCLServer) GetEndpointID() bundle.EndpointID {
+	return serv.endpointID
+}
+
+// Address should return a unique address string to both identify this
+// ConvergenceReceiver and ensure it will not opened twice.
+func (serv *TCPCLServer) Address() string {
+	return fmt.Sprintf("tcpcl://%s", serv.listenAddress)
+}
+
+// IsPermanent returns true, if this CLA should not be removed after failures.
+func (serv *TCPCLServer) IsPermanent() bool {
+	return serv.permanent
+}
+
+func (serv *TCPCLServer) String() string {
+	return serv.Address()
+}
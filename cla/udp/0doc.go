@@ -0,0 +1,35 @@
+// Package udp provides a simple UDP based convergence layer, transmitting each
+// bundle as a single CBOR encoded datagram without any acknowledgement.
+//
+// The UDPServer implements the ConvergenceReceiver and the UDPClient the
+// ConvergenceSender interfaces defined in the parent cla package. Both enforce
+// a maximum bundle size. The UDPClient also implements the optional
+// ConvergenceMaxSize interface. Thus, the Core fragments larger bundles before
+// sending them, if their flags allow fragmentation.
+package udp
+
+const (
+	// DefaultMaxBundleSize is the default maximum size of a bundle's CBOR
+	// representation, which fits into a single datagram within a typical
+	// Ethernet MTU.
+	DefaultMaxBundleSize = 1400
+
+	// MaxDatagramSize is the maximum payload size of an UDP datagram over IPv4
+	// and the upper limit of each maximum bundle size.
+	MaxDatagramSize = 65507
+)
+
+// checkMaxBundleSize returns the maximum bundle size to be used for the given
+// value. Zero results in the DefaultMaxBundleSize.
+func checkMaxBundleSize(maxSize uint) uint {
+	switch {
+	case maxSize == 0:
+		return DefaultMaxBundleSize
+
+	case maxSize > MaxDatagramSize:
+		return MaxDatagramSize
+
+	default:
+		return maxSize
+	}
+}
@@ -0,0 +1,113 @@
+package udp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// UDPClient is an implementation of an UDP convergence layer client, sending
+// each bundle as a single datagram to an UDPServer.
+type UDPClient struct {
+	conn    *net.UDPConn
+	peer    bundle.EndpointID
+	maxSize uint
+	mutex   sync.Mutex
+
+	permanent bool
+	address   string
+}
+
+// NewUDPClient creates a new UDPClient, sending to the given address for the
+// registered endpoint ID. Bundles exceeding the maximum size will be rejected;
+// zero results in the DefaultMaxBundleSize. The permanent flag indicates if
+// this UDPClient should never be removed from the core.
+func NewUDPClient(address string, peer bundle.EndpointID, maxSize uint, permanent bool) *UDPClient {
+	return &UDPClient{
+		peer:      peer,
+		maxSize:   checkMaxBundleSize(maxSize),
+		permanent: permanent,
+		address:   address,
+	}
+}
+
+// Start starts this UDPClient and might return an error and a boolean
+// indicating if another Start should be tried later.
+func (client *UDPClient) Start() (error, bool) {
+	udpAddr, err := net.ResolveUDPAddr("udp", client.address)
+	if err != nil {
+		return err, true
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+	if err == nil {
+		client.mutex.Lock()
+		client.conn = conn
+		client.mutex.Unlock()
+	}
+
+	return err, true
+}
+
+// Send transmits a bundle to this UDPClient's endpoint. An error is returned
+// for bundles exceeding the maximum bundle size.
+func (client *UDPClient) Send(bndl bundle.Bundle) error {
+	if size := bndl.EncodedSize(); size > uint64(client.maxSize) {
+		return fmt.Errorf("UDPClient.Send: bundle's size of %d bytes exceeds the maximum size of %d bytes",
+			size, client.maxSize)
+	}
+
+	var data bytes.Buffer
+	if err := bundle.NewEncoder(&data).Encode(bndl); err != nil {
+		return fmt.Errorf("UDPClient.Send: %v", err)
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.conn == nil {
+		return fmt.Errorf("UDPClient.Send: not started")
+	}
+
+	_, err := client.conn.Write(data.Bytes())
+	return err
+}
+
+// Close closes the UDPClient's connection.
+func (client *UDPClient) Close() {
+	client.mutex.Lock()
+	if client.conn != nil {
+		client.conn.Close()
+	}
+	client.mutex.Unlock()
+}
+
+// MaxBundleSize returns the maximum size of a bundle's CBOR representation.
+// This implements the cla.ConvergenceMaxSize interface.
+func (client *UDPClient) MaxBundleSize() uint {
+	return client.maxSize
+}
+
+// GetPeerEndpointID returns the endpoint ID assigned to this CLA's peer,
+// if it's known. Otherwise the zero endpoint will be returned.
+func (client *UDPClient) GetPeerEndpointID() bundle.EndpointID {
+	return client.peer
+}
+
+// Address should return a unique address string to both identify this
+// ConvergenceSender and ensure it will not opened twice.
+func (client *UDPClient) Address() string {
+	return fmt.Sprintf("udp://%s", client.address)
+}
+
+// IsPermanent returns true, if this CLA should not be removed after failures.
+func (client *UDPClient) IsPermanent() bool {
+	return client.permanent
+}
+
+func (client *UDPClient) String() string {
+	return client.Address()
+}
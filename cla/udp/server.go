@@ -0,0 +1,142 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// UDPServer is an implementation of an UDP convergence layer server, which
+// receives bundles as single datagrams and forwards them to the given channel.
+type UDPServer struct {
+	listenAddress string
+	reportChan    chan cla.RecBundle
+	endpointID    bundle.EndpointID
+	maxSize       uint
+	permanent     bool
+
+	stopSyn chan struct{}
+	stopAck chan struct{}
+}
+
+// NewUDPServer creates a new UDPServer for the given listen address. Datagrams
+// exceeding the maximum size will be dropped; zero results in the
+// DefaultMaxBundleSize. The permanent flag indicates if this UDPServer should
+// never be removed from the core.
+func NewUDPServer(listenAddress string, endpointID bundle.EndpointID, maxSize uint, permanent bool) *UDPServer {
+	return &UDPServer{
+		listenAddress: listenAddress,
+		reportChan:    make(chan cla.RecBundle),
+		endpointID:    endpointID,
+		maxSize:       checkMaxBundleSize(maxSize),
+		permanent:     permanent,
+		stopSyn:       make(chan struct{}),
+		stopAck:       make(chan struct{}),
+	}
+}
+
+// Start starts this UDPServer and might return an error and a boolean
+// indicating if another Start should be tried later.
+func (serv *UDPServer) Start() (error, bool) {
+	udpAddr, err := net.ResolveUDPAddr("udp", serv.listenAddress)
+	if err != nil {
+		return err, false
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err, true
+	}
+
+	go func(conn *net.UDPConn) {
+		// One additional byte detects truncated datagrams.
+		var buff = make([]byte, serv.maxSize+1)
+
+		for {
+			select {
+			case <-serv.stopSyn:
+				conn.Close()
+				close(serv.reportChan)
+				close(serv.stopAck)
+
+				return
+
+			default:
+				conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+				if n, addr, err := conn.ReadFromUDP(buff); err == nil {
+					serv.handleDatagram(buff[:n], addr)
+				}
+			}
+		}
+	}(conn)
+
+	return nil, true
+}
+
+// handleDatagram parses a received datagram and forwards its bundle.
+func (serv *UDPServer) handleDatagram(data []byte, addr *net.UDPAddr) {
+	if uint(len(data)) > serv.maxSize {
+		log.WithFields(log.Fields{
+			"cla":      serv,
+			"peer":     addr,
+			"max_size": serv.maxSize,
+		}).Warn("UDPServer dropped a datagram exceeding the maximum size")
+
+		return
+	}
+
+	bndl, err := bundle.NewBundleFromCbor(data)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"cla":   serv,
+			"peer":  addr,
+			"error": err,
+		}).Warn("UDPServer failed to parse a datagram's bundle")
+
+		return
+	}
+
+	serv.reportChan <- cla.NewRecBundle(bndl, serv.endpointID)
+}
+
+// Channel returns a channel of received bundles.
+func (serv *UDPServer) Channel() chan cla.RecBundle {
+	return serv.reportChan
+}
+
+// Close shuts this UDPServer down.
+func (serv *UDPServer) Close() {
+	close(serv.stopSyn)
+	<-serv.stopAck
+}
+
+// MaxBundleSize returns the maximum size of a received bundle's CBOR
+// representation, which should be announced to the peers.
+func (serv UDPServer) MaxBundleSize() uint {
+	return serv.maxSize
+}
+
+// GetEndpointID returns the endpoint ID assigned to this CLA.
+func (serv UDPServer) GetEndpointID() bundle.EndpointID {
+	return serv.endpointID
+}
+
+// Address should return a unique address string to both identify this
+// ConvergenceReceiver and ensure it will not opened twice.
+func (serv UDPServer) Address() string {
+	return fmt.Sprintf("udp://%s", serv.listenAddress)
+}
+
+// IsPermanent returns true, if this CLA should not be removed after failures.
+func (serv UDPServer) IsPermanent() bool {
+	return serv.permanent
+}
+
+func (serv UDPServer) String() string {
+	return serv.Address()
+}
@@ -0,0 +1,129 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func getRandomPort(t *testing.T) int {
+	addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func createBundle(t *testing.T, payload []byte) bundle.Bundle {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeEpoch, 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewBundleAgeBlock(1, bundle.DeleteBundle, 0),
+			bundle.NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bndl
+}
+
+func TestUDPServerClient(t *testing.T) {
+	const packages = 100
+
+	var port = getRandomPort(t)
+	var bndl = createBundle(t, []byte("hello world!"))
+
+	serv := NewUDPServer(
+		fmt.Sprintf("127.0.0.1:%d", port), bundle.MustNewEndpointID("dtn:udpcla"), 0, false)
+	if err, _ := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	client := NewUDPClient(
+		fmt.Sprintf("127.0.0.1:%d", port), bundle.MustNewEndpointID("dtn:udpcla"), 0, false)
+	if err, _ := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if size := client.MaxBundleSize(); size != DefaultMaxBundleSize {
+		t.Fatalf("UDPClient's maximum size is %d, not the default", size)
+	}
+
+	// Too large bundles are rejected by the client.
+	if err := client.Send(createBundle(t, make([]byte, DefaultMaxBundleSize))); err == nil {
+		t.Fatalf("UDPClient sent a bundle exceeding its maximum size")
+	}
+
+	// The loopback device should not lose any datagram.
+	for i := 0; i < packages; i++ {
+		if err := client.Send(bndl); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case recBndl := <-serv.Channel():
+			if !reflect.DeepEqual(recBndl.Bundle, bndl) {
+				t.Fatalf("Received bundle differs: %v, %v", recBndl.Bundle, bndl)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("UDPServer received no bundle")
+		}
+	}
+}
+
+func TestUDPServerMaxSize(t *testing.T) {
+	var port = getRandomPort(t)
+	var address = fmt.Sprintf("127.0.0.1:%d", port)
+
+	serv := NewUDPServer(address, bundle.MustNewEndpointID("dtn:udpcla"), 128, false)
+	if err, _ := serv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer serv.Close()
+
+	// This client does not know the server's smaller maximum size.
+	client := NewUDPClient(address, bundle.MustNewEndpointID("dtn:udpcla"), 0, false)
+	if err, _ := client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var small = createBundle(t, []byte("hello world!"))
+	var large = createBundle(t, make([]byte, 256))
+
+	for _, bndl := range []bundle.Bundle{large, small} {
+		if err := client.Send(bndl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case recBndl := <-serv.Channel():
+		if !reflect.DeepEqual(recBndl.Bundle, small) {
+			t.Fatalf("UDPServer received %v instead of the small bundle", recBndl.Bundle)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("UDPServer received no bundle")
+	}
+}
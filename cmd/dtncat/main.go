@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
@@ -51,6 +53,47 @@ func sendRequest(host, destination string, payload []byte) error {
 	return nil
 }
 
+func uploadRequest(host, destination string, payload io.Reader) error {
+	var query = url.Values{"destination": {destination}}
+
+	resp, err := http.Post(buildUrl(host, "upload")+"?"+query.Encode(), "application/octet-stream", payload)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Response's status code is %d != 200", resp.StatusCode)
+	}
+
+	var respData core.SimpleRESTRequestResponse
+	if err := codec.NewDecoder(resp.Body, new(codec.JsonHandle)).Decode(&respData); err != nil {
+		return err
+	}
+
+	if respData.Error != "" {
+		return fmt.Errorf("JSON contains error: %v", respData.Error)
+	}
+
+	return nil
+}
+
+func downloadRequest(host, payloadId string, w io.Writer) error {
+	var query = url.Values{"id": {payloadId}}
+
+	resp, err := http.Get(buildUrl(host, "download") + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Response's status code is %d != 200", resp.StatusCode)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
 func fetchRequest(host string) error {
 	resp, err := http.Get(buildUrl(host, "fetch"))
 	if err != nil {
@@ -67,14 +110,20 @@ func fetchRequest(host string) error {
 }
 
 func showHelp() {
-	fmt.Printf("dtncat [send|fetch|help] ...\n\n")
+	fmt.Printf("dtncat [send|fetch|upload|download|help] ...\n\n")
 	fmt.Printf("dtncat send REST-API ENDPOINT-ID\n")
 	fmt.Printf("  sends data from stdin through the given REST-API to the endpoint\n\n")
 	fmt.Printf("dtncat fetch REST-API\n")
 	fmt.Printf("  fetches all bundles from the given REST-API\n\n")
+	fmt.Printf("dtncat upload REST-API ENDPOINT-ID\n")
+	fmt.Printf("  streams large data from stdin through the given REST-API to the endpoint\n\n")
+	fmt.Printf("dtncat download REST-API PAYLOAD-ID\n")
+	fmt.Printf("  writes a fetched bundle's payload file to stdout\n\n")
 	fmt.Printf("Examples:\n")
-	fmt.Printf("  dtncat send  \"http://127.0.0.1:8080/\" \"dtn:alpha\" <<< \"hello world\"\n")
-	fmt.Printf("  dtncat fetch \"http://127.0.0.1:8080/\"\n")
+	fmt.Printf("  dtncat send     \"http://127.0.0.1:8080/\" \"dtn:alpha\" <<< \"hello world\"\n")
+	fmt.Printf("  dtncat fetch    \"http://127.0.0.1:8080/\"\n")
+	fmt.Printf("  dtncat upload   \"http://127.0.0.1:8080/\" \"dtn:alpha\" < large.iso\n")
+	fmt.Printf("  dtncat download \"http://127.0.0.1:8080/\" \"payload-123456\" > large.iso\n")
 }
 
 func main() {
@@ -116,6 +165,30 @@ func main() {
 			os.Exit(1)
 		}
 
+	case "upload":
+		if len(args) != 3 {
+			fmt.Printf("Amount of parameters is wrong.\n\n")
+			showHelp()
+			os.Exit(1)
+		}
+
+		if err := uploadRequest(args[1], args[2], os.Stdin); err != nil {
+			fmt.Printf("Uploading data failed: %v", err)
+			os.Exit(1)
+		}
+
+	case "download":
+		if len(args) != 3 {
+			fmt.Printf("Amount of parameters is wrong.\n\n")
+			showHelp()
+			os.Exit(1)
+		}
+
+		if err := downloadRequest(args[1], args[2], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Downloading data failed: %v", err)
+			os.Exit(1)
+		}
+
 	case "help", "--help", "-h":
 		showHelp()
 
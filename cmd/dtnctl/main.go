@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/geistesk/dtn7/core"
+	"github.com/ugorji/go/codec"
+)
+
+func buildUrl(host, action string, query url.Values) string {
+	var u string
+	if strings.HasSuffix(host, "/") {
+		u = fmt.Sprintf("%s%s/", host, action)
+	} else {
+		u = fmt.Sprintf("%s/%s/", host, action)
+	}
+
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	return u
+}
+
+// doRequest performs a request against the AdminAPI and prints its JSON
+// response.
+func doRequest(method, u string, body []byte) error {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	json, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", string(json))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response's status code is %d != 200", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func addPeerRequest(host string, args []string) error {
+	var peerReq = core.AdminPeerRequest{
+		Protocol: args[0],
+		Endpoint: args[1],
+		Node:     args[2],
+	}
+
+	if len(args) == 4 {
+		size, err := strconv.ParseUint(args[3], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		peerReq.MaxBundleSize = uint(size)
+	}
+
+	var body []byte
+	if err := codec.NewEncoderBytes(&body, new(codec.JsonHandle)).Encode(peerReq); err != nil {
+		return err
+	}
+
+	return doRequest(http.MethodPost, buildUrl(host, "peers", nil), body)
+}
+
+func showHelp() {
+	fmt.Printf("dtnctl [bundles|bundle|delete|clas|add-peer|remove-peer|help] ...\n\n")
+	fmt.Printf("dtnctl bundles ADMIN-API\n")
+	fmt.Printf("  lists all stored bundles and their constraints\n\n")
+	fmt.Printf("dtnctl bundle ADMIN-API BUNDLE-ID\n")
+	fmt.Printf("  shows the stored bundle\n\n")
+	fmt.Printf("dtnctl delete ADMIN-API BUNDLE-ID\n")
+	fmt.Printf("  deletes the stored bundle\n\n")
+	fmt.Printf("dtnctl clas ADMIN-API\n")
+	fmt.Printf("  lists all registered CLAs and those waiting for a restart\n\n")
+	fmt.Printf("dtnctl add-peer ADMIN-API PROTOCOL ENDPOINT ENDPOINT-ID [MAX-BUNDLE-SIZE]\n")
+	fmt.Printf("  adds a peer, like a peer block of dtnd's configuration\n\n")
+	fmt.Printf("dtnctl remove-peer ADMIN-API ADDRESS\n")
+	fmt.Printf("  closes and removes the CLA of the address\n\n")
+	fmt.Printf("Examples:\n")
+	fmt.Printf("  dtnctl bundles     \"http://127.0.0.1:8082/\"\n")
+	fmt.Printf("  dtnctl add-peer    \"http://127.0.0.1:8082/\" tcpcl \"[::1]:4556\" \"dtn:beta\"\n")
+	fmt.Printf("  dtnctl remove-peer \"http://127.0.0.1:8082/\" \"[::1]:4556\"\n")
+}
+
+func main() {
+	args := os.Args[1:]
+
+	if len(args) == 0 {
+		showHelp()
+		os.Exit(1)
+	}
+
+	var argsLen = map[string][]int{
+		"bundles":     {2},
+		"bundle":      {3},
+		"delete":      {3},
+		"clas":        {2},
+		"add-peer":    {5, 6},
+		"remove-peer": {3},
+	}
+
+	if lens, ok := argsLen[args[0]]; ok {
+		var valid = false
+		for _, l := range lens {
+			valid = valid || len(args) == l
+		}
+
+		if !valid {
+			fmt.Printf("Amount of parameters is wrong.\n\n")
+			showHelp()
+			os.Exit(1)
+		}
+	}
+
+	var err error
+
+	switch args[0] {
+	case "bundles":
+		err = doRequest(http.MethodGet, buildUrl(args[1], "bundles", nil), nil)
+
+	case "bundle":
+		err = doRequest(http.MethodGet, buildUrl(args[1], "bundles", url.Values{"id": {args[2]}}), nil)
+
+	case "delete":
+		err = doRequest(http.MethodDelete, buildUrl(args[1], "bundles", url.Values{"id": {args[2]}}), nil)
+
+	case "clas":
+		err = doRequest(http.MethodGet, buildUrl(args[1], "clas", nil), nil)
+
+	case "add-peer":
+		err = addPeerRequest(args[1], args[2:])
+
+	case "remove-peer":
+		err = doRequest(http.MethodDelete, buildUrl(args[1], "peers", url.Values{"address": {args[2]}}), nil)
+
+	case "help", "--help", "-h":
+		showHelp()
+
+	default:
+		fmt.Printf("Unknown option: %s\n\n", args[0])
+		showHelp()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Printf("Request failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
 	"strconv"
 
 	log "github.com/sirupsen/logrus"
@@ -10,25 +14,70 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/geistesk/dtn7/bundle"
 	"github.com/geistesk/dtn7/cla"
+	"github.com/geistesk/dtn7/cla/bibe"
+	"github.com/geistesk/dtn7/cla/mtcp"
 	"github.com/geistesk/dtn7/cla/stcp"
+	"github.com/geistesk/dtn7/cla/tcpcl"
+	"github.com/geistesk/dtn7/cla/udp"
 	"github.com/geistesk/dtn7/core"
 	"github.com/geistesk/dtn7/discovery"
 )
 
 // tomlConfig describes the TOML-configuration.
 type tomlConfig struct {
-	Core       coreConf
-	Logging    logConf
-	Discovery  discoveryConf
-	SimpleRest simpleRestConf `toml:"simple-rest"`
-	Listen     []convergenceConf
-	Peer       []convergenceConf
+	Core            coreConf
+	Quota           quotaConf
+	Routing         routingConf
+	Logging         logConf
+	Discovery       discoveryConf
+	SimpleRest      simpleRestConf `toml:"simple-rest"`
+	WebSocket       webSocketConf  `toml:"websocket"`
+	Metrics         metricsConf
+	Admin           adminConf
+	Listen          []convergenceConf
+	Peer            []convergenceConf
+	Route           []routeConf
+	Integrity       []integrityConf
+	Confidentiality []confidentialityConf
 }
 
 // coreConf describes the Core-configuration block.
 type coreConf struct {
 	Store             string
-	InspectAllBundles bool `toml:"inspect-all-bundles"`
+	StoreBackend      string `toml:"store-backend"`
+	MigrateFrom       string `toml:"migrate-from"`
+	InspectAllBundles bool   `toml:"inspect-all-bundles"`
+	NodeId            string `toml:"node-id"`
+	Clockless         bool
+	PayloadDir        string `toml:"payload-dir"`
+	PayloadThreshold  uint64 `toml:"payload-threshold"`
+	MaxBlockSize      uint64 `toml:"max-block-size"`
+}
+
+// defaultPayloadThreshold is the payload size from which on payloads are
+// written into the payload directory, if no payload-threshold is configured.
+const defaultPayloadThreshold = 1 << 20
+
+// quotaConf describes the store's StorageQuota.
+type quotaConf struct {
+	MaxBytes       uint64  `toml:"max-bytes"`
+	MaxBundles     uint    `toml:"max-bundles"`
+	MaxSourceShare float64 `toml:"max-source-share"`
+	Evict          bool
+}
+
+// routingConf describes the Routing-configuration block.
+type routingConf struct {
+	Algorithm string
+	Copies    uint
+}
+
+// routeConf describes a static route, used for "route".
+type routeConf struct {
+	Destination string
+	NextHop     string `toml:"next-hop"`
+	Priority    int
+	Fallback    bool
 }
 
 // logConf describes the Logging-configuration block.
@@ -50,56 +99,270 @@ type simpleRestConf struct {
 	Listen string
 }
 
+// webSocketConf describes the WebSocketAgent.
+type webSocketConf struct {
+	Node   string
+	Listen string
+}
+
+// metricsConf describes the Prometheus metrics endpoint.
+type metricsConf struct {
+	Listen string
+}
+
+// adminConf describes the AdminAPI.
+type adminConf struct {
+	Listen string
+}
+
+// integrityConf describes a BPSec security context, used to verify the Block
+// Integrity Blocks of received bundles.
+type integrityConf struct {
+	Context string
+	Variant uint
+	Key     string
+}
+
+// confidentialityConf describes a BPSec security context, used to decrypt the
+// Block Confidentiality Blocks of received bundles.
+type confidentialityConf struct {
+	Context string
+	Key     string
+}
+
 // convergenceConf describes the Convergence-configuration block, used for
 // "listen" and "peer".
 type convergenceConf struct {
-	Node     string
-	Protocol string
-	Endpoint string
+	Node          string
+	Protocol      string
+	Endpoint      string
+	MaxBundleSize uint `toml:"max-bundle-size"`
 }
 
 // parseListen inspects a "listen" convergenceConf and returns a ConvergenceReceiver.
 func parseListen(conv convergenceConf) (cla.ConvergenceReceiver, discovery.DiscoveryMessage, error) {
 	var defaultDisc = discovery.DiscoveryMessage{}
 
+	endpointID, err := bundle.NewEndpointID(conv.Node)
+	if err != nil {
+		return nil, defaultDisc, err
+	}
+
+	_, portStr, _ := net.SplitHostPort(conv.Endpoint)
+	portInt, _ := strconv.Atoi(portStr)
+
+	msg := discovery.DiscoveryMessage{
+		Endpoint: endpointID,
+		Port:     uint(portInt),
+	}
+
 	switch conv.Protocol {
 	case "stcp":
-		endpointID, err := bundle.NewEndpointID(conv.Node)
-		if err != nil {
-			return nil, defaultDisc, err
+		msg.Type = discovery.STCP
+		return stcp.NewSTCPServer(conv.Endpoint, endpointID, true), msg, nil
+
+	case "mtcp":
+		msg.Type = discovery.MTCP
+		return mtcp.NewMTCPServer(conv.Endpoint, endpointID, true), msg, nil
+
+	case "tcpcl":
+		msg.Type = discovery.TCPCLV4
+		return tcpcl.NewTCPCLServer(conv.Endpoint, endpointID, true), msg, nil
+
+	case "udp":
+		var serv = udp.NewUDPServer(conv.Endpoint, endpointID, conv.MaxBundleSize, true)
+
+		msg.Type = discovery.UDP
+		msg.SetMaxBundleSize(serv.MaxBundleSize())
+		return serv, msg, nil
+
+	default:
+		return nil, defaultDisc, fmt.Errorf("Unknown listen.protocol \"%s\"", conv.Protocol)
+	}
+}
+
+// parseIntegrity inspects an "integrity" integrityConf and returns an
+// IntegrityContext. The key must be hex encoded.
+func parseIntegrity(conf integrityConf) (bundle.IntegrityContext, error) {
+	key, err := hex.DecodeString(conf.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Context {
+	case "hmac-sha2":
+		var variant bundle.HMACSHA2Variant
+		switch conf.Variant {
+		case 0, 256:
+			variant = bundle.HMAC256
+		case 384:
+			variant = bundle.HMAC384
+		case 512:
+			variant = bundle.HMAC512
+		default:
+			return nil, fmt.Errorf("Unknown integrity.variant %d", conf.Variant)
 		}
 
-		_, portStr, _ := net.SplitHostPort(conv.Endpoint)
-		portInt, _ := strconv.Atoi(portStr)
+		return bundle.NewHMACSHA2Context(variant, key)
 
-		msg := discovery.DiscoveryMessage{
-			Type:     discovery.STCP,
-			Endpoint: endpointID,
-			Port:     uint(portInt),
+	case "ed25519":
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("integrity.key is no Ed25519 public key")
 		}
 
-		return stcp.NewSTCPServer(conv.Endpoint, endpointID, true), msg, nil
+		return bundle.NewEd25519VerifyContext(ed25519.PublicKey(key)), nil
 
 	default:
-		return nil, defaultDisc, fmt.Errorf("Unknown listen.protocol \"%s\"", conv.Protocol)
+		return nil, fmt.Errorf("Unknown integrity.context \"%s\"", conf.Context)
+	}
+}
+
+// parseConfidentiality inspects a "confidentiality" confidentialityConf and
+// returns a ConfidentialityContext. The key must be hex encoded.
+func parseConfidentiality(conf confidentialityConf) (bundle.ConfidentialityContext, error) {
+	key, err := hex.DecodeString(conf.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Context {
+	case "aes-gcm":
+		return bundle.NewAESGCMContext(key)
+
+	default:
+		return nil, fmt.Errorf("Unknown confidentiality.context \"%s\"", conf.Context)
 	}
 }
 
-func parsePeer(conv convergenceConf) (cla.ConvergenceSender, error) {
+// parsePeer inspects a "peer" convergenceConf and returns a ConvergenceSender.
+// The nodeId is this node's endpoint ID and the Core is used by BIBE, both
+// required by some protocols.
+func parsePeer(conv convergenceConf, nodeId bundle.EndpointID, c *core.Core) (cla.ConvergenceSender, error) {
+	endpointID, err := bundle.NewEndpointID(conv.Node)
+	if err != nil {
+		return nil, err
+	}
+
 	switch conv.Protocol {
 	case "stcp":
-		endpointID, err := bundle.NewEndpointID(conv.Node)
+		return stcp.NewSTCPClient(conv.Endpoint, endpointID, true), nil
+
+	case "mtcp":
+		return mtcp.NewMTCPClient(conv.Endpoint, endpointID, true), nil
+
+	case "tcpcl":
+		if nodeId == bundle.DtnNone() {
+			return nil, fmt.Errorf("peer.protocol \"tcpcl\" requires core.node-id")
+		}
+
+		return tcpcl.NewTCPCLClient(conv.Endpoint, nodeId, endpointID, true), nil
+
+	case "udp":
+		return udp.NewUDPClient(conv.Endpoint, endpointID, conv.MaxBundleSize, true), nil
+
+	case "bibe":
+		if nodeId == bundle.DtnNone() {
+			return nil, fmt.Errorf("peer.protocol \"bibe\" requires core.node-id")
+		}
+
+		remote, err := bundle.NewEndpointID(conv.Endpoint)
 		if err != nil {
 			return nil, err
 		}
 
-		return stcp.NewSTCPClient(conv.Endpoint, endpointID, true), nil
+		return bibe.NewBIBE(c, nodeId, remote, endpointID, true), nil
 
 	default:
 		return nil, fmt.Errorf("Unknown peer.protocol \"%s\"", conv.Protocol)
 	}
 }
 
+// parseStore creates the Store of the Core-configuration block's backend. An
+// existing SimpleStore file will be migrated, if configured.
+func parseStore(conf coreConf) (store core.Store, err error) {
+	switch conf.StoreBackend {
+	case "", "simple":
+		if conf.MigrateFrom != "" {
+			err = fmt.Errorf("core.migrate-from is only supported for the bolt backend")
+			return
+		}
+
+		store, err = core.NewSimpleStore(conf.Store)
+
+	case "bolt":
+		var boltStore *core.BoltStore
+		if boltStore, err = core.NewBoltStore(conf.Store); err != nil {
+			return
+		}
+		store = boltStore
+
+		if conf.MigrateFrom == "" {
+			return
+		}
+
+		if _, statErr := os.Stat(conf.MigrateFrom); os.IsNotExist(statErr) {
+			return
+		}
+
+		var migrated int
+		if migrated, err = core.MigrateSimpleStore(conf.MigrateFrom, boltStore); err != nil {
+			boltStore.Close()
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"from":    conf.MigrateFrom,
+			"to":      conf.Store,
+			"bundles": migrated,
+		}).Info("Migrated SimpleStore")
+
+	default:
+		err = fmt.Errorf("Unknown core.store-backend \"%s\"", conf.StoreBackend)
+	}
+
+	return
+}
+
+// parseRouting creates the RoutingAlgorithm of the Routing-configuration
+// block. A nil RoutingAlgorithm keeps the Core's default.
+func parseRouting(conf routingConf, c *core.Core, nodeId bundle.EndpointID) (core.RoutingAlgorithm, error) {
+	switch conf.Algorithm {
+	case "", "epidemic":
+		return nil, nil
+
+	case "prophet":
+		if nodeId == bundle.DtnNone() {
+			return nil, fmt.Errorf("routing.algorithm \"prophet\" requires core.node-id")
+		}
+
+		return core.NewProphetRouting(c, nodeId, core.DefaultProphetConfig())
+
+	case "spray-and-wait":
+		if conf.Copies == 0 {
+			return nil, fmt.Errorf("routing.algorithm \"spray-and-wait\" requires routing.copies")
+		}
+
+		return core.NewSprayAndWaitRouting(c, conf.Copies), nil
+
+	default:
+		return nil, fmt.Errorf("Unknown routing.algorithm \"%s\"", conf.Algorithm)
+	}
+}
+
+// parseRoute creates a StaticRoute of the given route configuration.
+func parseRoute(conf routeConf) (core.StaticRoute, error) {
+	if conf.Destination == "" {
+		return core.StaticRoute{}, fmt.Errorf("route.destination is empty")
+	}
+
+	nextHop, err := bundle.NewEndpointID(conf.NextHop)
+	if err != nil {
+		return core.StaticRoute{}, err
+	}
+
+	return core.NewStaticRoute(conf.Destination, nextHop, conf.Priority, conf.Fallback)
+}
+
 func parseSimpleRESTAppAgent(conf simpleRestConf, c *core.Core) (core.ApplicationAgent, error) {
 	endpointID, err := bundle.NewEndpointID(conf.Node)
 	if err != nil {
@@ -109,18 +372,84 @@ func parseSimpleRESTAppAgent(conf simpleRestConf, c *core.Core) (core.Applicatio
 	return core.NewSimpleRESTAppAgent(endpointID, c, conf.Listen), nil
 }
 
-// parseCore creates the Core based on the given TOML configuration.
-func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, err error) {
-	var conf tomlConfig
-	if _, err = toml.DecodeFile(filename, &conf); err != nil {
+func parseWebSocketAgent(conf webSocketConf, c *core.Core) (core.ApplicationAgent, error) {
+	endpointID, err := bundle.NewEndpointID(conf.Node)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewWebSocketAgent(endpointID, c, conf.Listen), nil
+}
+
+// parsePayloadSpool configures the directory for payload files, which is
+// created if necessary.
+func parsePayloadSpool(conf coreConf) error {
+	if conf.PayloadDir == "" {
+		return bundle.SetPayloadSpool("", 0)
+	}
+
+	if err := os.MkdirAll(conf.PayloadDir, 0700); err != nil {
+		return err
+	}
+
+	var threshold = conf.PayloadThreshold
+	if threshold == 0 {
+		threshold = defaultPayloadThreshold
+	}
+
+	return bundle.SetPayloadSpool(conf.PayloadDir, threshold)
+}
+
+// parseQuota creates the StorageQuota based on the given configuration.
+func parseQuota(conf quotaConf) (quota core.StorageQuota, err error) {
+	if conf.MaxSourceShare < 0 || conf.MaxSourceShare > 1 {
+		err = fmt.Errorf("Quota's max-source-share %f is not between 0 and 1", conf.MaxSourceShare)
 		return
 	}
 
-	// Logging
-	if conf.Logging.Level != "" {
-		if lvl, err := log.ParseLevel(conf.Logging.Level); err != nil {
+	quota = core.StorageQuota{
+		MaxBytes:       conf.MaxBytes,
+		MaxBundles:     conf.MaxBundles,
+		MaxSourceShare: conf.MaxSourceShare,
+		Evict:          conf.Evict,
+	}
+	return
+}
+
+// parseMetrics serves the Core's metrics on the configured address's /metrics.
+func parseMetrics(conf metricsConf, c *core.Core) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", c.MetricsHandler())
+
+	go func() {
+		if err := http.ListenAndServe(conf.Listen, mux); err != nil {
 			log.WithFields(log.Fields{
-				"level":    conf.Logging.Level,
+				"listen": conf.Listen,
+				"error":  err,
+			}).Warn("Serving metrics failed")
+		}
+	}()
+}
+
+// parseAdminAPI starts the AdminAPI. New peers are created like configured
+// "peer" blocks.
+func parseAdminAPI(conf adminConf, c *core.Core, nodeId bundle.EndpointID) {
+	core.NewAdminAPI(c, conf.Listen, func(req core.AdminPeerRequest) (cla.ConvergenceSender, error) {
+		return parsePeer(convergenceConf{
+			Node:          req.Node,
+			Protocol:      req.Protocol,
+			Endpoint:      req.Endpoint,
+			MaxBundleSize: req.MaxBundleSize,
+		}, nodeId, c)
+	})
+}
+
+// parseLogging applies the Logging-configuration block.
+func parseLogging(conf logConf) {
+	if conf.Level != "" {
+		if lvl, err := log.ParseLevel(conf.Level); err != nil {
+			log.WithFields(log.Fields{
+				"level":    conf.Level,
 				"error":    err,
 				"provided": "panic,fatal,error,warn,info,debug,trace",
 			}).Warn("Failed to set log level. Please select one of the provided ones")
@@ -129,9 +458,9 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 		}
 	}
 
-	log.SetReportCaller(conf.Logging.ReportCaller)
+	log.SetReportCaller(conf.ReportCaller)
 
-	switch conf.Logging.Format {
+	switch conf.Format {
 	case "", "text":
 		log.SetFormatter(&log.TextFormatter{
 			DisableTimestamp: true,
@@ -143,8 +472,18 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 	default:
 		log.Warn("Unknown logging format")
 	}
+}
 
-	var discoveryMsgs []discovery.DiscoveryMessage
+// parseCore creates the Core based on the given TOML configuration and returns
+// the daemon, managing the Core and its reloadable parts.
+func parseCore(filename string) (d *daemon, err error) {
+	var conf tomlConfig
+	if _, err = toml.DecodeFile(filename, &conf); err != nil {
+		return
+	}
+
+	// Logging
+	parseLogging(conf.Logging)
 
 	// Core
 	if conf.Core.Store == "" {
@@ -152,10 +491,90 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 		return
 	}
 
-	c, err = core.NewCore(conf.Core.Store, conf.Core.InspectAllBundles)
+	var nodeId = bundle.DtnNone()
+	if conf.Core.NodeId != "" {
+		if nodeId, err = bundle.NewEndpointID(conf.Core.NodeId); err != nil {
+			return
+		}
+	}
+
+	if err = parsePayloadSpool(conf.Core); err != nil {
+		return
+	}
+
+	if conf.Core.MaxBlockSize > 0 {
+		bundle.SetMaxBlockSize(conf.Core.MaxBlockSize)
+	}
+
+	store, err := parseStore(conf.Core)
+	if err != nil {
+		return
+	}
+
+	var c = core.NewCoreWithStore(store, conf.Core.InspectAllBundles)
+	c.SetNodeId(nodeId)
+	c.SetClockless(conf.Core.Clockless)
+
+	// Quota
+	quota, err := parseQuota(conf.Quota)
 	if err != nil {
 		return
 	}
+	c.SetStorageQuota(quota)
+
+	// Routing
+	routing, err := parseRouting(conf.Routing, c, nodeId)
+	if err != nil {
+		return
+	}
+
+	// Route/StaticRouting, wrapping the configured RoutingAlgorithm
+	var staticRouting *core.StaticRouting
+	if len(conf.Route) > 0 {
+		if routing == nil {
+			routing = core.NewEpidemicRouting(c, false)
+		}
+
+		staticRouting = core.NewStaticRouting(c, routing)
+		for _, routeConf := range conf.Route {
+			var route core.StaticRoute
+			if route, err = parseRoute(routeConf); err != nil {
+				return
+			}
+
+			staticRouting.AddRoute(route)
+		}
+
+		routing = staticRouting
+	}
+
+	if routing != nil {
+		c.SetRoutingAlgorithm(routing)
+	}
+
+	// Integrity/BPSec Block Integrity Blocks
+	for _, integrity := range conf.Integrity {
+		var ctx bundle.IntegrityContext
+
+		ctx, err = parseIntegrity(integrity)
+		if err != nil {
+			return
+		}
+
+		c.RegisterIntegrityContext(ctx)
+	}
+
+	// Confidentiality/BPSec Block Confidentiality Blocks
+	for _, confidentiality := range conf.Confidentiality {
+		var ctx bundle.ConfidentialityContext
+
+		ctx, err = parseConfidentiality(confidentiality)
+		if err != nil {
+			return
+		}
+
+		c.RegisterConfidentialityContext(ctx)
+	}
 
 	// SimpleREST (srest)
 	if conf.SimpleRest != (simpleRestConf{}) {
@@ -168,43 +587,38 @@ func parseCore(filename string) (c *core.Core, ds *discovery.DiscoveryService, e
 		}
 	}
 
-	// Listen/ConvergenceReceiver
-	for _, conv := range conf.Listen {
-		var convRec cla.ConvergenceReceiver
-		var discoMsg discovery.DiscoveryMessage
-
-		convRec, discoMsg, err = parseListen(conv)
-		if err != nil {
-			return
+	// WebSocket
+	if conf.WebSocket != (webSocketConf{}) {
+		if aa, err := parseWebSocketAgent(conf.WebSocket, c); err == nil {
+			c.RegisterApplicationAgent(aa)
+		} else {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Warn("Failed to register WebSocketAgent")
 		}
+	}
 
-		discoveryMsgs = append(discoveryMsgs, discoMsg)
+	// Metrics
+	if conf.Metrics.Listen != "" {
+		parseMetrics(conf.Metrics, c)
+	}
 
-		c.RegisterConvergence(convRec)
+	// Admin
+	if conf.Admin.Listen != "" {
+		parseAdminAPI(conf.Admin, c, nodeId)
 	}
 
-	// Peer/ConvergenceSender
-	for _, conv := range conf.Peer {
-		convRec, err := parsePeer(conv)
-		if err != nil {
-			log.WithFields(log.Fields{
-				"peer":  conv.Endpoint,
-				"error": err,
-			}).Warn("Failed to establish a connection to a peer")
-			continue
-		}
+	// Listen/ConvergenceReceiver, Peer/ConvergenceSender and Discovery
+	d = newDaemon(filename, conf, c, nodeId)
+	d.staticRouting = staticRouting
 
-		c.RegisterConvergence(convRec)
+	var listens map[convergenceConf]listen
+	if listens, err = d.newListens(conf); err != nil {
+		return
 	}
 
-	// Discovery
-	if conf.Discovery.IPv4 || conf.Discovery.IPv6 {
-		ds, err = discovery.NewDiscoveryService(
-			discoveryMsgs, c, conf.Discovery.IPv4, conf.Discovery.IPv6)
-		if err != nil {
-			return
-		}
-	}
+	d.updateConvergences(conf, listens)
 
+	err = d.updateDiscovery(conf)
 	return
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/BurntSushi/toml"
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+	"github.com/geistesk/dtn7/core"
+	"github.com/geistesk/dtn7/discovery"
+)
+
+// daemon bundles the running Core together with its parts, which might be
+// changed by reloading the configuration: the CLAs of "listen" and "peer"
+// blocks, the StaticRouting's routes, the DiscoveryService and the logging.
+type daemon struct {
+	filename string
+	conf     tomlConfig
+
+	c      *core.Core
+	ds     *discovery.DiscoveryService
+	nodeId bundle.EndpointID
+
+	// staticRouting is nil if the Core was started without "route" blocks.
+	staticRouting *core.StaticRouting
+
+	listens     map[convergenceConf]cla.ConvergenceReceiver
+	discoveries map[convergenceConf]discovery.DiscoveryMessage
+	peers       map[convergenceConf]cla.ConvergenceSender
+}
+
+// newDaemon creates a daemon for the Core, which was created based on the
+// configuration. Neither CLAs nor a DiscoveryService are started.
+func newDaemon(filename string, conf tomlConfig, c *core.Core, nodeId bundle.EndpointID) *daemon {
+	return &daemon{
+		filename: filename,
+		conf:     conf,
+
+		c:      c,
+		nodeId: nodeId,
+
+		listens:     make(map[convergenceConf]cla.ConvergenceReceiver),
+		discoveries: make(map[convergenceConf]discovery.DiscoveryMessage),
+		peers:       make(map[convergenceConf]cla.ConvergenceSender),
+	}
+}
+
+// containsConvergenceConf checks if the convergenceConf is part of the list.
+func containsConvergenceConf(convs []convergenceConf, conv convergenceConf) bool {
+	for _, c := range convs {
+		if c == conv {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listen is a created, but not yet started, ConvergenceReceiver of a "listen"
+// block together with its DiscoveryMessage.
+type listen struct {
+	convRec  cla.ConvergenceReceiver
+	discoMsg discovery.DiscoveryMessage
+}
+
+// newListens creates the ConvergenceReceivers of those "listen" blocks, which
+// are not running yet. None of them is started, so an erroneous block leaves
+// the running CLAs untouched.
+func (d *daemon) newListens(conf tomlConfig) (map[convergenceConf]listen, error) {
+	var listens = make(map[convergenceConf]listen)
+	for _, conv := range conf.Listen {
+		if _, ok := d.listens[conv]; ok {
+			continue
+		}
+
+		convRec, discoMsg, err := parseListen(conv)
+		if err != nil {
+			return nil, err
+		}
+
+		listens[conv] = listen{convRec, discoMsg}
+	}
+
+	return listens, nil
+}
+
+// updateConvergences removes the CLAs of "listen" and "peer" blocks, which are
+// not part of the configuration anymore, and starts the new ones. The new
+// ConvergenceReceivers must be created by newListens before. Unchanged CLAs are
+// left untouched.
+func (d *daemon) updateConvergences(conf tomlConfig, listens map[convergenceConf]listen) {
+	// Listen/ConvergenceReceiver
+	for conv, convRec := range d.listens {
+		if !containsConvergenceConf(conf.Listen, conv) {
+			d.c.CloseConvergence(convRec)
+			delete(d.listens, conv)
+			delete(d.discoveries, conv)
+		}
+	}
+
+	for conv, l := range listens {
+		d.listens[conv] = l.convRec
+		d.discoveries[conv] = l.discoMsg
+
+		d.c.RegisterConvergence(l.convRec)
+	}
+
+	// Peer/ConvergenceSender
+	for conv, convSender := range d.peers {
+		if !containsConvergenceConf(conf.Peer, conv) {
+			d.c.CloseConvergence(convSender)
+			delete(d.peers, conv)
+		}
+	}
+
+	for _, conv := range conf.Peer {
+		if _, ok := d.peers[conv]; ok {
+			continue
+		}
+
+		convSender, err := parsePeer(conv, d.nodeId, d.c)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"peer":  conv.Endpoint,
+				"error": err,
+			}).Warn("Failed to establish a connection to a peer")
+			continue
+		}
+
+		d.peers[conv] = convSender
+
+		d.c.RegisterConvergence(convSender)
+	}
+}
+
+// parseRoutes creates the StaticRoutes of the "route" blocks.
+func parseRoutes(conf tomlConfig) ([]core.StaticRoute, error) {
+	var routes = make([]core.StaticRoute, len(conf.Route))
+	for i, routeConf := range conf.Route {
+		route, err := parseRoute(routeConf)
+		if err != nil {
+			return nil, err
+		}
+
+		routes[i] = route
+	}
+
+	return routes, nil
+}
+
+// updateRoutes replaces the StaticRouting's routes by the given ones, created
+// by parseRoutes. Unchanged routes are replaced in place, so that no bundle
+// misses them.
+func (d *daemon) updateRoutes(routes []core.StaticRoute) {
+	for _, oldRoute := range d.staticRouting.Routes() {
+		var kept = false
+		for _, route := range routes {
+			if route.Pattern == oldRoute.Pattern && route.NextHop == oldRoute.NextHop {
+				kept = true
+				break
+			}
+		}
+
+		if !kept {
+			d.staticRouting.RemoveRoute(oldRoute.Pattern, oldRoute.NextHop)
+		}
+	}
+
+	for _, route := range routes {
+		d.staticRouting.AddRoute(route)
+	}
+}
+
+// updateDiscovery (re)starts the DiscoveryService, announcing the current
+// "listen" blocks. A running DiscoveryService is stopped before.
+func (d *daemon) updateDiscovery(conf tomlConfig) (err error) {
+	if d.ds != nil {
+		d.ds.Close()
+		d.ds = nil
+	}
+
+	if !conf.Discovery.IPv4 && !conf.Discovery.IPv6 {
+		return
+	}
+
+	var discoveryMsgs []discovery.DiscoveryMessage
+	for _, conv := range conf.Listen {
+		if discoMsg, ok := d.discoveries[conv]; ok {
+			discoveryMsgs = append(discoveryMsgs, discoMsg)
+		}
+	}
+
+	d.ds, err = discovery.NewDiscoveryService(
+		discoveryMsgs, d.c, d.nodeId, conf.Discovery.IPv4, conf.Discovery.IPv6)
+	return
+}
+
+// reload re-reads the configuration file and applies the changes of the
+// logging, quota, "route", "listen", "peer" and discovery blocks. Changes of
+// other blocks and "route" blocks of a Core started without those require a
+// restart and are only reported. The store and unchanged CLAs are not
+// affected.
+//
+// All blocks are parsed before any change is applied, so an erroneous
+// configuration leaves the daemon untouched. If only the DiscoveryService
+// fails to start, the other changes are kept and the discovery is considered
+// disabled, so that the next reload tries to start it again.
+func (d *daemon) reload() error {
+	var conf tomlConfig
+	if _, err := toml.DecodeFile(d.filename, &conf); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"config": d.filename,
+	}).Info("Reloading configuration")
+
+	var quotaChanged = conf.Quota != d.conf.Quota
+	var quota core.StorageQuota
+	if quotaChanged {
+		var err error
+		if quota, err = parseQuota(conf.Quota); err != nil {
+			return err
+		}
+	}
+
+	var routesChanged = !reflect.DeepEqual(conf.Route, d.conf.Route)
+	var routes []core.StaticRoute
+	if routesChanged && d.staticRouting != nil {
+		var err error
+		if routes, err = parseRoutes(conf); err != nil {
+			return err
+		}
+	}
+
+	listens, err := d.newListens(conf)
+	if err != nil {
+		return err
+	}
+
+	if conf.Logging != d.conf.Logging {
+		parseLogging(conf.Logging)
+	}
+
+	if quotaChanged {
+		d.c.SetStorageQuota(quota)
+	}
+
+	if routesChanged && d.staticRouting != nil {
+		d.updateRoutes(routes)
+	}
+
+	var restartRequired = map[string]bool{
+		"core":            conf.Core != d.conf.Core,
+		"routing":         conf.Routing != d.conf.Routing,
+		"route":           routesChanged && d.staticRouting == nil,
+		"simple-rest":     conf.SimpleRest != d.conf.SimpleRest,
+		"websocket":       conf.WebSocket != d.conf.WebSocket,
+		"metrics":         conf.Metrics != d.conf.Metrics,
+		"admin":           conf.Admin != d.conf.Admin,
+		"integrity":       !reflect.DeepEqual(conf.Integrity, d.conf.Integrity),
+		"confidentiality": !reflect.DeepEqual(conf.Confidentiality, d.conf.Confidentiality),
+	}
+	for block, changed := range restartRequired {
+		if changed {
+			log.WithFields(log.Fields{
+				"block": block,
+			}).Warn("Configuration block changed, but requires a restart to be applied")
+		}
+	}
+
+	var listensChanged = !reflect.DeepEqual(conf.Listen, d.conf.Listen)
+
+	d.updateConvergences(conf, listens)
+
+	if listensChanged || conf.Discovery != d.conf.Discovery {
+		err = d.updateDiscovery(conf)
+		if err != nil {
+			conf.Discovery = discoveryConf{}
+		}
+	}
+
+	d.conf = conf
+	return err
+}
+
+// close shuts the DiscoveryService and the Core down.
+func (d *daemon) close() {
+	if d.ds != nil {
+		d.ds.Close()
+	}
+
+	d.c.Close()
+}
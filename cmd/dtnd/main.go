@@ -3,23 +3,28 @@ package main
 import (
 	"os"
 	"os/signal"
+	"syscall"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// waitSigint blocks the current thread until a SIGINT appears.
-func waitSigint() {
-	signalSyn := make(chan os.Signal)
-	signalAck := make(chan struct{})
-
-	signal.Notify(signalSyn, os.Interrupt)
-
-	go func() {
-		<-signalSyn
-		close(signalAck)
-	}()
-
-	<-signalAck
+// waitSignals blocks the current thread until a SIGINT appears. Each SIGHUP
+// reloads the daemon's configuration.
+func waitSignals(d *daemon) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
+
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			return
+		}
+
+		if err := d.reload(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Warn("Failed to reload config")
+		}
+	}
 }
 
 func main() {
@@ -27,19 +32,15 @@ func main() {
 		log.Fatalf("Usage: %s configuration.toml", os.Args[0])
 	}
 
-	core, discovery, err := parseCore(os.Args[1])
+	d, err := parseCore(os.Args[1])
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err,
 		}).Fatal("Failed to parse config")
 	}
 
-	waitSigint()
+	waitSignals(d)
 	log.Info("Shutting down..")
 
-	core.Close()
-
-	if discovery != nil {
-		discovery.Close()
-	}
+	d.close()
 }
@@ -0,0 +1,343 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+
+	"github.com/geistesk/dtn7/cla"
+)
+
+// AdminBundle describes a stored bundle pack for the AdminAPI.
+type AdminBundle struct {
+	ID           string
+	Source       string
+	Destination  string
+	Receiver     string
+	PreviousNode string
+	Constraints  []string
+	Tombstone    bool
+	Expires      string
+}
+
+// newAdminBundle creates an AdminBundle for the given BundlePack.
+func newAdminBundle(c *Core, bp BundlePack) AdminBundle {
+	var constraints = make([]string, 0, len(bp.Constraints))
+	for constraint := range bp.Constraints {
+		constraints = append(constraints, constraint.String())
+	}
+	sort.Strings(constraints)
+
+	return AdminBundle{
+		ID:           bp.Bundle.ID(),
+		Source:       bp.Bundle.PrimaryBlock.SourceNode.String(),
+		Destination:  bp.Bundle.PrimaryBlock.Destination.String(),
+		Receiver:     bp.Receiver.String(),
+		PreviousNode: bp.PreviousNode.String(),
+		Constraints:  constraints,
+		Tombstone:    bp.Tombstone,
+		Expires:      c.expiration(bp).Format(time.RFC3339),
+	}
+}
+
+// AdminCLA describes a registered or enqueued CLA for the AdminAPI. The TTL is
+// the number of remaining start attempts of an enqueued CLA.
+type AdminCLA struct {
+	Address   string
+	Endpoint  string
+	Receiver  bool
+	Sender    bool
+	Permanent bool
+	TTL       uint
+}
+
+// newAdminCLA creates an AdminCLA for the given Convergence.
+func newAdminCLA(conv cla.Convergence) AdminCLA {
+	var ac = AdminCLA{
+		Address:   conv.Address(),
+		Permanent: conv.IsPermanent(),
+	}
+
+	if rec, ok := conv.(cla.ConvergenceReceiver); ok {
+		ac.Receiver = true
+		ac.Endpoint = rec.GetEndpointID().String()
+	}
+
+	if sender, ok := conv.(cla.ConvergenceSender); ok {
+		ac.Sender = true
+		ac.Endpoint = sender.GetPeerEndpointID().String()
+	}
+
+	return ac
+}
+
+// AdminCLAs lists the registered ConvergenceReceivers and ConvergenceSenders
+// and the queue of CLAs waiting for their (re)start.
+type AdminCLAs struct {
+	Receivers []AdminCLA
+	Senders   []AdminCLA
+	Queue     []AdminCLA
+}
+
+// AdminPeerRequest describes a new peer, added by the AdminAPI. The fields
+// equal dtnd's peer configuration.
+type AdminPeerRequest struct {
+	Node          string
+	Protocol      string
+	Endpoint      string
+	MaxBundleSize uint
+}
+
+// AdminResponse is the AdminAPI's response for requests without any other
+// result. The Error field is empty on success.
+type AdminResponse struct {
+	Error string
+}
+
+// AdminPeerFactory creates a ConvergenceSender for an AdminPeerRequest. Its
+// implementation is left to the program using the AdminAPI, e.g., dtnd.
+type AdminPeerFactory func(req AdminPeerRequest) (cla.ConvergenceSender, error)
+
+// AdminAPI is a HTTP API to inspect and manage a running Core. All requests
+// and responses are JSON encoded.
+//
+//	GET    /bundles/              lists all stored bundle packs as AdminBundles
+//	GET    /bundles/?id={id}      returns the AdminBundle of the bundle ID
+//	DELETE /bundles/?id={id}      deletes the bundle of the bundle ID
+//	GET    /clas/                 returns the registered and enqueued CLAs as AdminCLAs
+//	POST   /peers/                adds a peer, described by an AdminPeerRequest
+//	DELETE /peers/?address={addr} closes and removes the CLA of the address
+//
+// Bundle IDs and addresses are passed as query parameters because both might
+// contain slashes. Adding peers requires an AdminPeerFactory.
+type AdminAPI struct {
+	c           *Core
+	peerFactory AdminPeerFactory
+
+	serv *http.Server
+}
+
+// NewAdminAPI creates a new AdminAPI for the Core, bound to the address. The
+// peerFactory might be nil, which disables the creation of new peers.
+func NewAdminAPI(c *Core, addr string, peerFactory AdminPeerFactory) (api *AdminAPI) {
+	api = &AdminAPI{
+		c:           c,
+		peerFactory: peerFactory,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bundles/", api.handleBundles)
+	mux.HandleFunc("/clas/", api.handleCLAs)
+	mux.HandleFunc("/peers/", api.handlePeers)
+
+	api.serv = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		if err := api.serv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithFields(log.Fields{
+				"listen": addr,
+				"error":  err,
+			}).Warn("Serving the AdminAPI failed")
+		}
+	}()
+
+	return
+}
+
+// Close shuts this AdminAPI's HTTP server down.
+func (api *AdminAPI) Close() error {
+	return api.serv.Close()
+}
+
+// respond writes the JSON encoded value with the given HTTP status code.
+func (api *AdminAPI) respond(respWriter http.ResponseWriter, status int, v interface{}) {
+	respWriter.Header().Set("Content-Type", "application/json")
+	respWriter.WriteHeader(status)
+
+	if err := codec.NewEncoder(respWriter, new(codec.JsonHandle)).Encode(v); err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("AdminAPI failed to encode response")
+	}
+}
+
+// respondError writes an AdminResponse with the error message.
+func (api *AdminAPI) respondError(respWriter http.ResponseWriter, req *http.Request, status int, msg string) {
+	log.WithFields(log.Fields{
+		"method": req.Method,
+		"path":   req.URL.Path,
+		"error":  msg,
+	}).Warn("AdminAPI request errored")
+
+	api.respond(respWriter, status, AdminResponse{Error: msg})
+}
+
+func (api *AdminAPI) handleBundles(respWriter http.ResponseWriter, req *http.Request) {
+	var id = req.URL.Query().Get("id")
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		var bps = api.c.store.Query(func(_ BundlePack) bool { return true })
+		sort.Slice(bps, func(i, j int) bool {
+			return bps[i].Bundle.ID() < bps[j].Bundle.ID()
+		})
+
+		var abs = make([]AdminBundle, 0, len(bps))
+		for _, bp := range bps {
+			abs = append(abs, newAdminBundle(api.c, bp))
+		}
+
+		api.respond(respWriter, http.StatusOK, abs)
+
+	case id != "" && req.Method == http.MethodGet:
+		bp, ok := LookupBundle(api.c.store, id)
+		if !ok {
+			api.respondError(respWriter, req, http.StatusNotFound, "Unknown bundle ID")
+			return
+		}
+
+		api.respond(respWriter, http.StatusOK, newAdminBundle(api.c, bp))
+
+	case id != "" && req.Method == http.MethodDelete:
+		bp, ok := LookupBundle(api.c.store, id)
+		if !ok {
+			api.respondError(respWriter, req, http.StatusNotFound, "Unknown bundle ID")
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Info("AdminAPI requested bundle deletion")
+
+		api.c.stopCustodyTimer(id)
+		if !bp.Tombstone {
+			api.c.bundleDeletion(bp, NoInformation)
+		}
+
+		api.respond(respWriter, http.StatusOK, AdminResponse{})
+
+	default:
+		api.respondError(respWriter, req, http.StatusMethodNotAllowed, "Unsupported request")
+	}
+}
+
+func (api *AdminAPI) handleCLAs(respWriter http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		api.respondError(respWriter, req, http.StatusMethodNotAllowed, "Unsupported request")
+		return
+	}
+
+	var acs = AdminCLAs{
+		Receivers: make([]AdminCLA, 0),
+		Senders:   make([]AdminCLA, 0),
+		Queue:     make([]AdminCLA, 0),
+	}
+
+	api.c.convergenceMutex.Lock()
+	for _, rec := range api.c.convergenceReceivers {
+		acs.Receivers = append(acs.Receivers, newAdminCLA(rec))
+	}
+	for _, sender := range api.c.convergenceSenders {
+		acs.Senders = append(acs.Senders, newAdminCLA(sender))
+	}
+	for _, cqe := range api.c.convergenceQueue {
+		var ac = newAdminCLA(cqe.conv)
+		ac.TTL = cqe.ttl
+		acs.Queue = append(acs.Queue, ac)
+	}
+	api.c.convergenceMutex.Unlock()
+
+	api.respond(respWriter, http.StatusOK, acs)
+}
+
+func (api *AdminAPI) handlePeers(respWriter http.ResponseWriter, req *http.Request) {
+	var addr = req.URL.Query().Get("address")
+
+	switch {
+	case addr == "" && req.Method == http.MethodPost:
+		if api.peerFactory == nil {
+			api.respondError(respWriter, req, http.StatusNotImplemented, "Adding peers is not supported")
+			return
+		}
+
+		var peerReq AdminPeerRequest
+		if err := codec.NewDecoder(req.Body, new(codec.JsonHandle)).Decode(&peerReq); err != nil {
+			api.respondError(respWriter, req, http.StatusBadRequest, "Failed to parse request")
+			return
+		}
+
+		conv, err := api.peerFactory(peerReq)
+		if err != nil {
+			api.respondError(respWriter, req, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"cla": conv,
+		}).Info("AdminAPI requested new peer")
+
+		api.c.RegisterConvergence(conv)
+		api.respond(respWriter, http.StatusOK, AdminResponse{})
+
+	case addr != "" && req.Method == http.MethodDelete:
+		if !api.c.removeConvergenceAddress(addr) {
+			api.respondError(respWriter, req, http.StatusNotFound,
+				fmt.Sprintf("Unknown CLA address %s", addr))
+			return
+		}
+
+		api.respond(respWriter, http.StatusOK, AdminResponse{})
+
+	default:
+		api.respondError(respWriter, req, http.StatusMethodNotAllowed, "Unsupported request")
+	}
+}
+
+// removeConvergenceAddress closes and removes all registered or enqueued CLAs
+// of the given address. False is returned if there was no such CLA.
+func (c *Core) removeConvergenceAddress(addr string) bool {
+	var convs []cla.Convergence
+
+	c.convergenceMutex.Lock()
+	for _, rec := range c.convergenceReceivers {
+		if rec.Address() == addr {
+			convs = append(convs, rec)
+		}
+	}
+	for _, sender := range c.convergenceSenders {
+		if sender.Address() == addr {
+			convs = appendConvergence(convs, sender)
+		}
+	}
+	for _, cqe := range c.convergenceQueue {
+		if cqe.conv.Address() == addr {
+			convs = appendConvergence(convs, cqe.conv)
+		}
+	}
+	c.convergenceMutex.Unlock()
+
+	for _, conv := range convs {
+		c.CloseConvergence(conv)
+	}
+
+	return len(convs) > 0
+}
+
+// appendConvergence appends the Convergence, if it is not already contained. A
+// CLA might be both a ConvergenceReceiver and a ConvergenceSender.
+func appendConvergence(convs []cla.Convergence, conv cla.Convergence) []cla.Convergence {
+	for _, known := range convs {
+		if known == conv {
+			return convs
+		}
+	}
+
+	return append(convs, conv)
+}
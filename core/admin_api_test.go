@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// adminRequest performs a request against the AdminAPI and decodes its JSON
+// response into v.
+func adminRequest(t *testing.T, api *AdminAPI, method, target string, body []byte, v interface{}) int {
+	var req = httptest.NewRequest(method, target, bytes.NewReader(body))
+	var rec = httptest.NewRecorder()
+
+	api.serv.Handler.ServeHTTP(rec, req)
+
+	if err := codec.NewDecoder(rec.Body, new(codec.JsonHandle)).Decode(v); err != nil {
+		t.Fatalf("Decoding %s %s's response failed: %v", method, target, err)
+	}
+
+	return rec.Code
+}
+
+func TestAdminAPI(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var peer = newPeerSender("dtn://beta/")
+	var api = NewAdminAPI(c, "127.0.0.1:0", func(req AdminPeerRequest) (cla.ConvergenceSender, error) {
+		return peer, nil
+	})
+	defer api.Close()
+
+	// Bundles
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn://gamma/"),
+			bundle.MustNewEndpointID("dtn://alpha/"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewBundlePack(bndl))
+
+	var abs []AdminBundle
+	if code := adminRequest(t, api, http.MethodGet, "/bundles/", nil, &abs); code != http.StatusOK {
+		t.Fatalf("Listing bundles resulted in %d", code)
+	}
+	if len(abs) != 1 || abs[0].ID != bndl.ID() || len(abs[0].Constraints) != 2 {
+		t.Fatalf("Listed bundles are %v", abs)
+	}
+
+	var bundleTarget = "/bundles/?id=" + url.QueryEscape(bndl.ID())
+
+	var ab AdminBundle
+	if code := adminRequest(t, api, http.MethodGet, bundleTarget, nil, &ab); code != http.StatusOK {
+		t.Fatalf("Showing bundle resulted in %d", code)
+	}
+	if ab.Destination != "dtn://gamma/" {
+		t.Fatalf("Shown bundle is %v", ab)
+	}
+
+	var resp AdminResponse
+	if code := adminRequest(t, api, http.MethodDelete, bundleTarget, nil, &resp); code != http.StatusOK {
+		t.Fatalf("Deleting bundle resulted in %d: %v", code, resp)
+	}
+	if len(QueryAll(c.store)) != 0 {
+		t.Fatalf("Deleted bundle has still constraints")
+	}
+
+	if code := adminRequest(t, api, http.MethodGet, "/bundles/?id=unknown", nil, &resp); code != http.StatusNotFound {
+		t.Fatalf("Showing an unknown bundle resulted in %d", code)
+	}
+
+	// Peers and CLAs
+	var peerReq []byte
+	codec.NewEncoderBytes(&peerReq, new(codec.JsonHandle)).Encode(AdminPeerRequest{
+		Node:     "dtn://beta/",
+		Protocol: "dummy",
+		Endpoint: "localhost",
+	})
+
+	if code := adminRequest(t, api, http.MethodPost, "/peers/", peerReq, &resp); code != http.StatusOK {
+		t.Fatalf("Adding peer resulted in %d: %v", code, resp)
+	}
+
+	var acs AdminCLAs
+	if code := adminRequest(t, api, http.MethodGet, "/clas/", nil, &acs); code != http.StatusOK {
+		t.Fatalf("Listing CLAs resulted in %d", code)
+	}
+	if len(acs.Senders) != 1 || acs.Senders[0].Address != peer.Address() || acs.Senders[0].Endpoint != "dtn://beta/" {
+		t.Fatalf("Listed CLAs are %v", acs)
+	}
+
+	var peerTarget = "/peers/?address=" + url.QueryEscape(peer.Address())
+	if code := adminRequest(t, api, http.MethodDelete, peerTarget, nil, &resp); code != http.StatusOK {
+		t.Fatalf("Removing peer resulted in %d: %v", code, resp)
+	}
+	if code := adminRequest(t, api, http.MethodDelete, peerTarget, nil, &resp); code != http.StatusNotFound {
+		t.Fatalf("Removing an unknown peer resulted in %d", code)
+	}
+
+	adminRequest(t, api, http.MethodGet, "/clas/", nil, &acs)
+	if len(acs.Senders) != 0 {
+		t.Fatalf("Removed peer is still listed: %v", acs)
+	}
+}
@@ -2,19 +2,29 @@ package core
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 
 	"github.com/geistesk/dtn7/bundle"
 	"github.com/ugorji/go/codec"
 )
 
 // AdministrativeRecordTypeCode specifies the type of an AdministrativeRecord.
-// However, currently the Bundle Status Report is the only known type.
+// Further types might be added by RegisterAdministrativeRecordType.
 type AdministrativeRecordTypeCode uint
 
 const (
 	// BundleStatusReportTypeCode is the Bundle Status Report's type code, used in
 	// its parent Administrative Record.
 	BundleStatusReportTypeCode AdministrativeRecordTypeCode = 1
+
+	// CustodySignalTypeCode is the Custody Signal's type code, used in its parent
+	// Administrative Record. This type code was defined in RFC 5050.
+	CustodySignalTypeCode AdministrativeRecordTypeCode = 2
+
+	// BIBEProtocolDataUnitTypeCode is the BIBE Protocol Data Unit's type code,
+	// used in its parent Administrative Record.
+	BIBEProtocolDataUnitTypeCode AdministrativeRecordTypeCode = 3
 )
 
 func (artc AdministrativeRecordTypeCode) String() string {
@@ -22,32 +32,95 @@ func (artc AdministrativeRecordTypeCode) String() string {
 	case BundleStatusReportTypeCode:
 		return "bundle status report"
 
+	case CustodySignalTypeCode:
+		return "custody signal"
+
+	case BIBEProtocolDataUnitTypeCode:
+		return "BIBE protocol data unit"
+
 	default:
 		return "unknown"
 	}
 }
 
+var (
+	administrativeRecordTypes = map[AdministrativeRecordTypeCode]reflect.Type{
+		BundleStatusReportTypeCode:   reflect.TypeOf(StatusReport{}),
+		CustodySignalTypeCode:        reflect.TypeOf(CustodySignal{}),
+		BIBEProtocolDataUnitTypeCode: reflect.TypeOf(BIBEProtocolDataUnit{}),
+	}
+	administrativeRecordTypesMutex sync.RWMutex
+)
+
+// RegisterAdministrativeRecordType registers the content's type for the given
+// type code. Received AdministrativeRecords of this type code will be decoded
+// into a value of this type, which must be (de)serializable by the CBOR codec.
+// An already registered type code will be overwritten.
+func RegisterAdministrativeRecordType(typeCode AdministrativeRecordTypeCode, content interface{}) {
+	administrativeRecordTypesMutex.Lock()
+	administrativeRecordTypes[typeCode] = reflect.TypeOf(content)
+	administrativeRecordTypesMutex.Unlock()
+}
+
+// administrativeRecordType returns the registered content type of the type
+// code and false if this type code is unknown.
+func administrativeRecordType(typeCode AdministrativeRecordTypeCode) (t reflect.Type, ok bool) {
+	administrativeRecordTypesMutex.RLock()
+	t, ok = administrativeRecordTypes[typeCode]
+	administrativeRecordTypesMutex.RUnlock()
+
+	return
+}
+
 // AdministrativeRecord is a application data unit used for administrative
-// records. There is only one kind of Administrative Record definded today: the
-// Bundle Status Report. Therefore the Content field is currently of the
-// StatusReport type. Otherwise the CBOR en- and decoding would have been done
-// by hand. So this becomes work for future me.
+// records. The Content's type depends on the TypeCode: a StatusReport for the
+// BundleStatusReportTypeCode, a CustodySignal for the CustodySignalTypeCode and
+// a BIBEProtocolDataUnit for the BIBEProtocolDataUnitTypeCode. Other types are
+// decoded as registered by RegisterAdministrativeRecordType.
 type AdministrativeRecord struct {
-	_struct struct{} `codec:",toarray"`
-
 	TypeCode AdministrativeRecordTypeCode
-	Content  StatusReport
+	Content  interface{}
 }
 
 // NewAdministrativeRecord generates a new Administrative Record based on the
 // given parameters.
-func NewAdministrativeRecord(typeCode AdministrativeRecordTypeCode, content StatusReport) AdministrativeRecord {
+func NewAdministrativeRecord(typeCode AdministrativeRecordTypeCode, content interface{}) AdministrativeRecord {
 	return AdministrativeRecord{
 		TypeCode: typeCode,
 		Content:  content,
 	}
 }
 
+func (ar AdministrativeRecord) CodecEncodeSelf(enc *codec.Encoder) {
+	enc.MustEncode([]interface{}{ar.TypeCode, ar.Content})
+}
+
+func (ar *AdministrativeRecord) CodecDecodeSelf(dec *codec.Decoder) {
+	var arrPt = new([]interface{})
+	dec.MustDecode(arrPt)
+
+	var arr = *arrPt
+	if len(arr) != 2 {
+		panic("arr has wrong length (!= 2)")
+	}
+
+	ar.TypeCode = AdministrativeRecordTypeCode(arr[0].(uint64))
+
+	// The content was decoded generically and must be re-encoded to be decoded
+	// into its specific type.
+	var data []byte
+	codec.NewEncoderBytes(&data, new(codec.CborHandle)).MustEncode(arr[1])
+
+	contentType, ok := administrativeRecordType(ar.TypeCode)
+	if !ok {
+		panic(fmt.Sprintf("unknown administrative record type code %d", ar.TypeCode))
+	}
+
+	var content = reflect.New(contentType)
+	codec.NewDecoderBytes(data, new(codec.CborHandle)).MustDecode(content.Interface())
+	ar.Content = content.Elem().Interface()
+}
+
 // NewAdministrativeRecordFromCbor creates a new AdministrativeRecord from
 // a given byte array.
 func NewAdministrativeRecordFromCbor(data []byte) (ar AdministrativeRecord, err error) {
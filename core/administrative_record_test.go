@@ -0,0 +1,111 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestAdministrativeRecordCbor(t *testing.T) {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.RequestStatusTime,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bpdu, err := NewBIBEProtocolDataUnit(bndl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []AdministrativeRecord{
+		NewAdministrativeRecord(BundleStatusReportTypeCode,
+			NewStatusReport(bndl, ReceivedBundle, NoInformation, bundle.DtnTimeNow())),
+		NewAdministrativeRecord(BIBEProtocolDataUnitTypeCode, bpdu),
+	}
+
+	for _, ar := range tests {
+		var cb = ar.ToCanonicalBlock()
+
+		arDec, err := NewAdministrativeRecordFromCbor(cb.Data.([]byte))
+		if err != nil {
+			t.Fatalf("Decoding %v failed: %v", ar, err)
+		}
+
+		if !reflect.DeepEqual(ar, arDec) {
+			t.Fatalf("CBOR result differs: %v, %v", ar, arDec)
+		}
+	}
+
+	bndlDec, err := bpdu.Bundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(bndl, bndlDec) {
+		t.Fatalf("Encapsulated bundle differs: %v, %v", bndl, bndlDec)
+	}
+}
+
+func TestBIBEBundleFilePayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := bundle.SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.SetPayloadSpool("", 0)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, make([]byte, 4096)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outer, err := NewBIBEBundle(bndl, bundle.MustNewEndpointID("dtn:a"), bundle.MustNewEndpointID("dtn:b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloadBlock, _ := outer.PayloadBlock()
+	if _, ok := payloadBlock.Data.(bundle.FilePayload); !ok {
+		t.Fatalf("Encapsulating bundle's payload is no FilePayload: %T", payloadBlock.Data)
+	}
+
+	payload, err := outer.PayloadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ar, err := NewAdministrativeRecordFromCbor(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := ar.Content.(BIBEProtocolDataUnit).Bundle()
+	if err != nil {
+		t.Fatal(err)
+	} else if inner.ID() != bndl.ID() {
+		t.Fatalf("Encapsulated bundle %v differs from %v", inner, bndl)
+	}
+}
@@ -12,3 +12,54 @@ type ApplicationAgent interface {
 	// may contain an application specific payload or an administrative record.
 	Deliver(bndl *bundle.Bundle) error
 }
+
+// MultiEndpointAgent is an optional interface for an ApplicationAgent, which
+// serves multiple endpoint IDs next to its own one. Those might change at
+// runtime, e.g., when applications connect or disconnect.
+type MultiEndpointAgent interface {
+	ApplicationAgent
+
+	// EndpointIDs returns all endpoint IDs currently served by this
+	// ApplicationAgent, except its own EndpointID.
+	EndpointIDs() []bundle.EndpointID
+}
+
+// PatternAgent is an optional interface for an ApplicationAgent, which serves
+// all endpoint IDs matching one of its EndpointPatterns, e.g., all services of
+// a node. Like the EndpointIDs of a MultiEndpointAgent, those might change at
+// runtime.
+type PatternAgent interface {
+	ApplicationAgent
+
+	// EndpointPatterns returns all EndpointPatterns currently served by this
+	// ApplicationAgent.
+	EndpointPatterns() []EndpointPattern
+}
+
+// agentHasEndpoint checks if the ApplicationAgent serves the endpoint ID,
+// either as its own EndpointID or, for a MultiEndpointAgent, as one of its
+// additional EndpointIDs or, for a PatternAgent, by one of its
+// EndpointPatterns.
+func agentHasEndpoint(agent ApplicationAgent, endpoint bundle.EndpointID) bool {
+	if agent.EndpointID() == endpoint {
+		return true
+	}
+
+	if multiAgent, ok := agent.(MultiEndpointAgent); ok {
+		for _, eid := range multiAgent.EndpointIDs() {
+			if eid == endpoint {
+				return true
+			}
+		}
+	}
+
+	if patternAgent, ok := agent.(PatternAgent); ok {
+		for _, pattern := range patternAgent.EndpointPatterns() {
+			if pattern.Matches(endpoint) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -3,7 +3,12 @@ package core
 import (
 	"encoding/base64"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -17,6 +22,9 @@ import (
 type SimpleRESTRequest struct {
 	Destination string
 	Payload     string
+	Encrypt     bool
+	Priority    string
+	Ordinal     uint
 }
 
 // SimpleRESTRequestResponse is the response, sent to a SimpleRESTRequest.
@@ -25,28 +33,41 @@ type SimpleRESTRequestResponse struct {
 }
 
 // SimpleRESTResponse is the data structure used for incoming bundles,
-// handled through the SimpleRESTAppAgent.
+// handled through the SimpleRESTAppAgent. A payload stored in a file is not
+// included, but must be downloaded by its PayloadID.
 type SimpleRESTResponse struct {
 	Destination  string
 	SourceNode   string
 	ControlFlags string
 	Timestamp    [2]string
 	Payload      []byte
+	PayloadID    string
+	PayloadSize  uint64
 }
 
 // NewSimpleRESTReponseFromBundle creates a new SimpleRESTResponse for a bundle.
 func NewSimpleRESTReponseFromBundle(b bundle.Bundle) SimpleRESTResponse {
-	payload, _ := b.PayloadBlock()
-
-	return SimpleRESTResponse{
+	var resp = SimpleRESTResponse{
 		Destination:  b.PrimaryBlock.Destination.String(),
 		SourceNode:   b.PrimaryBlock.SourceNode.String(),
 		ControlFlags: b.PrimaryBlock.BundleControlFlags.String(),
 		Timestamp: [2]string{
 			bundle.DtnTime(b.PrimaryBlock.CreationTimestamp[0]).String(),
 			fmt.Sprintf("%d", b.PrimaryBlock.CreationTimestamp[1])},
-		Payload: payload.Data.([]byte),
 	}
+
+	payload, _ := b.PayloadBlock()
+	switch data := payload.Data.(type) {
+	case []byte:
+		resp.Payload = data
+		resp.PayloadSize = uint64(len(data))
+
+	case bundle.FilePayload:
+		resp.PayloadID = filepath.Base(data.Path)
+		resp.PayloadSize = data.Size
+	}
+
+	return resp
 }
 
 // SimpleRESTAppAgent is an implementation of an ApplicationAgent, useable
@@ -63,12 +84,34 @@ func NewSimpleRESTReponseFromBundle(b bundle.Bundle) SimpleRESTResponse {
 //
 // Would create an outbounding bundle with a "hello" payload, addressed to an
 // endpoint named "dtn:foobar".
+//
+// An additional "Encrypt" field with the value true encrypts the payload with
+// a Block Confidentiality Block. Therefore, the Core's first registered
+// ConfidentialityContext is used.
+//
+// The optional "Priority" field names the bundle's class of service, "bulk",
+// "normal" or "expedited", and the "Ordinal" field orders bundles within the
+// same class. Both are carried in a Priority block.
+//
+// Large payloads should be streamed through the /upload/ and /download/
+// endpoints, which requires a payload spool, see bundle.SetPayloadSpool. A
+// HTTP POST request's body to /upload/ becomes the payload of a bundle, which
+// is stored in a file. The destination, priority and ordinal are passed as
+// query parameters. Such a bundle might be fragmented.
+//
+//	curl --data-binary @large.iso "http://localhost:8080/upload/?destination=dtn:foobar&priority=bulk"
+//
+// A fetched bundle with a payload file has a "PayloadID" instead of the
+// "Payload". This file can be downloaded once and is removed afterwards.
+//
+//	curl -o large.iso "http://localhost:8080/download/?id=payload-123456"
 type SimpleRESTAppAgent struct {
 	endpointID bundle.EndpointID
 	c          *Core
 
 	serv        *http.Server
 	bundles     []bundle.Bundle
+	downloads   map[string]bundle.FilePayload
 	bundleMutex sync.Mutex
 }
 
@@ -79,11 +122,14 @@ func NewSimpleRESTAppAgent(endpointID bundle.EndpointID, c *Core, addr string) (
 		endpointID: endpointID,
 		c:          c,
 		bundles:    make([]bundle.Bundle, 0, 0),
+		downloads:  make(map[string]bundle.FilePayload),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/fetch/", aa.handleFetch)
 	mux.HandleFunc("/send/", aa.handleSend)
+	mux.HandleFunc("/upload/", aa.handleUpload)
+	mux.HandleFunc("/download/", aa.handleDownload)
 
 	aa.serv = &http.Server{
 		Addr:    addr,
@@ -99,7 +145,13 @@ func (aa *SimpleRESTAppAgent) handleFetch(respWriter http.ResponseWriter, _ *htt
 
 	resps := make([]SimpleRESTResponse, 0, 0)
 	for _, bndl := range aa.bundles {
-		resps = append(resps, NewSimpleRESTReponseFromBundle(bndl))
+		resp := NewSimpleRESTReponseFromBundle(bndl)
+		resps = append(resps, resp)
+
+		if resp.PayloadID != "" {
+			payload, _ := bndl.PayloadBlock()
+			aa.downloads[resp.PayloadID] = payload.Data.(bundle.FilePayload)
+		}
 	}
 	aa.bundles = aa.bundles[:0]
 
@@ -111,9 +163,9 @@ func (aa *SimpleRESTAppAgent) handleFetch(respWriter http.ResponseWriter, _ *htt
 func (aa *SimpleRESTAppAgent) handleSend(respWriter http.ResponseWriter, req *http.Request) {
 	var resp SimpleRESTRequestResponse
 
-	defer func(resp SimpleRESTRequestResponse) {
+	defer func() {
 		codec.NewEncoder(respWriter, new(codec.JsonHandle)).Encode(resp)
-	}(resp)
+	}()
 
 	var handleErr = func(msg string) {
 		resp = SimpleRESTRequestResponse{msg}
@@ -147,29 +199,221 @@ func (aa *SimpleRESTAppAgent) handleSend(respWriter http.ResponseWriter, req *ht
 		return
 	}
 
-	var bndl, bndlErr = bundle.NewBundle(
+	var prio, prioErr = parseSimpleRESTPriority(postReq.Priority, postReq.Ordinal)
+	if prioErr != nil {
+		handleErr("Unknown priority class")
+		return
+	}
+
+	var bndl, bndlErr = aa.createBundle(bundle.MustNotFragmented, dest, bundle.NewPayloadBlock(0, payload), prio)
+	if bndlErr != nil {
+		handleErr(fmt.Sprintf("Creating bundle failed: %v", bndlErr))
+		return
+	}
+
+	if postReq.Encrypt {
+		if len(aa.c.confidentialityContexts) == 0 {
+			handleErr("Encryption was requested, but no confidentiality context exists")
+			return
+		}
+
+		if err := bndl.AddConfidentialityBlocks(
+			[]uint{0}, aa.endpointID, aa.c.confidentialityContexts[0]); err != nil {
+			handleErr(fmt.Sprintf("Encrypting bundle failed: %v", err))
+			return
+		}
+	}
+
+	if err := aa.c.SendBundle(bndl); err != nil {
+		handleErr(fmt.Sprintf("Transmitting bundle failed: %v", err))
+		return
+	}
+
+	resp = SimpleRESTRequestResponse{}
+	log.WithFields(log.Fields{
+		"srest":  aa.EndpointID(),
+		"bundle": bndl,
+	}).Info("SimpleRESTAppAgent's transmitted bundle")
+}
+
+// parseSimpleRESTPriority creates the Priority for a request's optional
+// priority class name and ordinal.
+func parseSimpleRESTPriority(name string, ordinal uint) (bundle.Priority, error) {
+	var prio = bundle.DefaultPriority()
+	if name != "" {
+		class, err := bundle.ParsePriorityClass(name)
+		if err != nil {
+			return prio, err
+		}
+
+		prio.Class = class
+	}
+	prio.Ordinal = ordinal
+
+	return prio, nil
+}
+
+// createBundle creates an outbounding bundle with the payload block.
+func (aa *SimpleRESTAppAgent) createBundle(flags bundle.BundleControlFlags, dest bundle.EndpointID,
+	payloadBlock bundle.CanonicalBlock, prio bundle.Priority) (bundle.Bundle, error) {
+	return bundle.NewBundle(
 		bundle.NewPrimaryBlock(
-			bundle.MustNotFragmented|bundle.StatusRequestDelivery,
+			flags|bundle.StatusRequestDelivery,
 			dest,
 			aa.endpointID,
 			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
 			60*60*1000000),
 		[]bundle.CanonicalBlock{
-			bundle.NewPayloadBlock(0, payload),
+			payloadBlock,
 			bundle.NewHopCountBlock(23, 0, bundle.NewHopCount(5)),
+			bundle.NewPriorityBlock(25, 0, prio),
 		})
+}
+
+// receivePayload reads the request's body as a payload. If a payload spool
+// exists, the body is streamed into a payload file.
+func receivePayload(body io.Reader) (bundle.CanonicalBlock, error) {
+	if dir, _ := bundle.PayloadSpool(); dir == "" {
+		payload, err := ioutil.ReadAll(body)
+		return bundle.NewPayloadBlock(0, payload), err
+	}
+
+	f, err := bundle.NewPayloadFile()
+	if err != nil {
+		return bundle.CanonicalBlock{}, err
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return bundle.CanonicalBlock{}, err
+	} else if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return bundle.CanonicalBlock{}, err
+	}
+
+	fp, err := bundle.NewFilePayload(f.Name())
+	return bundle.NewFilePayloadBlock(0, fp), err
+}
+
+func (aa *SimpleRESTAppAgent) handleUpload(respWriter http.ResponseWriter, req *http.Request) {
+	var resp SimpleRESTRequestResponse
+
+	defer func() {
+		codec.NewEncoder(respWriter, new(codec.JsonHandle)).Encode(resp)
+	}()
+
+	var handleErr = func(msg string) {
+		resp = SimpleRESTRequestResponse{msg}
+		log.WithFields(log.Fields{
+			"srest":   aa.EndpointID(),
+			"request": req,
+			"error":   msg,
+		}).Warn("SimpleRESTAppAgent's upload errored")
+	}
+
+	if req.Method != "POST" {
+		handleErr("Upload expects a POST request")
+		return
+	}
+
+	var query = req.URL.Query()
+
+	var dest, destErr = bundle.NewEndpointID(query.Get("destination"))
+	if destErr != nil {
+		handleErr("Unintelligible destination")
+		return
+	}
+
+	var ordinal uint64
+	if ordinalStr := query.Get("ordinal"); ordinalStr != "" {
+		var ordinalErr error
+		if ordinal, ordinalErr = strconv.ParseUint(ordinalStr, 10, 0); ordinalErr != nil {
+			handleErr("Unintelligible ordinal")
+			return
+		}
+	}
+
+	var prio, prioErr = parseSimpleRESTPriority(query.Get("priority"), uint(ordinal))
+	if prioErr != nil {
+		handleErr("Unknown priority class")
+		return
+	}
+
+	var payloadBlock, payloadErr = receivePayload(req.Body)
+	if payloadErr != nil {
+		handleErr(fmt.Sprintf("Receiving payload failed: %v", payloadErr))
+		return
+	}
+
+	// The payload file is referenced by the stored bundle afterwards. Otherwise,
+	// it must be removed.
+	var removePayload = func() {
+		if fp, ok := payloadBlock.Data.(bundle.FilePayload); ok {
+			os.Remove(fp.Path)
+		}
+	}
+
+	var bndl, bndlErr = aa.createBundle(0, dest, payloadBlock, prio)
 	if bndlErr != nil {
+		removePayload()
 		handleErr(fmt.Sprintf("Creating bundle failed: %v", bndlErr))
 		return
 	}
 
-	aa.c.SendBundle(bndl)
+	if err := aa.c.SendBundle(bndl); err != nil {
+		removePayload()
+		handleErr(fmt.Sprintf("Transmitting bundle failed: %v", err))
+		return
+	}
 
 	resp = SimpleRESTRequestResponse{}
 	log.WithFields(log.Fields{
 		"srest":  aa.EndpointID(),
 		"bundle": bndl,
-	}).Info("SimpleRESTAppAgent's transmitted bundle")
+	}).Info("SimpleRESTAppAgent's transmitted uploaded bundle")
+}
+
+func (aa *SimpleRESTAppAgent) handleDownload(respWriter http.ResponseWriter, req *http.Request) {
+	var id = req.URL.Query().Get("id")
+
+	aa.bundleMutex.Lock()
+	fp, ok := aa.downloads[id]
+	delete(aa.downloads, id)
+	aa.bundleMutex.Unlock()
+
+	if !ok {
+		http.Error(respWriter, "Unknown payload ID", http.StatusNotFound)
+		return
+	}
+
+	// Each payload file is only downloaded once. The opened file remains
+	// readable after its removal.
+	f, err := fp.Open()
+	os.Remove(fp.Path)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"srest": aa.EndpointID(),
+			"file":  fp.Path,
+			"error": err,
+		}).Warn("SimpleRESTAppAgent failed to open payload file")
+
+		http.Error(respWriter, "Payload file is unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	respWriter.Header().Set("Content-Type", "application/octet-stream")
+	respWriter.Header().Set("Content-Length", strconv.FormatUint(fp.Size, 10))
+
+	if _, err := io.Copy(respWriter, f); err != nil {
+		log.WithFields(log.Fields{
+			"srest": aa.EndpointID(),
+			"file":  fp.Path,
+			"error": err,
+		}).Warn("SimpleRESTAppAgent failed to send payload file")
+	}
 }
 
 // EndpointID returns this SimpleRESTAppAgent's (unique) endpoint ID.
@@ -179,14 +423,28 @@ func (aa *SimpleRESTAppAgent) EndpointID() bundle.EndpointID {
 
 // Deliver delivers a received bundle to this SimpleRESTAppAgent. This bundle
 // may contain an application specific payload or an administrative record.
+// A payload file is claimed by this SimpleRESTAppAgent until its download.
 func (aa *SimpleRESTAppAgent) Deliver(bndl *bundle.Bundle) error {
 	log.WithFields(log.Fields{
 		"srest":  aa.EndpointID(),
 		"bundle": bndl,
 	}).Info("SimpleRESTAppAgent received a bundle")
 
+	var delivered = *bndl
+	delivered.CanonicalBlocks = append([]bundle.CanonicalBlock(nil), bndl.CanonicalBlocks...)
+
+	if payload, err := delivered.PayloadBlock(); err == nil {
+		if fp, ok := payload.Data.(bundle.FilePayload); ok {
+			claimedFp, err := claimFilePayload(fp)
+			if err != nil {
+				return err
+			}
+			payload.Data = claimedFp
+		}
+	}
+
 	aa.bundleMutex.Lock()
-	aa.bundles = append(aa.bundles, *bndl)
+	aa.bundles = append(aa.bundles, delivered)
 	aa.bundleMutex.Unlock()
 
 	return nil
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/ugorji/go/codec"
+)
+
+func TestSimpleRESTUploadDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := bundle.SetPayloadSpool(dir, 1024); err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.SetPayloadSpool("", 0)
+
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+
+	var aa = NewSimpleRESTAppAgent(bundle.MustNewEndpointID("dtn://alpha/app"), c, "127.0.0.1:0")
+	c.RegisterApplicationAgent(aa)
+
+	var serv = httptest.NewServer(aa.serv.Handler)
+	defer serv.Close()
+
+	var payload = make([]byte, 1<<16)
+	rand.Read(payload)
+
+	var query = url.Values{"destination": {"dtn://alpha/app"}, "priority": {"bulk"}}
+	resp, err := http.Post(serv.URL+"/upload/?"+query.Encode(), "application/octet-stream", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploadResp SimpleRESTRequestResponse
+	if err := codec.NewDecoder(resp.Body, new(codec.JsonHandle)).Decode(&uploadResp); err != nil {
+		t.Fatal(err)
+	} else if uploadResp.Error != "" {
+		t.Fatalf("Upload errored: %s", uploadResp.Error)
+	}
+	resp.Body.Close()
+
+	var fetched []SimpleRESTResponse
+	for deadline := time.Now().Add(5 * time.Second); len(fetched) == 0 && time.Now().Before(deadline); {
+		resp, err := http.Get(serv.URL + "/fetch/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := codec.NewDecoder(resp.Body, new(codec.JsonHandle)).Decode(&fetched); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if len(fetched) == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	if len(fetched) != 1 {
+		t.Fatalf("Fetched %d instead of 1 bundles", len(fetched))
+	} else if fetched[0].PayloadID == "" || len(fetched[0].Payload) != 0 {
+		t.Fatalf("Fetched bundle has no payload file: %v", fetched[0])
+	} else if fetched[0].PayloadSize != uint64(len(payload)) {
+		t.Fatalf("Fetched payload has %d instead of %d bytes", fetched[0].PayloadSize, len(payload))
+	}
+
+	var deliveredFile = filepath.Join(dir, deliveredPayloadDir, fetched[0].PayloadID)
+	if _, err := os.Stat(deliveredFile); err != nil {
+		t.Fatalf("Delivered payload file is missing: %v", err)
+	}
+
+	var download = func() *http.Response {
+		resp, err := http.Get(serv.URL + "/download/?id=" + url.QueryEscape(fetched[0].PayloadID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp = download()
+	if data, err := ioutil.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, payload) {
+		t.Fatalf("Downloaded payload differs")
+	}
+	resp.Body.Close()
+
+	if _, err := os.Stat(deliveredFile); !os.IsNotExist(err) {
+		t.Fatalf("Downloaded payload file was not removed: %v", err)
+	}
+
+	if resp = download(); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Second download resulted in status %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
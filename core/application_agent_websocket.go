@@ -0,0 +1,442 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/ugorji/go/codec"
+)
+
+// Types of a WebSocketMessage. The first three are sent by clients, the others
+// by the WebSocketAgent.
+const (
+	// WebSocketRegister registers the EndpointID for this client.
+	WebSocketRegister = "register"
+
+	// WebSocketUnregister unregisters the EndpointID for this client.
+	WebSocketUnregister = "unregister"
+
+	// WebSocketSend creates an outbounding bundle of the Destination and
+	// Payload. An optional EndpointID, which must be registered by this client,
+	// is used as the bundle's source.
+	WebSocketSend = "send"
+
+	// WebSocketBundle pushes a received Bundle to a client.
+	WebSocketBundle = "bundle"
+
+	// WebSocketAck acknowledges a client's message.
+	WebSocketAck = "ack"
+
+	// WebSocketError reports a failed client's message, explained by Error.
+	WebSocketError = "error"
+)
+
+const (
+	// webSocketWriteTimeout limits the time to push a message to a client.
+	webSocketWriteTimeout = 10 * time.Second
+
+	// webSocketPongTimeout limits the time to wait on a client's message or a
+	// pong, answering the pings sent every webSocketPingInterval.
+	webSocketPongTimeout  = 60 * time.Second
+	webSocketPingInterval = webSocketPongTimeout / 2
+
+	// webSocketReadLimit is the maximum size of a client's message. Larger
+	// payloads should be sent through the SimpleRESTAppAgent's upload.
+	webSocketReadLimit = 1 << 24
+)
+
+// WebSocketMessage is the data structure exchanged between the WebSocketAgent
+// and its clients, each encoded as a JSON text message. The Type defines which
+// of the other fields are used. Each client's message is answered by either a
+// WebSocketAck or a WebSocketError message, in order.
+type WebSocketMessage struct {
+	Type        string
+	EndpointID  string              `codec:",omitempty"`
+	Destination string              `codec:",omitempty"`
+	Payload     []byte              `codec:",omitempty"`
+	Bundle      *SimpleRESTResponse `codec:",omitempty"`
+	Error       string              `codec:",omitempty"`
+}
+
+// webSocketClient is a connected client of the WebSocketAgent.
+type webSocketClient struct {
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+
+	// endpoints are secured by the WebSocketAgent's mutex.
+	endpoints map[bundle.EndpointID]bool
+}
+
+// write sends a WebSocketMessage to this client.
+func (wsc *webSocketClient) write(msg WebSocketMessage) error {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, new(codec.JsonHandle)).Encode(msg); err != nil {
+		return err
+	}
+
+	wsc.writeMutex.Lock()
+	defer wsc.writeMutex.Unlock()
+
+	wsc.conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return wsc.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// WebSocketAgent is an implementation of an ApplicationAgent, offering
+// bidirectional messaging through WebSocket connections on the /ws endpoint.
+//
+// Each client registers one or more endpoint IDs by WebSocketRegister
+// messages. Received bundles addressed to a registered endpoint ID are pushed
+// immediately to all clients which registered it as WebSocketBundle messages.
+// Outbounding bundles are created by WebSocketSend messages. All messages are
+// JSON encoded WebSocketMessages, where the payload is base64 encoded.
+//
+//	{"Type":"register","EndpointID":"dtn:alpha/app"}
+//	{"Type":"send","Destination":"dtn:beta/app","Payload":"aGVsbG8gd29ybGQ="}
+//
+// Only endpoint IDs of this node, as identified by the Core's node ID or the
+// WebSocketAgent's own endpoint ID, might be registered. Registered endpoint
+// IDs are released when the client disconnects.
+type WebSocketAgent struct {
+	endpointID bundle.EndpointID
+	c          *Core
+
+	serv     *http.Server
+	upgrader websocket.Upgrader
+
+	clients map[*webSocketClient]bool
+	mutex   sync.Mutex
+}
+
+// newWebSocketAgent creates a new WebSocketAgent without starting a server.
+func newWebSocketAgent(endpointID bundle.EndpointID, c *Core) *WebSocketAgent {
+	return &WebSocketAgent{
+		endpointID: endpointID,
+		c:          c,
+		clients:    make(map[*webSocketClient]bool),
+	}
+}
+
+// NewWebSocketAgent creates a new WebSocketAgent for the given endpoint, Core
+// and bound to the address.
+func NewWebSocketAgent(endpointID bundle.EndpointID, c *Core, addr string) (aa *WebSocketAgent) {
+	aa = newWebSocketAgent(endpointID, c)
+
+	mux := http.NewServeMux()
+	mux.Handle("/ws", aa)
+
+	aa.serv = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go aa.serv.ListenAndServe()
+	return
+}
+
+// Close shuts down the web server and disconnects all clients.
+func (aa *WebSocketAgent) Close() error {
+	var err error
+	if aa.serv != nil {
+		err = aa.serv.Close()
+	}
+
+	aa.mutex.Lock()
+	for client := range aa.clients {
+		client.conn.Close()
+	}
+	aa.mutex.Unlock()
+
+	return err
+}
+
+// ServeHTTP upgrades a HTTP request to a WebSocket connection and handles
+// this client until it disconnects.
+func (aa *WebSocketAgent) ServeHTTP(respWriter http.ResponseWriter, req *http.Request) {
+	conn, err := aa.upgrader.Upgrade(respWriter, req, nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"websocket": aa.EndpointID(),
+			"error":     err,
+		}).Warn("WebSocketAgent failed to upgrade connection")
+		return
+	}
+
+	var client = &webSocketClient{
+		conn:      conn,
+		endpoints: make(map[bundle.EndpointID]bool),
+	}
+
+	aa.mutex.Lock()
+	aa.clients[client] = true
+	aa.mutex.Unlock()
+
+	log.WithFields(log.Fields{
+		"websocket": aa.EndpointID(),
+		"client":    conn.RemoteAddr(),
+	}).Info("WebSocketAgent's client connected")
+
+	aa.handleClient(client)
+
+	aa.mutex.Lock()
+	delete(aa.clients, client)
+	aa.mutex.Unlock()
+
+	conn.Close()
+
+	log.WithFields(log.Fields{
+		"websocket": aa.EndpointID(),
+		"client":    conn.RemoteAddr(),
+	}).Info("WebSocketAgent's client disconnected")
+}
+
+// handleClient reads and answers the client's messages until its connection
+// fails. An unresponsive client, which neither sends messages nor answers the
+// pings, is disconnected.
+func (aa *WebSocketAgent) handleClient(client *webSocketClient) {
+	client.conn.SetReadLimit(webSocketReadLimit)
+	client.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+	})
+
+	var pingStop = make(chan struct{})
+	defer close(pingStop)
+
+	go func() {
+		var ticker = time.NewTicker(webSocketPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-pingStop:
+				return
+
+			case <-ticker.C:
+				if err := client.conn.WriteControl(websocket.PingMessage, nil,
+					time.Now().Add(webSocketWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		client.conn.SetReadDeadline(time.Now().Add(webSocketPongTimeout))
+
+		var resp = WebSocketMessage{Type: WebSocketAck}
+
+		var msg WebSocketMessage
+		if decErr := codec.NewDecoderBytes(data, new(codec.JsonHandle)).Decode(&msg); decErr != nil {
+			err = newCoreError("Failed to parse message")
+		} else {
+			err = aa.handleMessage(client, msg)
+		}
+
+		if err != nil {
+			resp = WebSocketMessage{Type: WebSocketError, Error: err.Error()}
+
+			log.WithFields(log.Fields{
+				"websocket": aa.EndpointID(),
+				"client":    client.conn.RemoteAddr(),
+				"error":     err,
+			}).Warn("WebSocketAgent's client message errored")
+		}
+
+		if err := client.write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handleMessage executes a client's message.
+func (aa *WebSocketAgent) handleMessage(client *webSocketClient, msg WebSocketMessage) error {
+	switch msg.Type {
+	case WebSocketRegister, WebSocketUnregister:
+		eid, err := bundle.NewEndpointID(msg.EndpointID)
+		if err != nil {
+			return newCoreError("Unintelligible endpoint ID")
+		}
+
+		if msg.Type == WebSocketRegister && !aa.isLocalEndpoint(eid) {
+			return newCoreError("Endpoint ID does not belong to this node")
+		}
+
+		aa.mutex.Lock()
+		if msg.Type == WebSocketRegister {
+			client.endpoints[eid] = true
+		} else {
+			delete(client.endpoints, eid)
+		}
+		aa.mutex.Unlock()
+
+		log.WithFields(log.Fields{
+			"websocket": aa.EndpointID(),
+			"client":    client.conn.RemoteAddr(),
+			"endpoint":  eid,
+			"type":      msg.Type,
+		}).Info("WebSocketAgent's client changed registration")
+
+		// Pending bundles might be addressed to the registered endpoint ID.
+		if msg.Type == WebSocketRegister {
+			aa.c.notifyPending()
+		}
+
+		return nil
+
+	case WebSocketSend:
+		return aa.handleSend(client, msg)
+
+	default:
+		return newCoreError(fmt.Sprintf("Unknown message type \"%s\"", msg.Type))
+	}
+}
+
+// isLocalEndpoint checks if the endpoint ID belongs to this node, identified
+// by the Core's node ID or this WebSocketAgent's endpoint ID.
+func (aa *WebSocketAgent) isLocalEndpoint(eid bundle.EndpointID) bool {
+	if eid == bundle.DtnNone() {
+		return false
+	}
+
+	return aa.c.NodeId().SameNode(eid) || aa.endpointID.SameNode(eid)
+}
+
+// handleSend creates and transmits an outbounding bundle.
+func (aa *WebSocketAgent) handleSend(client *webSocketClient, msg WebSocketMessage) error {
+	var src = aa.endpointID
+	if msg.EndpointID != "" {
+		eid, err := bundle.NewEndpointID(msg.EndpointID)
+		if err != nil {
+			return newCoreError("Unintelligible source")
+		}
+
+		aa.mutex.Lock()
+		registered := client.endpoints[eid]
+		aa.mutex.Unlock()
+
+		if !registered {
+			return newCoreError("Source is not registered by this client")
+		}
+
+		src = eid
+	}
+
+	dest, err := bundle.NewEndpointID(msg.Destination)
+	if err != nil {
+		return newCoreError("Unintelligible destination")
+	}
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.StatusRequestDelivery,
+			dest,
+			src,
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, msg.Payload),
+			bundle.NewHopCountBlock(23, 0, bundle.NewHopCount(5)),
+		})
+	if err != nil {
+		return newCoreError(fmt.Sprintf("Creating bundle failed: %v", err))
+	}
+
+	if err := aa.c.SendBundle(bndl); err != nil {
+		return newCoreError(fmt.Sprintf("Transmitting bundle failed: %v", err))
+	}
+
+	log.WithFields(log.Fields{
+		"websocket": aa.EndpointID(),
+		"bundle":    bndl,
+	}).Info("WebSocketAgent's transmitted bundle")
+
+	return nil
+}
+
+// EndpointID returns this WebSocketAgent's (unique) endpoint ID.
+func (aa *WebSocketAgent) EndpointID() bundle.EndpointID {
+	return aa.endpointID
+}
+
+// EndpointIDs returns all endpoint IDs registered by the connected clients.
+func (aa *WebSocketAgent) EndpointIDs() (eids []bundle.EndpointID) {
+	var known = make(map[bundle.EndpointID]bool)
+
+	aa.mutex.Lock()
+	for client := range aa.clients {
+		for eid := range client.endpoints {
+			if !known[eid] {
+				known[eid] = true
+				eids = append(eids, eid)
+			}
+		}
+	}
+	aa.mutex.Unlock()
+
+	return
+}
+
+// Deliver pushes a received bundle to all clients which registered the
+// bundle's destination. An error is returned if no client received it.
+func (aa *WebSocketAgent) Deliver(bndl *bundle.Bundle) error {
+	var dest = bndl.PrimaryBlock.Destination
+
+	var clients []*webSocketClient
+	aa.mutex.Lock()
+	for client := range aa.clients {
+		if client.endpoints[dest] {
+			clients = append(clients, client)
+		}
+	}
+	aa.mutex.Unlock()
+
+	var resp = NewSimpleRESTReponseFromBundle(*bndl)
+
+	// There is no download endpoint; a payload file is pushed inline.
+	if resp.PayloadID != "" {
+		payload, err := bndl.PayloadData()
+		if err != nil {
+			return err
+		}
+		resp.Payload, resp.PayloadID = payload, ""
+	}
+
+	var msg = WebSocketMessage{Type: WebSocketBundle, Bundle: &resp}
+
+	var delivered = 0
+	for _, client := range clients {
+		if err := client.write(msg); err != nil {
+			log.WithFields(log.Fields{
+				"websocket": aa.EndpointID(),
+				"client":    client.conn.RemoteAddr(),
+				"bundle":    bndl,
+				"error":     err,
+			}).Warn("WebSocketAgent failed to push bundle")
+		} else {
+			delivered++
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"websocket": aa.EndpointID(),
+		"bundle":    bndl,
+		"clients":   delivered,
+	}).Info("WebSocketAgent pushed a bundle")
+
+	if delivered == 0 {
+		return newCoreError("No client received the bundle")
+	}
+
+	return nil
+}
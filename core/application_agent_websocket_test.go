@@ -0,0 +1,134 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/ugorji/go/codec"
+)
+
+func TestWebSocketAgent(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var aa = newWebSocketAgent(bundle.MustNewEndpointID("dtn://alpha/ws"), c)
+	c.RegisterApplicationAgent(aa)
+
+	var serv = httptest.NewServer(aa)
+	defer serv.Close()
+	defer aa.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(serv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var write = func(msg WebSocketMessage) {
+		var data []byte
+		if err := codec.NewEncoderBytes(&data, new(codec.JsonHandle)).Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var read = func() (msg WebSocketMessage) {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := codec.NewDecoderBytes(data, new(codec.JsonHandle)).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	var eid = bundle.MustNewEndpointID("dtn://alpha/app")
+	if c.HasEndpoint(eid) {
+		t.Fatalf("Core has endpoint %v before registration", eid)
+	}
+
+	// Another node's endpoint ID must be rejected.
+	write(WebSocketMessage{Type: WebSocketRegister, EndpointID: "dtn://beta/app"})
+	if msg := read(); msg.Type != WebSocketError {
+		t.Fatalf("Registration of another node's endpoint was accepted: %v", msg)
+	}
+
+	write(WebSocketMessage{Type: WebSocketRegister, EndpointID: eid.String()})
+	if msg := read(); msg.Type != WebSocketAck {
+		t.Fatalf("Registration was not acknowledged: %v", msg)
+	}
+
+	if !c.HasEndpoint(eid) {
+		t.Fatalf("Core has no endpoint %v after registration", eid)
+	}
+
+	// A bundle addressed to itself is pushed back through the Core.
+	write(WebSocketMessage{
+		Type:        WebSocketSend,
+		EndpointID:  eid.String(),
+		Destination: eid.String(),
+		Payload:     []byte("hello world"),
+	})
+
+	var types = make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		msg := read()
+		types[msg.Type] = true
+
+		if msg.Type == WebSocketBundle {
+			if msg.Bundle == nil || string(msg.Bundle.Payload) != "hello world" {
+				t.Fatalf("Pushed bundle differs: %v", msg.Bundle)
+			}
+		}
+	}
+
+	if !types[WebSocketAck] || !types[WebSocketBundle] {
+		t.Fatalf("Sending resulted in unexpected messages: %v", types)
+	}
+
+	// An unregistered source must be rejected.
+	write(WebSocketMessage{
+		Type:        WebSocketSend,
+		EndpointID:  "dtn://alpha/other",
+		Destination: eid.String(),
+	})
+	if msg := read(); msg.Type != WebSocketError {
+		t.Fatalf("Unregistered source was accepted: %v", msg)
+	}
+
+	write(WebSocketMessage{Type: WebSocketUnregister, EndpointID: eid.String()})
+	if msg := read(); msg.Type != WebSocketAck {
+		t.Fatalf("Unregistration was not acknowledged: %v", msg)
+	}
+
+	if c.HasEndpoint(eid) {
+		t.Fatalf("Core has endpoint %v after unregistration", eid)
+	}
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// BIBEProtocolDataUnit is the content of an administrative record, which
+// encapsulates a whole bundle for Bundle-in-Bundle Encapsulation (BIBE), as
+// defined in draft-ietf-dtn-bibect-02. Custody transfer is not supported.
+// Therefore both the TransmissionID and the RetransmissionTime are zero.
+type BIBEProtocolDataUnit struct {
+	_struct struct{} `codec:",toarray"`
+
+	TransmissionID     uint
+	RetransmissionTime bundle.DtnTime
+	EncapsulatedBundle []byte
+}
+
+// NewBIBEProtocolDataUnit creates a new BIBEProtocolDataUnit, encapsulating
+// the given bundle. An error is returned if the bundle's payload file cannot
+// be read.
+func NewBIBEProtocolDataUnit(bndl bundle.Bundle) (bpdu BIBEProtocolDataUnit, err error) {
+	var buf bytes.Buffer
+	if err = bundle.NewEncoder(&buf).Encode(bndl); err != nil {
+		return
+	}
+
+	bpdu = BIBEProtocolDataUnit{
+		TransmissionID:     0,
+		RetransmissionTime: 0,
+		EncapsulatedBundle: buf.Bytes(),
+	}
+	return
+}
+
+// Bundle returns the encapsulated bundle.
+func (bpdu BIBEProtocolDataUnit) Bundle() (bundle.Bundle, error) {
+	return bundle.NewBundleFromCbor(bpdu.EncapsulatedBundle)
+}
+
+func (bpdu BIBEProtocolDataUnit) String() string {
+	return fmt.Sprintf("BIBEProtocolDataUnit(%d, %v, %d bytes)",
+		bpdu.TransmissionID, bpdu.RetransmissionTime, len(bpdu.EncapsulatedBundle))
+}
+
+// NewBIBEBundle creates a new bundle from the source to the destination,
+// containing an administrative record which encapsulates the given bundle.
+// The encapsulating bundle inherits the encapsulated bundle's lifetime.
+func NewBIBEBundle(bndl bundle.Bundle, source, destination bundle.EndpointID) (bundle.Bundle, error) {
+	payloadBlock, err := newBIBEPayloadBlock(bndl)
+	if err != nil {
+		return bundle.Bundle{}, err
+	}
+
+	return bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.AdministrativeRecordPayload,
+			destination,
+			source,
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			bndl.PrimaryBlock.Lifetime),
+		[]bundle.CanonicalBlock{
+			bundle.NewHopCountBlock(23, 0, bundle.NewHopCount(5)),
+			payloadBlock,
+		})
+}
+
+// newBIBEPayloadBlock creates the payload block of an administrative record,
+// encapsulating the given bundle. An encapsulated bundle reaching the payload
+// spool's threshold is streamed into a payload file instead of memory.
+func newBIBEPayloadBlock(bndl bundle.Bundle) (bundle.CanonicalBlock, error) {
+	if dir, threshold := bundle.PayloadSpool(); dir == "" || bndl.EncodedSize() < threshold {
+		bpdu, err := NewBIBEProtocolDataUnit(bndl)
+		if err != nil {
+			return bundle.CanonicalBlock{}, err
+		}
+
+		return NewAdministrativeRecord(BIBEProtocolDataUnitTypeCode, bpdu).ToCanonicalBlock(), nil
+	}
+
+	// The administrative record is encoded with an empty encapsulated bundle,
+	// whose trailing empty byte string is replaced by the streamed bundle.
+	var head []byte
+	var ar = NewAdministrativeRecord(BIBEProtocolDataUnitTypeCode,
+		BIBEProtocolDataUnit{EncapsulatedBundle: []byte{}})
+	if err := codec.NewEncoderBytes(&head, new(codec.CborHandle)).Encode(ar); err != nil {
+		return bundle.CanonicalBlock{}, err
+	}
+	head = head[:len(head)-1]
+
+	f, err := bundle.NewPayloadFile()
+	if err != nil {
+		return bundle.CanonicalBlock{}, err
+	}
+
+	var w = bufio.NewWriter(f)
+	if _, err = w.Write(head); err == nil {
+		if err = bundle.NewEncoder(w).EncodeByteString(bndl); err == nil {
+			err = w.Flush()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return bundle.CanonicalBlock{}, err
+	}
+
+	fp, err := bundle.NewFilePayload(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return bundle.CanonicalBlock{}, err
+	}
+
+	return bundle.NewFilePayloadBlock(0, fp), nil
+}
+
+// decapsulateBIBE extracts the bundle of a BIBEProtocolDataUnit and passes it
+// to the reception. Only bundles addressed to this node are decapsulated.
+func (c *Core) decapsulateBIBE(bp BundlePack, bpdu BIBEProtocolDataUnit) {
+	if !c.HasEndpoint(bp.Bundle.PrimaryBlock.Destination) {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Debug("BIBE bundle is not addressed to this node, skipping decapsulation")
+		return
+	}
+
+	inner, err := bpdu.Bundle()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Decapsulating BIBE bundle failed")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":       bp.Bundle,
+		"encapsulated": inner,
+	}).Info("Decapsulated bundle from BIBE bundle")
+
+	c.receive(NewRecBundlePack(cla.NewRecBundle(inner, bp.Bundle.PrimaryBlock.Destination)))
+}
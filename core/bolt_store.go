@@ -0,0 +1,163 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBucket is the name of the bbolt bucket, containing all bundle packs.
+var boltBucket = []byte("bundles")
+
+// BoltStore is an implemention of the Store interface, backed by the embedded
+// key-value database bbolt. Each bundle pack is stored as a single record,
+// identified by its bundle's ID. Every Push is an atomic transaction; thus, a
+// crash will not corrupt the already stored bundle packs.
+type BoltStore struct {
+	db       *bolt.DB
+	filename string
+	mutex    sync.RWMutex
+}
+
+// NewBoltStore creates a BoltStore, reading and writing data to the specified
+// database file. An existing database will be compacted while opening.
+func NewBoltStore(filename string) (store *BoltStore, err error) {
+	store = &BoltStore{filename: filename}
+
+	if err = store.open(); err != nil {
+		return nil, err
+	}
+
+	if err = store.Compact(); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return
+}
+
+// open opens the database file and creates the bucket, if necessary.
+func (store *BoltStore) open() (err error) {
+	store.db, err = bolt.Open(store.filename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return
+	}
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		_, bucketErr := tx.CreateBucketIfNotExists(boltBucket)
+		return bucketErr
+	})
+}
+
+// Compact rewrites the database to a new file, dropping unused pages of
+// deleted or updated records. Afterwards, the new file replaces the old one.
+func (store *BoltStore) Compact() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	var tmpFilename = fmt.Sprintf("%s.compact", store.filename)
+	os.Remove(tmpFilename)
+
+	dst, err := bolt.Open(tmpFilename, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	if err := bolt.Compact(dst, store.db, 0); err != nil {
+		dst.Close()
+		os.Remove(tmpFilename)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+
+	if err := store.db.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpFilename, store.filename); err != nil {
+		return err
+	}
+
+	return store.open()
+}
+
+// Close closes the underlying database.
+func (store *BoltStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.db.Close()
+}
+
+func (store *BoltStore) Push(bp BundlePack) error {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, new(codec.CborHandle)).Encode(bp); err != nil {
+		return err
+	}
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(bp.Bundle.ID()), data)
+	})
+}
+
+func (store *BoltStore) Delete(id string) error {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(id))
+	})
+}
+
+// decodeBundlePack decodes a stored record into a BundlePack.
+func decodeBundlePack(data []byte) (bp BundlePack, err error) {
+	err = codec.NewDecoderBytes(data, new(codec.CborHandle)).Decode(&bp)
+	return
+}
+
+func (store *BoltStore) Query(sel func(BundlePack) bool) (bps []BundlePack) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(_, data []byte) error {
+			if bp, err := decodeBundlePack(data); err == nil && sel(bp) {
+				bps = append(bps, bp)
+			}
+
+			return nil
+		})
+	})
+
+	return
+}
+
+// Lookup returns the bundle pack of the requested bundle ID and true, or false
+// if there is no such bundle pack.
+func (store *BoltStore) Lookup(id string) (bp BundlePack, ok bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	store.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(boltBucket).Get([]byte(id)); data != nil {
+			var err error
+			bp, err = decodeBundlePack(data)
+			ok = err == nil
+		}
+
+		return nil
+	})
+
+	return
+}
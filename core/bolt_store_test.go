@@ -0,0 +1,210 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func boltStoreFilename(t *testing.T) string {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatalf("Creating tempfile failed: %v", err)
+	}
+
+	// We don't want this file; just it's filename.
+	file.Close()
+	os.Remove(file.Name())
+
+	return file.Name()
+}
+
+func TestBoltStoreSingle(t *testing.T) {
+	var filename = boltStoreFilename(t)
+	defer os.Remove(filename)
+
+	store, err := NewBoltStore(filename)
+	if err != nil {
+		t.Fatalf("Creating BoltStore failed: %v", err)
+	}
+	defer store.Close()
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatalf("Creating bundle failed: %v", err)
+	}
+
+	bp := NewBundlePack(bndl)
+	if err := store.Push(bp); err != nil {
+		t.Errorf("Pushing errored: %v", err)
+	}
+
+	if !KnowsBundle(store, bp) {
+		t.Errorf("Pushed bundle pack is unknown")
+	}
+
+	bp.AddConstraint(DispatchPending)
+	if err := store.Push(bp); err != nil {
+		t.Errorf("Pushing errored: %v", err)
+	}
+
+	if l := len(store.Query(func(_ BundlePack) bool { return true })); l != 1 {
+		t.Errorf("After pushing modified bundle pack, store's length is %d", l)
+	}
+
+	if storedBp, ok := store.Lookup(bp.Bundle.ID()); !ok {
+		t.Errorf("Lookup of a stored bundle pack failed")
+	} else if !storedBp.HasConstraint(DispatchPending) {
+		t.Errorf("Stored bundle pack lacks its constraint")
+	}
+
+	bp.PurgeConstraints()
+	if err := store.Push(bp); err != nil {
+		t.Errorf("Pushing errored: %v", err)
+	}
+
+	if l := len(QueryAll(store)); l != 0 {
+		t.Errorf("Store is not empty after pushing bundle pack without constraints")
+	}
+
+	if _, ok := store.Lookup("dtn:unknown-0-0"); ok {
+		t.Errorf("Lookup of an unknown bundle pack succeeded")
+	}
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	var filename = boltStoreFilename(t)
+	defer os.Remove(filename)
+
+	store, err := NewBoltStore(filename)
+	if err != nil {
+		t.Fatalf("Creating BoltStore failed: %v", err)
+	}
+
+	// Overwriting a record multiple times leaves unused pages, which will be
+	// dropped by the compaction on reopening.
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatalf("Creating bundle failed: %v", err)
+	}
+
+	bp := NewBundlePack(bndl)
+	bp.AddConstraint(DispatchPending)
+	for i := 0; i < 32; i++ {
+		if err := store.Push(bp); err != nil {
+			t.Fatalf("Pushing errored: %v", err)
+		}
+	}
+
+	bndl2, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 1), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world!")),
+		})
+	if err != nil {
+		t.Fatalf("Creating bundle failed: %v", err)
+	}
+
+	bp2 := NewBundlePack(bndl2)
+	bp2.AddConstraint(Contraindicated)
+	if err := store.Push(bp2); err != nil {
+		t.Fatalf("Pushing errored: %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Closing BoltStore failed: %v", err)
+	}
+
+	store2, err := NewBoltStore(filename)
+	if err != nil {
+		t.Fatalf("Reopening BoltStore failed: %v", err)
+	}
+	defer store2.Close()
+
+	if l := len(QueryAll(store2)); l != 2 {
+		t.Errorf("Reopened store contains %d bundle packs instead of two", l)
+	}
+
+	if l := len(QueryPending(store2)); l != 1 {
+		t.Errorf("Reopened store contains %d pending bundle packs instead of one", l)
+	}
+
+	if _, err := os.Stat(filename + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("Temporary compaction file was not removed")
+	}
+}
+
+func TestMigrateSimpleStore(t *testing.T) {
+	var simpleFilename = boltStoreFilename(t)
+	var boltFilename = boltStoreFilename(t)
+	defer os.Remove(simpleFilename + ".migrated")
+	defer os.Remove(boltFilename)
+
+	simpleStore, err := NewSimpleStore(simpleFilename)
+	if err != nil {
+		t.Fatalf("Creating SimpleStore failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn:dest"),
+				bundle.MustNewEndpointID("dtn:src"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)), 60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world!")),
+			})
+		if err != nil {
+			t.Fatalf("Creating bundle failed: %v", err)
+		}
+
+		bp := NewBundlePack(bndl)
+		bp.AddConstraint(ForwardPending)
+		if err := simpleStore.Push(bp); err != nil {
+			t.Fatalf("Pushing errored: %v", err)
+		}
+	}
+
+	boltStore, err := NewBoltStore(boltFilename)
+	if err != nil {
+		t.Fatalf("Creating BoltStore failed: %v", err)
+	}
+	defer boltStore.Close()
+
+	if n, err := MigrateSimpleStore(simpleFilename, boltStore); err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	} else if n != 3 {
+		t.Errorf("Migrated %d bundle packs instead of three", n)
+	}
+
+	if l := len(QueryAll(boltStore)); l != 3 {
+		t.Errorf("BoltStore contains %d bundle packs instead of three", l)
+	}
+
+	if _, err := os.Stat(simpleFilename); !os.IsNotExist(err) {
+		t.Errorf("SimpleStore's file was not renamed")
+	}
+}
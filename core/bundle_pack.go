@@ -11,20 +11,36 @@ import (
 
 // BundlePack is a set of a bundle, it's creation or reception time stamp and
 // a set of constraints used in the process of delivering this bundle.
+//
+// The Receiver is the endpoint ID of the receiving CLA, while the PreviousNode
+// is the node which forwarded this bundle, as stated in its Previous Node
+// block on reception.
+//
+// Delivered is set after a bundle addressed to a group endpoint was delivered
+// locally. Such a bundle is forwarded afterwards to reach other members.
+//
+// A tombstone is a lightweight BundlePack of an already finished bundle. Its
+// bundle is reduced to the primary block, only remaining to recognize the
+// bundle's ID until the bundle's lifetime is over.
 type BundlePack struct {
-	Bundle      *bundle.Bundle
-	Receiver    bundle.EndpointID
-	Timestamp   time.Time
-	Constraints map[Constraint]bool
+	Bundle       *bundle.Bundle
+	Receiver     bundle.EndpointID
+	PreviousNode bundle.EndpointID
+	Timestamp    time.Time
+	Constraints  map[Constraint]bool
+	Delivered    bool
+	Tombstone    bool
+	Expires      time.Time
 }
 
 // NewBundlePack returns a BundlePack for the given bundle.
 func NewBundlePack(b bundle.Bundle) BundlePack {
 	return BundlePack{
-		Bundle:      &b,
-		Receiver:    bundle.DtnNone(),
-		Timestamp:   time.Now(),
-		Constraints: make(map[Constraint]bool),
+		Bundle:       &b,
+		Receiver:     bundle.DtnNone(),
+		PreviousNode: bundle.DtnNone(),
+		Timestamp:    time.Now(),
+		Constraints:  make(map[Constraint]bool),
 	}
 }
 
@@ -34,6 +50,12 @@ func NewRecBundlePack(b cla.RecBundle) BundlePack {
 	bp := NewBundlePack(b.Bundle)
 	bp.Receiver = b.Receiver
 
+	if cb, err := b.Bundle.ExtensionBlock(bundle.PreviousNodeBlock); err == nil {
+		if prevNode, ok := cb.Data.(bundle.EndpointID); ok {
+			bp.PreviousNode = prevNode
+		}
+	}
+
 	return bp
 }
 
@@ -42,6 +64,18 @@ func (bp BundlePack) HasReceiver() bool {
 	return bp.Receiver != bundle.DtnNone()
 }
 
+// HasPreviousNode returns true if this BundlePack has a PreviousNode value.
+// BundlePacks stored before the PreviousNode's introduction have none.
+func (bp BundlePack) HasPreviousNode() bool {
+	return bp.PreviousNode != bundle.DtnNone() && bp.PreviousNode != bundle.EndpointID{}
+}
+
+// IsPreviousNode returns true if the given endpoint ID is this BundlePack's
+// PreviousNode. Routing algorithms should not send a bundle back to it.
+func (bp BundlePack) IsPreviousNode(eid bundle.EndpointID) bool {
+	return bp.HasPreviousNode() && bp.PreviousNode == eid
+}
+
 // HasConstraint returns true if the given constraint contains.
 func (bp BundlePack) HasConstraint(c Constraint) bool {
 	_, ok := bp.Constraints[c]
@@ -70,18 +104,103 @@ func (bp *BundlePack) PurgeConstraints() {
 	}
 }
 
+// Expiration returns the point in time when this BundlePack's bundle exceeds
+// its lifetime. For bundles without a creation time, the reception time stamp
+// and the Bundle Age block, if present, are taken into account.
+func (bp BundlePack) Expiration() time.Time {
+	if bp.Tombstone {
+		return bp.Expires
+	}
+
+	var pb = bp.Bundle.PrimaryBlock
+	var lifetime = time.Duration(pb.Lifetime) * time.Microsecond
+
+	if ts := pb.CreationTimestamp.DtnTime(); ts != bundle.DtnTimeEpoch {
+		return ts.Time().Add(lifetime)
+	}
+
+	return bp.AgeExpiration()
+}
+
+// AgeExpiration returns the point in time when this BundlePack's bundle
+// exceeds its lifetime, based on the reception time stamp and the Bundle Age
+// block, ignoring the creation time. A bundle without a Bundle Age block is
+// treated as if it had no age on reception.
+func (bp BundlePack) AgeExpiration() time.Time {
+	var lifetime = time.Duration(bp.Bundle.PrimaryBlock.Lifetime) * time.Microsecond
+	var expiration = bp.Timestamp.Add(lifetime)
+
+	if ageBlock, err := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock); err == nil {
+		if age, ok := ageBlock.Data.(uint); ok {
+			expiration = expiration.Add(-time.Duration(age) * time.Microsecond)
+		}
+	}
+
+	return expiration
+}
+
+// IsExpired returns true if this BundlePack's bundle exceeded its lifetime.
+func (bp BundlePack) IsExpired() bool {
+	return time.Now().After(bp.Expiration())
+}
+
+// ToTombstone returns a tombstone of this BundlePack, which has no constraints
+// and only contains the bundle's primary block and its expiration.
+func (bp BundlePack) ToTombstone() BundlePack {
+	return BundlePack{
+		Bundle:       &bundle.Bundle{PrimaryBlock: bp.Bundle.PrimaryBlock},
+		Receiver:     bundle.DtnNone(),
+		PreviousNode: bundle.DtnNone(),
+		Timestamp:    bp.Timestamp,
+		Constraints:  make(map[Constraint]bool),
+		Tombstone:    true,
+		Expires:      bp.Expiration(),
+	}
+}
+
+// copyBundle replaces the BundlePack's bundle by a copy with its own canonical
+// blocks. Thus, modifying a block does not affect other holders of the former
+// bundle, e.g., a CLA which is still sending it.
+func (bp *BundlePack) copyBundle() {
+	var bndl = *bp.Bundle
+	bndl.CanonicalBlocks = append([]bundle.CanonicalBlock(nil), bp.Bundle.CanonicalBlocks...)
+	bp.Bundle = &bndl
+}
+
+// copyConstraints replaces the Constraints by a copy. Thus, a stored
+// BundlePack does not share its Constraints with another BundlePack, which
+// might be altered concurrently.
+func (bp *BundlePack) copyConstraints() {
+	var constraints = make(map[Constraint]bool, len(bp.Constraints))
+	for c, v := range bp.Constraints {
+		constraints[c] = v
+	}
+	bp.Constraints = constraints
+}
+
 // UpdateBundleAge updates the bundle's Bundle Age block based on its reception
-// timestamp, if such a block exists.
+// timestamp, if such a block exists. The reception timestamp is reset
+// afterwards. Thus, repeated updates do not count the same time twice. The
+// block is updated on a copy of the bundle, which might be shared with the
+// store or a CLA.
 func (bp *BundlePack) UpdateBundleAge() (uint, error) {
-	ageBlock, err := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock)
-	if err != nil {
+	if _, err := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock); err != nil {
 		return 0, newCoreError("No such block")
 	}
 
+	bp.copyBundle()
+	ageBlock, _ := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock)
+
+	var now = time.Now()
+
 	age := ageBlock.Data.(uint)
-	offset := uint(time.Now().Sub(bp.Timestamp) / 1000)
+	offset := uint(0)
+	if now.After(bp.Timestamp) {
+		offset = uint(now.Sub(bp.Timestamp) / 1000)
+	}
 
 	(*ageBlock).Data = age + offset
+	bp.Timestamp = now
 
 	return age + offset, nil
 }
@@ -99,6 +218,14 @@ func (bp BundlePack) String() string {
 		fmt.Fprintf(&b, ", %v", bp.Receiver)
 	}
 
+	if bp.HasPreviousNode() {
+		fmt.Fprintf(&b, ", previous node %v", bp.PreviousNode)
+	}
+
+	if bp.Tombstone {
+		fmt.Fprintf(&b, ", tombstone")
+	}
+
 	fmt.Fprintf(&b, ")")
 
 	return b.String()
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
 )
 
 func TestBundlePackUpdateBundleAge(t *testing.T) {
@@ -40,4 +41,52 @@ func TestBundlePackUpdateBundleAge(t *testing.T) {
 	if age < 35000 || age > 65000 {
 		t.Errorf("Bundle's Age Block drifts to much: %v", ageBlock)
 	}
+
+	// The update must not alter the block of a shared bundle.
+	if origBlock, _ := bndl.ExtensionBlock(bundle.BundleAgeBlock); origBlock.Data.(uint) != 0 {
+		t.Errorf("Shared bundle's Age Block was altered: %v", origBlock)
+	}
+
+	// A repeated update must not count the first 50ms again.
+	age, _ = bp.UpdateBundleAge()
+	if age > 65000 {
+		t.Errorf("Bundle's Age Block was counted twice: %v", ageBlock)
+	}
+}
+
+func TestNewRecBundlePackPreviousNode(t *testing.T) {
+	var prevNode = bundle.MustNewEndpointID("dtn:prev")
+
+	var bndl, err = bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 3600),
+		[]bundle.CanonicalBlock{
+			bundle.NewPreviousNodeBlock(1, 0, prevNode),
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bp = NewRecBundlePack(cla.NewRecBundle(bndl, bundle.MustNewEndpointID("dtn:rec")))
+	if !bp.HasPreviousNode() || bp.PreviousNode != prevNode {
+		t.Fatalf("BundlePack's previous node is %v instead of %v", bp.PreviousNode, prevNode)
+	}
+
+	if !bp.IsPreviousNode(prevNode) || bp.IsPreviousNode(bp.Receiver) {
+		t.Fatalf("BundlePack's IsPreviousNode is wrong")
+	}
+
+	bndl.RemoveExtensionBlock(bundle.PreviousNodeBlock)
+	bp = NewRecBundlePack(cla.NewRecBundle(bndl, bundle.MustNewEndpointID("dtn:rec")))
+	if bp.HasPreviousNode() {
+		t.Fatalf("BundlePack without a Previous Node block has the previous node %v", bp.PreviousNode)
+	}
+
+	if (BundlePack{}).HasPreviousNode() {
+		t.Fatalf("Empty BundlePack has a previous node")
+	}
 }
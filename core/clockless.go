@@ -0,0 +1,133 @@
+package core
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// SetClockless enables or disables the clockless mode for nodes without a
+// synchronized clock, which is disabled by default. It should be set before
+// any bundle is processed.
+//
+// In clockless mode, outbounding bundles are created with a zero DTN time and
+// a Bundle Age block. The age of a bundle is updated when it is forwarded and
+// for all stored bundles when the Core is closed. A bundle's lifetime is
+// checked based on its age instead of its creation time.
+// Because the local clock might have been reset since the last run, the time
+// stamps of all stored bundle packs are moved to the present when this mode is
+// enabled. Thus, the time while this node was turned off is not counted.
+func (c *Core) SetClockless(clockless bool) {
+	c.clockless = clockless
+
+	if clockless {
+		c.resetStoredTimestamps()
+	}
+}
+
+// Clockless returns true if this Core operates in clockless mode.
+func (c *Core) Clockless() bool {
+	return c.clockless
+}
+
+// prepareClockless sets the bundle's creation time to zero and inserts a
+// Bundle Age block, if none exists.
+func (c *Core) prepareClockless(bp BundlePack) {
+	var ts = &bp.Bundle.PrimaryBlock.CreationTimestamp
+	ts[0] = uint(bundle.DtnTimeEpoch)
+
+	if _, err := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock); err != nil {
+		bp.Bundle.AddExtensionBlock(bundle.NewBundleAgeBlock(0, bundle.DeleteBundle, 0))
+	}
+}
+
+// isLifetimeExceeded checks if the bundle's lifetime is exceeded, based on its
+// creation time. In clockless mode, the bundle's age is inspected instead.
+func (c *Core) isLifetimeExceeded(bp BundlePack) bool {
+	if c.clockless {
+		return time.Now().After(bp.AgeExpiration())
+	}
+
+	return bp.Bundle.PrimaryBlock.IsLifetimeExceeded()
+}
+
+// isExpired checks if the BundlePack's bundle exceeded its lifetime. In
+// clockless mode, the creation time of a bundle is ignored.
+func (c *Core) isExpired(bp BundlePack) bool {
+	if c.clockless && !bp.Tombstone {
+		return time.Now().After(bp.AgeExpiration())
+	}
+
+	return bp.IsExpired()
+}
+
+// expiration returns the point in time when the BundlePack's bundle exceeds
+// its lifetime. In clockless mode, the creation time of a bundle is ignored.
+func (c *Core) expiration(bp BundlePack) time.Time {
+	if c.clockless && !bp.Tombstone {
+		return bp.AgeExpiration()
+	}
+
+	return bp.Expiration()
+}
+
+// toTombstone returns the BundlePack's tombstone, whose expiration respects the
+// clockless mode.
+func (c *Core) toTombstone(bp BundlePack) BundlePack {
+	var tombstone = bp.ToTombstone()
+	if c.clockless {
+		tombstone.Expires = bp.AgeExpiration()
+	}
+
+	return tombstone
+}
+
+// updateStoredBundleAges adds the time spent in the store to the Bundle Age
+// block of all stored bundles. This is only called on closing, after the
+// Core's goroutine has stopped. Otherwise, this would race the processing of
+// bundles and might store outdated constraints.
+func (c *Core) updateStoredBundleAges() {
+	var bps = c.store.Query(func(bp BundlePack) bool {
+		return !bp.Tombstone
+	})
+
+	for _, bp := range bps {
+		if _, err := bp.UpdateBundleAge(); err != nil {
+			continue
+		}
+
+		if err := c.store.Push(bp); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+				"error":  err,
+			}).Warn("Storing bundle's updated age failed")
+		}
+	}
+}
+
+// resetStoredTimestamps moves the time stamps of all stored bundle packs, and
+// the expirations of tombstones, to the present. Furthermore, the sequence
+// numbers of stored bundles are passed to the IdKeeper because bundles created
+// in clockless mode share the same zero DTN time.
+func (c *Core) resetStoredTimestamps() {
+	var now = time.Now()
+	var bps = c.store.Query(func(_ BundlePack) bool { return true })
+
+	for _, bp := range bps {
+		if bp.Tombstone {
+			bp.Expires = now.Add(bp.Expires.Sub(bp.Timestamp))
+		}
+		bp.Timestamp = now
+
+		if err := c.store.Push(bp); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+				"error":  err,
+			}).Warn("Resetting stored bundle's time stamp failed")
+		}
+
+		c.idKeeper.observe(bp.Bundle)
+	}
+}
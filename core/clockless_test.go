@@ -0,0 +1,145 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// createClocklessStore returns the filename of a new, empty SimpleStore. The
+// returned function removes this file.
+func createClocklessStore(t *testing.T) (string, func()) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+
+	return file.Name(), func() { os.Remove(file.Name()) }
+}
+
+func TestCoreClocklessTransmit(t *testing.T) {
+	storePath, cleanup := createClocklessStore(t)
+	defer cleanup()
+
+	c, err := NewCore(storePath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetClockless(true)
+	if !c.Clockless() {
+		t.Fatalf("Core is not clockless")
+	}
+
+	for i := 0; i < 2; i++ {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn:dest"),
+				bundle.DtnNone(),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.SendBundle(bndl)
+	}
+
+	var bps = QueryAll(c.store)
+	if len(bps) != 2 {
+		t.Fatalf("Store contains %d instead of 2 bundles", len(bps))
+	}
+
+	for _, bp := range bps {
+		if ts := bp.Bundle.PrimaryBlock.CreationTimestamp.DtnTime(); ts != bundle.DtnTimeEpoch {
+			t.Fatalf("Bundle's creation time is %v instead of zero", ts)
+		}
+
+		if _, err := bp.Bundle.ExtensionBlock(bundle.BundleAgeBlock); err != nil {
+			t.Fatalf("Bundle has no Bundle Age block: %v", err)
+		}
+
+		if c.isExpired(bp) {
+			t.Fatalf("Bundle is expired")
+		}
+	}
+
+	if bps[0].Bundle.ID() == bps[1].Bundle.ID() {
+		t.Fatalf("Bundles share the same ID %s", bps[0].Bundle.ID())
+	}
+}
+
+func TestCoreClocklessRestart(t *testing.T) {
+	storePath, cleanup := createClocklessStore(t)
+	defer cleanup()
+
+	store, err := NewSimpleStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// This bundle was created with another, unrelated clock two hours ago.
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow()-2*60*60, 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewBundleAgeBlock(1, bundle.DeleteBundle, 10*60*1000000),
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bp = NewBundlePack(bndl)
+	bp.Timestamp = time.Now().Add(-2 * time.Hour)
+	bp.AddConstraint(Contraindicated)
+
+	if err := store.Push(bp); err != nil {
+		t.Fatal(err)
+	}
+
+	var c = NewCoreWithStore(store, false)
+	defer c.Close()
+
+	if !c.isExpired(bp) {
+		t.Fatalf("Bundle is not expired without clockless mode")
+	}
+
+	c.SetClockless(true)
+
+	stored, ok := store.Lookup(bndl.ID())
+	if !ok {
+		t.Fatalf("Bundle is not stored anymore")
+	}
+
+	if c.isExpired(stored) {
+		t.Fatalf("Bundle is expired in clockless mode")
+	}
+
+	if exp := time.Until(stored.AgeExpiration()); exp < 49*time.Minute || exp > 51*time.Minute {
+		t.Fatalf("Bundle expires in %v instead of 50 minutes", exp)
+	}
+
+	c.updateStoredBundleAges()
+
+	stored, _ = store.Lookup(bndl.ID())
+	ageBlock, _ := stored.Bundle.ExtensionBlock(bundle.BundleAgeBlock)
+	if age := ageBlock.Data.(uint); age < 10*60*1000000 || age > 11*60*1000000 {
+		t.Fatalf("Bundle's age is %d", age)
+	}
+}
@@ -19,6 +19,11 @@ const (
 	// was moved to the contraindicated stage. This Constraint was not defined
 	// in draft-ietf-dtn-bpbis-12, but seemed reasonable for this implementation.
 	Contraindicated Constraint = iota
+
+	// CustodyAccepted is assigned to a bundle if this node accepted its custody.
+	// The bundle is retained until another node accepts its custody. This
+	// Constraint was not defined in draft-ietf-dtn-bpbis-12, but in RFC 5050.
+	CustodyAccepted Constraint = iota
 )
 
 func (c Constraint) String() string {
@@ -32,6 +37,12 @@ func (c Constraint) String() string {
 	case ReassemblyPending:
 		return "reassembly pending"
 
+	case Contraindicated:
+		return "contraindicated"
+
+	case CustodyAccepted:
+		return "custody accepted"
+
 	default:
 		return "unknown"
 	}
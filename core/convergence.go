@@ -155,6 +155,13 @@ func (cqe *convergenceQueueElement) activate(c *Core) (retry bool) {
 			c.reloadConvRecs <- struct{}{}
 		}
 
+		if doSender {
+			c.routing.ReportPeerAppeared(cqe.conv)
+
+			// A new contact might allow to forward pending bundles right now.
+			c.notifyPending()
+		}
+
 		retry = false
 		return
 	}
@@ -179,6 +186,8 @@ func (c *Core) RegisterConvergence(conv cla.Convergence) {
 // removeConvergenceSender removes a (known) ConvergenceSender. It should have
 // been `Close()`ed before.
 func (c *Core) removeConvergenceSender(sender cla.ConvergenceSender) {
+	var removed = false
+
 	c.convergenceMutex.Lock()
 	for i := len(c.convergenceSenders) - 1; i >= 0; i-- {
 		if c.convergenceSenders[i] == sender {
@@ -188,14 +197,21 @@ func (c *Core) removeConvergenceSender(sender cla.ConvergenceSender) {
 
 			c.convergenceSenders = append(
 				c.convergenceSenders[:i], c.convergenceSenders[i+1:]...)
+			removed = true
 		}
 	}
 	c.convergenceMutex.Unlock()
+
+	if removed {
+		c.routing.ReportPeerDisappeared(sender)
+	}
 }
 
 // removeConvergenceReceiver removes a (known) ConvergenceSender. It should have
 // been `Close()`ed before.
 func (c *Core) removeConvergenceReceiver(rec cla.ConvergenceReceiver) {
+	var removed = false
+
 	c.convergenceMutex.Lock()
 	for i := len(c.convergenceReceivers) - 1; i >= 0; i-- {
 		if c.convergenceReceivers[i] == rec {
@@ -205,9 +221,14 @@ func (c *Core) removeConvergenceReceiver(rec cla.ConvergenceReceiver) {
 
 			c.convergenceReceivers = append(
 				c.convergenceReceivers[:i], c.convergenceReceivers[i+1:]...)
+			removed = true
 		}
 	}
 	c.convergenceMutex.Unlock()
+
+	if removed {
+		c.reloadConvRecs <- struct{}{}
+	}
 }
 
 // RemoveConvergence removes a Convergence. It should have been
@@ -222,6 +243,25 @@ func (c *Core) RemoveConvergence(conv cla.Convergence) {
 	}
 }
 
+// CloseConvergence closes and removes a registered Convergence. An enqueued
+// Convergence, waiting for its (re)start, will also be dequeued.
+func (c *Core) CloseConvergence(conv cla.Convergence) {
+	log.WithFields(log.Fields{
+		"cla": conv,
+	}).Info("Closing and removing Convergence")
+
+	c.convergenceMutex.Lock()
+	for i := len(c.convergenceQueue) - 1; i >= 0; i-- {
+		if c.convergenceQueue[i].conv == conv {
+			c.convergenceQueue = append(c.convergenceQueue[:i], c.convergenceQueue[i+1:]...)
+		}
+	}
+	c.convergenceMutex.Unlock()
+
+	conv.Close()
+	c.RemoveConvergence(conv)
+}
+
 // RestartConvergence stops and restarts a Convergence.
 func (c *Core) RestartConvergence(conv cla.Convergence) {
 	log.WithFields(log.Fields{
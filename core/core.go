@@ -1,6 +1,7 @@
 package core
 
 import (
+	"io"
 	"sync"
 	"time"
 
@@ -15,9 +16,13 @@ func isKnownBlockType(blocktype bundle.CanonicalBlockType) bool {
 	switch blocktype {
 	case
 		bundle.PayloadBlock,
+		bundle.IntegrityBlock,
+		bundle.ConfidentialityBlock,
 		bundle.PreviousNodeBlock,
 		bundle.BundleAgeBlock,
-		bundle.HopCountBlock:
+		bundle.HopCountBlock,
+		bundle.SprayAndWaitBlock,
+		bundle.PriorityBlock:
 		return true
 
 	default:
@@ -31,6 +36,11 @@ type Core struct {
 	Agents []ApplicationAgent
 
 	inspectAllBundles bool
+	nodeId            bundle.EndpointID
+	clockless         bool
+
+	integrityContexts       []bundle.IntegrityContext
+	confidentialityContexts []bundle.ConfidentialityContext
 
 	// Used by the convergence methods, defined in core/convergence.go
 	convergenceSenders   []cla.ConvergenceSender
@@ -41,8 +51,21 @@ type Core struct {
 	idKeeper IdKeeper
 	store    Store
 	routing  RoutingAlgorithm
+	metrics  *metrics
+
+	// Used by the storage quota, defined in core/storage_quota.go
+	quota      StorageQuota
+	usage      *storeUsage
+	quotaMutex sync.Mutex
+
+	// Used by the custody transfer, defined in core/custody.go
+	custodyTimeout     time.Duration
+	custodyTimers      map[string]*time.Timer
+	custodyRetransmits map[string]bool
+	custodyMutex       sync.Mutex
 
 	reloadConvRecs chan struct{}
+	pendingSyn     chan struct{}
 	stopSyn        chan struct{}
 	stopAck        chan struct{}
 }
@@ -52,27 +75,41 @@ type Core struct {
 // administrative records - next to the bundles addressed to this node - should
 // be inspected. This allows bundle deletion for forwarding bundles.
 func NewCore(storePath string, inspectAllBundles bool) (*Core, error) {
-	var c = new(Core)
-
-	c.inspectAllBundles = inspectAllBundles
-
 	store, err := NewSimpleStore(storePath)
 	if err != nil {
 		return nil, err
 	}
-	c.store = store
+
+	return NewCoreWithStore(store, inspectAllBundles), nil
+}
+
+// NewCoreWithStore creates and returns a new Core, backed by the given Store.
+// The inspectAllBundles flag behaves like NewCore's. If the Store implements
+// the io.Closer interface, it will be closed together with this Core.
+func NewCoreWithStore(store Store, inspectAllBundles bool) *Core {
+	var c = new(Core)
+
+	c.inspectAllBundles = inspectAllBundles
+	c.nodeId = bundle.DtnNone()
+	c.store = quotaStore{Store: store, c: c}
 
 	c.idKeeper = NewIdKeeper()
+	c.metrics = newMetrics()
 	c.reloadConvRecs = make(chan struct{}, 9000)
+	c.pendingSyn = make(chan struct{}, 1)
 
 	c.routing = NewEpidemicRouting(c, false)
 
+	c.custodyTimeout = DefaultCustodyTimeout
+	c.custodyTimers = make(map[string]*time.Timer)
+	c.custodyRetransmits = make(map[string]bool)
+
 	c.stopSyn = make(chan struct{})
 	c.stopAck = make(chan struct{})
 
 	go c.checkConvergenceReceivers()
 
-	return c, nil
+	return c
 }
 
 // SetRoutingAlgorithm overwrites the used RoutingAlgorithm, which defaults to
@@ -81,16 +118,30 @@ func (c *Core) SetRoutingAlgorithm(routing RoutingAlgorithm) {
 	c.routing = routing
 }
 
+// SetNodeId sets this node's endpoint ID, which defaults to dtn:none. It is
+// inserted as the Previous Node block of forwarded bundles and is required to
+// act as a custodian.
+func (c *Core) SetNodeId(nodeId bundle.EndpointID) {
+	c.nodeId = nodeId
+}
+
+// NodeId returns this node's endpoint ID, which might be dtn:none.
+func (c *Core) NodeId() bundle.EndpointID {
+	return c.nodeId
+}
+
 // checkConvergenceReceivers checks all ConvergenceReceivers for new bundles.
 func (c *Core) checkConvergenceReceivers() {
 	var chnl = cla.JoinReceivers()
 	var tick = time.NewTicker(30 * time.Second)
+	var gcTick = time.NewTicker(garbageCollectionInterval)
 
 	for {
 		select {
 		// Invoked by Close(), shuts down
 		case <-c.stopSyn:
 			tick.Stop()
+			gcTick.Stop()
 
 			c.convergenceMutex.Lock()
 			for _, claRec := range c.convergenceReceivers {
@@ -145,13 +196,15 @@ func (c *Core) checkConvergenceReceivers() {
 			c.convergenceMutex.Unlock()
 
 			// Bundles
-			for _, bp := range QueryPending(c.store) {
-				log.WithFields(log.Fields{
-					"bundle": bp.Bundle,
-				}).Info("Retrying bundle from store")
+			c.dispatchPending()
 
-				c.dispatching(bp)
-			}
+		// Invoked by notifyPending, e.g., for a new contact
+		case <-c.pendingSyn:
+			c.dispatchPending()
+
+		// Replace finished bundles by tombstones, drop expired ones
+		case <-gcTick.C:
+			c.collectGarbage()
 
 		// Invoked by RegisterConvergenceReceiver, recreates chnl
 		case <-c.reloadConvRecs:
@@ -170,11 +223,42 @@ func (c *Core) checkConvergenceReceivers() {
 func (c *Core) Close() {
 	close(c.stopSyn)
 	<-c.stopAck
+
+	c.stopCustodyTimers()
+
+	if c.clockless {
+		c.updateStoredBundleAges()
+	}
+
+	if closer, ok := c.store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Warn("Closing the store errored")
+		}
+	}
 }
 
 // RegisterApplicationAgent adds a new ApplicationAgent to this Core's list.
 func (c *Core) RegisterApplicationAgent(agent ApplicationAgent) {
 	c.Agents = append(c.Agents, agent)
+
+	// Pending bundles might be addressed to this ApplicationAgent.
+	c.notifyPending()
+}
+
+// RegisterIntegrityContext adds a new IntegrityContext, which is used to
+// verify the Block Integrity Blocks of received bundles.
+func (c *Core) RegisterIntegrityContext(ctx bundle.IntegrityContext) {
+	c.integrityContexts = append(c.integrityContexts, ctx)
+}
+
+// RegisterConfidentialityContext adds a new ConfidentialityContext, which is
+// used to decrypt the Block Confidentiality Blocks of bundles addressed to this
+// node. The first registered ConfidentialityContext is also used for
+// encryption by the SimpleRESTAppAgent.
+func (c *Core) RegisterConfidentialityContext(ctx bundle.ConfidentialityContext) {
+	c.confidentialityContexts = append(c.confidentialityContexts, ctx)
 }
 
 // senderForDestination returns an array of ConvergenceSenders whose endpoint ID
@@ -194,11 +278,40 @@ func (c *Core) senderForDestination(endpoint bundle.EndpointID) []cla.Convergenc
 	return css
 }
 
+// senders returns a copy of the ConvergenceSenders, which might be iterated
+// without holding the convergenceMutex.
+func (c *Core) senders() []cla.ConvergenceSender {
+	c.convergenceMutex.Lock()
+	defer c.convergenceMutex.Unlock()
+
+	return append([]cla.ConvergenceSender(nil), c.convergenceSenders...)
+}
+
+// acceptingSenders returns those ConvergenceSenders which accept the bundle,
+// as defined in the cla.ConvergenceFilter interface.
+func acceptingSenders(css []cla.ConvergenceSender, bndl bundle.Bundle) []cla.ConvergenceSender {
+	var accepting []cla.ConvergenceSender
+
+	for _, cs := range css {
+		if filter, ok := cs.(cla.ConvergenceFilter); ok && !filter.Accepts(bndl) {
+			log.WithFields(log.Fields{
+				"bundle": bndl,
+				"cla":    cs,
+			}).Debug("CLA does not accept bundle")
+			continue
+		}
+
+		accepting = append(accepting, cs)
+	}
+
+	return accepting
+}
+
 // hasEndpoint checks if this Core has some endpoint, but does not secure this
 // request with a Mutex. Therefore, the safe HasEndpoint method exists.
 func (c *Core) hasEndpoint(endpoint bundle.EndpointID) bool {
 	for _, agent := range c.Agents {
-		if agent.EndpointID() == endpoint {
+		if agentHasEndpoint(agent, endpoint) {
 			return true
 		}
 	}
@@ -209,6 +322,11 @@ func (c *Core) hasEndpoint(endpoint bundle.EndpointID) bool {
 		}
 	}
 
+	// The node ID receives custody signals, all its services are local ones.
+	if c.nodeId != bundle.DtnNone() && c.nodeId.SameNode(endpoint) {
+		return true
+	}
+
 	return false
 }
 
@@ -0,0 +1,394 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// DefaultCustodyTimeout is the default time a custodian waits for a custody
+// signal before retransmitting a bundle.
+const DefaultCustodyTimeout = 30 * time.Second
+
+// CustodySignalReason is the reason code of a CustodySignal, as defined in
+// section 6.1.2 of RFC 5050.
+type CustodySignalReason uint
+
+const (
+	// CustodyNoInformation is the "No additional information" reason code.
+	CustodyNoInformation CustodySignalReason = 0
+
+	// CustodyRedundantReception is the "Redundant reception" reason code. The
+	// receiving node already is a custodian of this bundle.
+	CustodyRedundantReception CustodySignalReason = 3
+
+	// CustodyDepletedStorage is the "Depleted storage" reason code.
+	CustodyDepletedStorage CustodySignalReason = 4
+
+	// CustodyDestinationUnintelligible is the "Destination endpoint ID
+	// unintelligible" reason code.
+	CustodyDestinationUnintelligible CustodySignalReason = 5
+
+	// CustodyNoKnownRoute is the "No known route to destination from here"
+	// reason code.
+	CustodyNoKnownRoute CustodySignalReason = 6
+
+	// CustodyNoTimelyContact is the "No timely contact with next node on route"
+	// reason code.
+	CustodyNoTimelyContact CustodySignalReason = 7
+
+	// CustodyBlockUnintelligible is the "Block unintelligible" reason code.
+	CustodyBlockUnintelligible CustodySignalReason = 8
+)
+
+func (csr CustodySignalReason) String() string {
+	switch csr {
+	case CustodyNoInformation:
+		return "no additional information"
+
+	case CustodyRedundantReception:
+		return "redundant reception"
+
+	case CustodyDepletedStorage:
+		return "depleted storage"
+
+	case CustodyDestinationUnintelligible:
+		return "destination endpoint ID unintelligible"
+
+	case CustodyNoKnownRoute:
+		return "no known route to destination from here"
+
+	case CustodyNoTimelyContact:
+		return "no timely contact with next node on route"
+
+	case CustodyBlockUnintelligible:
+		return "block unintelligible"
+
+	default:
+		return "unknown"
+	}
+}
+
+// CustodySignal is the content of an administrative record, which reports
+// if a node accepted or refused the custody of a bundle to its custodian. The
+// bundle is identified by its source node and creation timestamp. A fragment
+// is further identified by its offset and payload length, which are both zero
+// for an unfragmented bundle. Its structure is inspired by RFC 5050, but
+// follows the StatusReport's encoding.
+type CustodySignal struct {
+	_struct struct{} `codec:",toarray"`
+
+	Accepted       bool
+	Reason         CustodySignalReason
+	SourceNode     bundle.EndpointID
+	Timestamp      bundle.CreationTimestamp
+	FragmentOffset uint
+	FragmentLength uint
+}
+
+// NewCustodySignal creates a CustodySignal for the given bundle. An accepting
+// CustodySignal should use the CustodyNoInformation reason.
+func NewCustodySignal(bndl bundle.Bundle, accepted bool, reason CustodySignalReason) CustodySignal {
+	var offset, length = fragmentIdentity(bndl)
+
+	return CustodySignal{
+		Accepted:       accepted,
+		Reason:         reason,
+		SourceNode:     bndl.PrimaryBlock.SourceNode,
+		Timestamp:      bndl.PrimaryBlock.CreationTimestamp,
+		FragmentOffset: offset,
+		FragmentLength: length,
+	}
+}
+
+// fragmentIdentity returns a fragment's offset and payload length or zeros
+// for an unfragmented bundle. A FilePayload's length is taken without opening
+// its file.
+func fragmentIdentity(bndl bundle.Bundle) (offset, length uint) {
+	if !bndl.PrimaryBlock.HasFragmentation() {
+		return
+	}
+
+	offset = bndl.PrimaryBlock.FragmentOffset
+
+	if payloadBlock, err := bndl.PayloadBlock(); err == nil {
+		switch data := payloadBlock.Data.(type) {
+		case []byte:
+			length = uint(len(data))
+
+		case bundle.FilePayload:
+			length = uint(data.Size)
+		}
+	}
+
+	return
+}
+
+// Matches returns true if this CustodySignal refers to the given bundle or
+// fragment.
+func (cs CustodySignal) Matches(bndl bundle.Bundle) bool {
+	if bndl.PrimaryBlock.SourceNode != cs.SourceNode ||
+		bndl.PrimaryBlock.CreationTimestamp != cs.Timestamp {
+		return false
+	}
+
+	offset, length := fragmentIdentity(bndl)
+	return offset == cs.FragmentOffset && length == cs.FragmentLength
+}
+
+// ReleasesCustody returns true if the custodian might release the bundle's
+// custody. This is the case for an accepted custody and for a refusal because
+// of a redundant reception, which indicates an already accepted custody.
+func (cs CustodySignal) ReleasesCustody() bool {
+	return cs.Accepted || cs.Reason == CustodyRedundantReception
+}
+
+func (cs CustodySignal) String() string {
+	if cs.FragmentLength > 0 {
+		return fmt.Sprintf("CustodySignal(%t, %v, %v, %v, %d, %d)",
+			cs.Accepted, cs.Reason, cs.SourceNode, cs.Timestamp,
+			cs.FragmentOffset, cs.FragmentLength)
+	}
+
+	return fmt.Sprintf("CustodySignal(%t, %v, %v, %v)",
+		cs.Accepted, cs.Reason, cs.SourceNode, cs.Timestamp)
+}
+
+// SetCustodyTimeout overwrites the time to wait for a custody signal before
+// retransmitting a bundle, which defaults to DefaultCustodyTimeout.
+func (c *Core) SetCustodyTimeout(timeout time.Duration) {
+	c.custodyMutex.Lock()
+	c.custodyTimeout = timeout
+	c.custodyMutex.Unlock()
+}
+
+// requestsCustody returns true if the bundle requests a custody transfer.
+func requestsCustody(bp BundlePack) bool {
+	return bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.RequestCustody)
+}
+
+// acceptCustody accepts the custody of a received bundle, which requested a
+// custody transfer, and informs its previous custodian. Custody is only
+// accepted with a node ID, which is the custodian's endpoint ID in a custody
+// signal, and from a known previous node.
+func (c *Core) acceptCustody(bp *BundlePack) {
+	if !requestsCustody(*bp) || bp.Bundle.IsAdministrativeRecord() {
+		return
+	}
+
+	if c.nodeId == bundle.DtnNone() || !bp.HasPreviousNode() {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Debug("Bundle requests custody, but there is no node ID or previous node")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":    bp.Bundle,
+		"custodian": bp.PreviousNode,
+	}).Info("Accepting bundle's custody")
+
+	bp.AddConstraint(CustodyAccepted)
+	c.store.Push(*bp)
+
+	c.SendCustodySignal(*bp, true, CustodyNoInformation)
+}
+
+// refuseRedundantCustody informs the custodian of an already known bundle,
+// which requested a custody transfer, about its redundant reception. Thus, the
+// custodian might release this bundle.
+func (c *Core) refuseRedundantCustody(bp BundlePack) {
+	if !requestsCustody(bp) || bp.Bundle.IsAdministrativeRecord() {
+		return
+	}
+
+	if c.nodeId == bundle.DtnNone() || !bp.HasPreviousNode() {
+		return
+	}
+
+	c.SendCustodySignal(bp, false, CustodyRedundantReception)
+}
+
+// SendCustodySignal creates a new custody signal in response to the given
+// BundlePack and transmits it to its previous node, the bundle's custodian.
+func (c *Core) SendCustodySignal(bp BundlePack, accepted bool, reason CustodySignalReason) {
+	if !bp.HasPreviousNode() || c.nodeId == bundle.DtnNone() {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Warn("Failed to create custody signal, no previous node or node ID")
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":    bp.Bundle,
+		"custodian": bp.PreviousNode,
+		"accepted":  accepted,
+		"reason":    reason,
+	}).Info("Sending a custody signal for a bundle")
+
+	var cs = NewCustodySignal(*bp.Bundle, accepted, reason)
+	var ar = NewAdministrativeRecord(CustodySignalTypeCode, cs)
+
+	var outBndl, err = bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.AdministrativeRecordPayload,
+			bp.PreviousNode,
+			c.nodeId,
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewHopCountBlock(23, 0, bundle.NewHopCount(5)),
+			ar.ToCanonicalBlock(),
+		})
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Creating custody signal bundle failed")
+
+		return
+	}
+
+	c.transmit(NewBundlePack(outBndl))
+}
+
+// custodyReleased returns true if the stored BundlePack is no longer in this
+// node's custody, e.g., because a custody signal was received meanwhile.
+func (c *Core) custodyReleased(bp BundlePack) bool {
+	bpStore, ok := LookupBundle(c.store, bp.Bundle.ID())
+	return !ok || !bpStore.HasConstraint(CustodyAccepted)
+}
+
+// startCustodyTimer (re)starts the custody timer of a bundle, for which this
+// node is a custodian. After the custody timeout without a releasing custody
+// signal, the bundle will be retransmitted.
+func (c *Core) startCustodyTimer(bp BundlePack) {
+	var id = bp.Bundle.ID()
+
+	c.custodyMutex.Lock()
+	defer c.custodyMutex.Unlock()
+
+	if timer, ok := c.custodyTimers[id]; ok {
+		timer.Stop()
+	}
+
+	c.custodyTimers[id] = time.AfterFunc(c.custodyTimeout, func() {
+		c.custodyTimerExpired(id)
+	})
+
+	log.WithFields(log.Fields{
+		"bundle":  bp.Bundle,
+		"timeout": c.custodyTimeout,
+	}).Debug("Started custody timer")
+}
+
+// stopCustodyTimer stops the custody timer of the bundle ID, if one exists.
+func (c *Core) stopCustodyTimer(id string) {
+	c.custodyMutex.Lock()
+	defer c.custodyMutex.Unlock()
+
+	if timer, ok := c.custodyTimers[id]; ok {
+		timer.Stop()
+		delete(c.custodyTimers, id)
+	}
+}
+
+// stopCustodyTimers stops all custody timers. This is called on closing.
+func (c *Core) stopCustodyTimers() {
+	c.custodyMutex.Lock()
+	defer c.custodyMutex.Unlock()
+
+	for id, timer := range c.custodyTimers {
+		timer.Stop()
+		delete(c.custodyTimers, id)
+	}
+}
+
+// custodyTimerExpired marks a bundle, whose custody was not released in time,
+// for its retransmission. This is performed by the Core's goroutine, as part of
+// the requested retry of pending bundles.
+func (c *Core) custodyTimerExpired(id string) {
+	c.custodyMutex.Lock()
+	delete(c.custodyTimers, id)
+	c.custodyRetransmits[id] = true
+	c.custodyMutex.Unlock()
+
+	c.notifyPending()
+}
+
+// custodyRetransmissions returns the bundles marked for retransmission by
+// their expired custody timer and clears these marks. Released or expired
+// bundles and those already pending are skipped.
+func (c *Core) custodyRetransmissions() []BundlePack {
+	c.custodyMutex.Lock()
+	var ids = c.custodyRetransmits
+	c.custodyRetransmits = make(map[string]bool)
+	c.custodyMutex.Unlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var bps = c.store.Query(func(bp BundlePack) bool {
+		return ids[bp.Bundle.ID()] && bp.HasConstraint(CustodyAccepted) && !bp.HasConstraint(Contraindicated)
+	})
+
+	var retransmits []BundlePack
+	for _, bp := range bps {
+		if c.isExpired(bp) {
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+			}).Info("Custody timer expired for an expired bundle, no retransmission")
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Info("Custody timer expired, retransmitting bundle")
+
+		retransmits = append(retransmits, bp)
+	}
+
+	return retransmits
+}
+
+// inspectCustodySignal handles a received custody signal. A releasing custody
+// signal stops the custody timer and releases the stored bundle. Otherwise,
+// the bundle will be retransmitted after its custody timer expired.
+func (c *Core) inspectCustodySignal(bp BundlePack, cs CustodySignal) {
+	var bpStores = QueryFromCustodySignal(c.store, cs)
+	if len(bpStores) != 1 {
+		log.WithFields(log.Fields{
+			"bundle":      bp.Bundle,
+			"custody_sig": cs,
+			"store_numb":  len(bpStores),
+		}).Warn("Custody signal's bundle is unknown")
+		return
+	}
+
+	var bpStore = bpStores[0]
+	if !cs.ReleasesCustody() {
+		log.WithFields(log.Fields{
+			"bundle":         bp.Bundle,
+			"custody_sig":    cs,
+			"custody_bundle": bpStore,
+		}).Info("Custody signal refused custody, waiting for retransmission")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":         bp.Bundle,
+		"custody_sig":    cs,
+		"custody_bundle": bpStore,
+	}).Info("Custody signal released custody, releasing bundle")
+
+	c.stopCustodyTimer(bpStore.Bundle.ID())
+
+	bpStore.RemoveConstraint(CustodyAccepted)
+	c.store.Push(bpStore)
+}
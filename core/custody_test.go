@@ -0,0 +1,351 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// readCustodySignal reads a bundle from the peerSender, which must contain a
+// custody signal.
+func readCustodySignal(t *testing.T, ps peerSender) CustodySignal {
+	select {
+	case bndl := <-ps.bundles:
+		payload, err := bndl.PayloadBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ar, err := NewAdministrativeRecordFromCbor(payload.Data.([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cs, ok := ar.Content.(CustodySignal)
+		if !ok {
+			t.Fatalf("Administrative record is no custody signal: %v", ar)
+		}
+		return cs
+
+	case <-time.After(time.Second):
+		t.Fatal("No custody signal was sent")
+	}
+
+	return CustodySignal{}
+}
+
+func TestCustodySignalCbor(t *testing.T) {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.RequestCustody,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []CustodySignal{
+		NewCustodySignal(bndl, true, CustodyNoInformation),
+		NewCustodySignal(bndl, false, CustodyRedundantReception),
+		NewCustodySignal(bndl, false, CustodyNoKnownRoute),
+	}
+
+	for _, cs := range tests {
+		var ar = NewAdministrativeRecord(CustodySignalTypeCode, cs)
+		var cb = ar.ToCanonicalBlock()
+
+		arDec, err := NewAdministrativeRecordFromCbor(cb.Data.([]byte))
+		if err != nil {
+			t.Fatalf("Decoding %v failed: %v", ar, err)
+		}
+
+		if !reflect.DeepEqual(ar, arDec) {
+			t.Fatalf("CBOR result differs: %v, %v", ar, arDec)
+		}
+
+		if rel := arDec.Content.(CustodySignal).ReleasesCustody(); rel != (cs.Accepted || cs.Reason == CustodyRedundantReception) {
+			t.Fatalf("%v releases custody: %t", cs, rel)
+		}
+	}
+}
+
+func TestCustodyRetransmission(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn:alpha"))
+	c.SetCustodyTimeout(100 * time.Millisecond)
+
+	var senderBeta = newPeerSender("dtn:beta")
+	c.convergenceSenders = append(c.convergenceSenders, senderBeta)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.RequestCustody,
+			bundle.MustNewEndpointID("dtn:beta"),
+			bundle.MustNewEndpointID("dtn:alpha"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SendBundle(bndl)
+
+	// The bundle is sent initially and retransmitted without a custody signal.
+	for i := 0; i < 2; i++ {
+		select {
+		case sent := <-senderBeta.bundles:
+			if sent.ID() != bndl.ID() {
+				t.Fatalf("Sent bundle %v differs from %v", sent, bndl)
+			}
+
+			prevNode, err := sent.ExtensionBlock(bundle.PreviousNodeBlock)
+			if err != nil || prevNode.Data.(bundle.EndpointID) != c.NodeId() {
+				t.Fatalf("Sent bundle's Previous Node block is not the custodian: %v", prevNode)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("Bundle was not sent for the %d. time", i+1)
+		}
+	}
+
+	if bps := QueryFromCustodySignal(c.store, NewCustodySignal(bndl, true, CustodyNoInformation)); len(bps) != 1 {
+		t.Fatalf("Custodian's store contains %d bundles in custody", len(bps))
+	}
+
+	// An accepting custody signal releases the bundle.
+	var ar = NewAdministrativeRecord(CustodySignalTypeCode,
+		NewCustodySignal(bndl, true, CustodyNoInformation))
+	signal, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.AdministrativeRecordPayload,
+			c.NodeId(),
+			bundle.MustNewEndpointID("dtn:beta"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{ar.ToCanonicalBlock()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewRecBundlePack(cla.NewRecBundle(signal, c.NodeId())))
+
+	if bps := QueryFromCustodySignal(c.store, NewCustodySignal(bndl, true, CustodyNoInformation)); len(bps) != 0 {
+		t.Fatalf("Custodian's store contains %d bundles in custody after release", len(bps))
+	}
+
+	// Drain a retransmission which might have been started before the release.
+	time.Sleep(50 * time.Millisecond)
+	for len(senderBeta.bundles) > 0 {
+		<-senderBeta.bundles
+	}
+
+	select {
+	case sent := <-senderBeta.bundles:
+		t.Fatalf("Released bundle was retransmitted: %v", sent)
+
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestCustodyAcceptance(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn:beta"))
+
+	var senderAlpha = newPeerSender("dtn:alpha")
+	c.convergenceSenders = append(c.convergenceSenders, senderAlpha)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.RequestCustody,
+			bundle.MustNewEndpointID("dtn:gamma"),
+			bundle.MustNewEndpointID("dtn:alpha"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPreviousNodeBlock(2, 0, bundle.MustNewEndpointID("dtn:alpha")),
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bndlCbor = bndl.ToCbor()
+
+	// Each reception gets its own copy, like decoded by a CLA.
+	var receive = func() {
+		bndlRec, err := bundle.NewBundleFromCbor(bndlCbor)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.receive(NewRecBundlePack(cla.NewRecBundle(bndlRec, c.NodeId())))
+	}
+
+	receive()
+
+	if cs := readCustodySignal(t, senderAlpha); !cs.Accepted || cs.SourceNode != bndl.PrimaryBlock.SourceNode {
+		t.Fatalf("Custody signal does not accept custody: %v", cs)
+	}
+
+	if bps := QueryFromCustodySignal(c.store, NewCustodySignal(bndl, true, CustodyNoInformation)); len(bps) != 1 {
+		t.Fatalf("Store contains %d bundles in custody", len(bps))
+	}
+
+	// A redundant reception will be refused, allowing the release.
+	receive()
+
+	if cs := readCustodySignal(t, senderAlpha); cs.Accepted || cs.Reason != CustodyRedundantReception {
+		t.Fatalf("Custody signal does not refuse a redundant reception: %v", cs)
+	}
+}
+
+func TestCustodySignalFragments(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.RequestCustody,
+			bundle.MustNewEndpointID("dtn:gamma"),
+			bundle.MustNewEndpointID("dtn:alpha"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, make([]byte, 1024)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frags, err := bndl.Fragment(512)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(frags) < 2 {
+		t.Fatalf("Bundle was split into %d fragments", len(frags))
+	}
+
+	for _, frag := range frags {
+		var bp = NewBundlePack(frag)
+		bp.AddConstraint(CustodyAccepted)
+		c.store.Push(bp)
+	}
+
+	for _, frag := range frags {
+		var cs = NewCustodySignal(frag, true, CustodyNoInformation)
+
+		bps := QueryFromCustodySignal(c.store, cs)
+		if len(bps) != 1 {
+			t.Fatalf("%v matches %d instead of 1 fragments", cs, len(bps))
+		} else if bps[0].Bundle.ID() != frag.ID() {
+			t.Fatalf("%v matches fragment %v instead of %v", cs, bps[0].Bundle.ID(), frag.ID())
+		}
+	}
+
+	if bps := QueryFromCustodySignal(c.store, NewCustodySignal(bndl, true, CustodyNoInformation)); len(bps) != 0 {
+		t.Fatalf("Custody signal of the whole bundle matches %d fragments", len(bps))
+	}
+}
+
+func TestCustodyRefusedForDeletedBundle(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn:beta"))
+
+	var senderAlpha = newPeerSender("dtn:alpha")
+	c.convergenceSenders = append(c.convergenceSenders, senderAlpha)
+
+	// An unknown block requests the bundle's deletion.
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented|bundle.RequestCustody,
+			bundle.MustNewEndpointID("dtn:gamma"),
+			bundle.MustNewEndpointID("dtn:alpha"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPreviousNodeBlock(2, 0, bundle.MustNewEndpointID("dtn:alpha")),
+			bundle.NewCanonicalBlock(192, 3, bundle.DeleteBundle, []byte{0x23}),
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewRecBundlePack(cla.NewRecBundle(bndl, c.NodeId())))
+
+	select {
+	case sent := <-senderAlpha.bundles:
+		t.Fatalf("Deleted bundle's custody was accepted: %v", sent)
+
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if bps := QueryFromCustodySignal(c.store, NewCustodySignal(bndl, true, CustodyNoInformation)); len(bps) != 0 {
+		t.Fatalf("Store contains %d bundles in custody", len(bps))
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// compileGlob compiles a glob for endpoint IDs, where "*" matches any sequence
+// of characters and "?" matches a single character, into a regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var expr = regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+
+	return regexp.Compile("^" + expr + "$")
+}
+
+// EndpointPattern is a glob for endpoint IDs, used by PatternAgents to register
+// a whole set of endpoint IDs. Like a StaticRoute's Pattern, "*" matches any
+// sequence of characters and "?" matches a single character.
+//
+// Example: "dtn://alpha/*" for all services of the node "dtn://alpha/" or
+// "ipn:23.*" for all services of the node 23.
+type EndpointPattern struct {
+	Pattern string
+
+	regex *regexp.Regexp
+}
+
+// NewEndpointPattern creates a new EndpointPattern. An error is returned for an
+// invalid pattern.
+func NewEndpointPattern(pattern string) (EndpointPattern, error) {
+	regex, err := compileGlob(pattern)
+	if err != nil {
+		return EndpointPattern{}, err
+	}
+
+	return EndpointPattern{
+		Pattern: pattern,
+		regex:   regex,
+	}, nil
+}
+
+// MustNewEndpointPattern returns a new EndpointPattern like NewEndpointPattern,
+// but panics in case of an error.
+func MustNewEndpointPattern(pattern string) EndpointPattern {
+	ep, err := NewEndpointPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	return ep
+}
+
+// Matches returns true if the endpoint ID matches this EndpointPattern.
+func (ep EndpointPattern) Matches(eid bundle.EndpointID) bool {
+	return ep.regex != nil && ep.regex.MatchString(eid.String())
+}
+
+func (ep EndpointPattern) String() string {
+	return ep.Pattern
+}
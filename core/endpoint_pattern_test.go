@@ -0,0 +1,121 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// patternAgent is a dummy PatternAgent, collecting all delivered bundles.
+type patternAgent struct {
+	eid       bundle.EndpointID
+	patterns  []EndpointPattern
+	delivered []*bundle.Bundle
+}
+
+func (pa *patternAgent) EndpointID() bundle.EndpointID {
+	return pa.eid
+}
+
+func (pa *patternAgent) EndpointPatterns() []EndpointPattern {
+	return pa.patterns
+}
+
+func (pa *patternAgent) Deliver(bndl *bundle.Bundle) error {
+	pa.delivered = append(pa.delivered, bndl)
+	return nil
+}
+
+func TestEndpointPatternMatches(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		eid     string
+		matches bool
+	}{
+		{"dtn://alpha/*", "dtn://alpha/", true},
+		{"dtn://alpha/*", "dtn://alpha/mail", true},
+		{"dtn://alpha/*", "dtn://beta/mail", false},
+		{"dtn://alpha/mail/?", "dtn://alpha/mail/1", true},
+		{"ipn:23.*", "ipn:23.42", true},
+		{"ipn:23.*", "ipn:230.42", false},
+	}
+
+	for _, test := range tests {
+		var pattern = MustNewEndpointPattern(test.pattern)
+		if m := pattern.Matches(bundle.MustNewEndpointID(test.eid)); m != test.matches {
+			t.Fatalf("Pattern %s matching %s resulted in %t", test.pattern, test.eid, m)
+		}
+	}
+
+	if (EndpointPattern{}).Matches(bundle.DtnNone()) {
+		t.Fatalf("Empty pattern matches")
+	}
+}
+
+func TestCoreServiceDemux(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+
+	var mailAgent = &patternAgent{
+		eid:      bundle.MustNewEndpointID("dtn://alpha/mail"),
+		patterns: []EndpointPattern{MustNewEndpointPattern("dtn://alpha/mail/*")},
+	}
+	var chatAgent = &patternAgent{
+		eid: bundle.MustNewEndpointID("dtn://alpha/chat"),
+	}
+
+	c.RegisterApplicationAgent(mailAgent)
+	c.RegisterApplicationAgent(chatAgent)
+
+	for _, eid := range []string{"dtn://alpha/mail", "dtn://alpha/mail/inbox", "dtn://alpha/other"} {
+		if !c.HasEndpoint(bundle.MustNewEndpointID(eid)) {
+			t.Fatalf("Core does not recognize %s", eid)
+		}
+	}
+
+	if c.HasEndpoint(bundle.MustNewEndpointID("dtn://beta/mail")) {
+		t.Fatalf("Core recognizes another node's service")
+	}
+
+	for i, dest := range []string{"dtn://alpha/mail/inbox", "dtn://alpha/chat"} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID(dest),
+				bundle.MustNewEndpointID("dtn://beta/"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.receive(NewBundlePack(bndl))
+	}
+
+	if len(mailAgent.delivered) != 1 || mailAgent.delivered[0].PrimaryBlock.Destination.Demux() != "mail/inbox" {
+		t.Fatalf("Mail agent received %v", mailAgent.delivered)
+	}
+
+	if len(chatAgent.delivered) != 1 || chatAgent.delivered[0].PrimaryBlock.Destination.Demux() != "chat" {
+		t.Fatalf("Chat agent received %v", chatAgent.delivered)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// forEachFragment passes the bundles to be sent to the given
+// ConvergenceSender to the function. If this CLA advertises a maximum bundle
+// size, as defined in the cla.ConvergenceMaxSize interface, and the bundle
+// exceeds this size, it will be fragmented. Each fragment is created only
+// right before it is passed, to keep a FilePayload out of memory.
+func forEachFragment(bndl bundle.Bundle, node cla.ConvergenceSender, f func(bundle.Bundle) error) error {
+	maxSizeNode, ok := node.(cla.ConvergenceMaxSize)
+	if !ok {
+		return f(bndl)
+	}
+
+	return bndl.ForEachFragment(maxSizeNode.MaxBundleSize(), f)
+}
+
+// reassemble stores the BundlePack of a received fragment and tries to
+// reassemble its original bundle. If all fragments are present, a BundlePack
+// of the reassembled bundle and true is returned and the fragments are
+// released. Otherwise, the fragment remains in the store and false is returned.
+func (c *Core) reassemble(bp BundlePack) (BundlePack, bool) {
+	bp.AddConstraint(ReassemblyPending)
+	bp.RemoveConstraint(DispatchPending)
+	c.store.Push(bp)
+
+	var fragPacks = QueryFragments(c.store, *bp.Bundle)
+	var frags = make([]bundle.Bundle, len(fragPacks))
+	for i, fragPack := range fragPacks {
+		frags[i] = *fragPack.Bundle
+	}
+
+	bndl, err := bundle.ReassembleFragments(frags)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"bundle":    bp.Bundle,
+			"fragments": len(frags),
+			"reason":    err,
+		}).Debug("Bundle's reassembly is pending")
+
+		return bp, false
+	}
+
+	log.WithFields(log.Fields{
+		"bundle":    &bndl,
+		"fragments": len(frags),
+	}).Info("Bundle was reassembled from its fragments")
+
+	for _, fragPack := range fragPacks {
+		fragPack.PurgeConstraints()
+		c.store.Push(fragPack)
+	}
+
+	var reassembled = NewBundlePack(bndl)
+	reassembled.Receiver = bp.Receiver
+	reassembled.PreviousNode = bp.PreviousNode
+	reassembled.AddConstraint(DispatchPending)
+	c.store.Push(reassembled)
+
+	return reassembled, true
+}
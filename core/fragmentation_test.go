@@ -0,0 +1,116 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// maxSizeSender is a dummy ConvergenceSender, implementing the
+// ConvergenceMaxSize interface.
+type maxSizeSender struct {
+	maxSize uint
+}
+
+func (mss maxSizeSender) Start() (error, bool)                 { return nil, false }
+func (mss maxSizeSender) Close()                               {}
+func (mss maxSizeSender) Address() string                      { return "dummy://" }
+func (mss maxSizeSender) IsPermanent() bool                    { return false }
+func (mss maxSizeSender) Send(_ bundle.Bundle) error           { return nil }
+func (mss maxSizeSender) GetPeerEndpointID() bundle.EndpointID { return bundle.DtnNone() }
+func (mss maxSizeSender) MaxBundleSize() uint                  { return mss.maxSize }
+
+func TestFragmentsForSender(t *testing.T) {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			0,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, make([]byte, 4096)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fragments = func(maxSize uint) (frags []bundle.Bundle) {
+		err := forEachFragment(bndl, maxSizeSender{maxSize: maxSize}, func(frag bundle.Bundle) error {
+			frags = append(frags, frag)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if frags := fragments(1 << 16); len(frags) != 1 || !reflect.DeepEqual(frags[0], bndl) {
+		t.Fatalf("Bundle was fragmented for a large enough CLA: %v", frags)
+	}
+
+	if frags := fragments(1024); len(frags) < 4 {
+		t.Fatalf("Bundle was fragmented into %d fragments", len(frags))
+	}
+}
+
+func TestCoreReassemble(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			0,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, make([]byte, 4096)),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frags, err := bndl.Fragment(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, frag := range frags {
+		bp, complete := c.reassemble(NewBundlePack(frag))
+
+		if last := i == len(frags)-1; complete != last {
+			t.Fatalf("Reassembly of fragment %d of %d has completion state %t",
+				i, len(frags), complete)
+		} else if !last {
+			if !bp.HasConstraint(ReassemblyPending) {
+				t.Fatalf("Fragment %d is not pending reassembly", i)
+			}
+
+			continue
+		}
+
+		if !reflect.DeepEqual(*bp.Bundle, bndl) {
+			t.Fatalf("Reassembled bundle differs: %v, %v", bp.Bundle, bndl)
+		}
+	}
+
+	if fragPacks := QueryFragments(c.store, bndl); len(fragPacks) != 0 {
+		t.Fatalf("Store still contains %d fragments pending reassembly", len(fragPacks))
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// garbageCollectionInterval is the interval between two runs of the Core's
+// garbage collection.
+const garbageCollectionInterval = 5 * time.Minute
+
+// collectGarbage inspects all stored bundle packs. Finished bundle packs are
+// replaced by tombstones, which are dropped after their bundle's lifetime is
+// over. Bundle packs whose lifetime has elapsed are removed altogether.
+// Afterwards, unreferenced payload files are removed.
+func (c *Core) collectGarbage() {
+	var tombstones, deletions int
+
+	var bps = c.store.Query(func(bp BundlePack) bool {
+		return c.isExpired(bp) || !bp.Tombstone && !bp.HasConstraints()
+	})
+
+	for _, bp := range bps {
+		if !c.isExpired(bp) {
+			if err := c.store.Push(c.toTombstone(bp)); err != nil {
+				log.WithFields(log.Fields{
+					"bundle": bp.Bundle,
+					"error":  err,
+				}).Warn("Garbage collection failed to replace bundle by its tombstone")
+			} else {
+				tombstones++
+			}
+
+			continue
+		}
+
+		if !bp.Tombstone && bp.HasConstraints() {
+			c.metrics.countDeleted(LifetimeExpired)
+
+			if bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDeletion) {
+				c.SendStatusReport(bp, DeletedBundle, LifetimeExpired)
+			}
+		}
+
+		if err := c.store.Delete(bp.Bundle.ID()); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+				"error":  err,
+			}).Warn("Garbage collection failed to delete expired bundle")
+		} else {
+			deletions++
+		}
+	}
+
+	c.collectPayloadFiles()
+
+	log.WithFields(log.Fields{
+		"tombstones": tombstones,
+		"deletions":  deletions,
+	}).Debug("Garbage collection finished")
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestBundlePackTombstone(t *testing.T) {
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bp = NewBundlePack(bndl)
+	bp.AddConstraint(ForwardPending)
+
+	var tombstone = bp.ToTombstone()
+
+	if !tombstone.Tombstone || tombstone.HasConstraints() {
+		t.Fatalf("Tombstone is malformed: %v", tombstone)
+	}
+
+	if len(tombstone.Bundle.CanonicalBlocks) != 0 {
+		t.Fatalf("Tombstone contains canonical blocks")
+	}
+
+	if tombstone.Bundle.ID() != bp.Bundle.ID() {
+		t.Fatalf("Tombstone's ID %s differs from %s", tombstone.Bundle.ID(), bp.Bundle.ID())
+	}
+
+	if !tombstone.Expiration().Equal(bp.Expiration()) {
+		t.Fatalf("Tombstone's expiration %v differs from %v",
+			tombstone.Expiration(), bp.Expiration())
+	}
+
+	if tombstone.IsExpired() {
+		t.Fatalf("Tombstone is expired")
+	}
+}
+
+func TestCoreCollectGarbage(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var expired = bundle.DtnTimeFromTime(time.Now().Add(-2 * time.Hour))
+
+	var bps []BundlePack
+	for i, creation := range []bundle.DtnTime{bundle.DtnTimeNow(), bundle.DtnTimeNow(), expired, expired} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn:dest"),
+				bundle.MustNewEndpointID("dtn:src"),
+				bundle.NewCreationTimestamp(creation, uint(i)), 60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bps = append(bps, NewBundlePack(bndl))
+	}
+
+	var finished, pending, expiredPending = bps[0], bps[1], bps[2]
+	pending.AddConstraint(Contraindicated)
+	expiredPending.AddConstraint(Contraindicated)
+	var expiredTombstone = bps[3].ToTombstone()
+
+	for _, bp := range []BundlePack{finished, pending, expiredPending, expiredTombstone} {
+		if err := c.store.Push(bp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.collectGarbage()
+
+	var stored = make(map[string]BundlePack)
+	for _, bp := range c.store.Query(func(_ BundlePack) bool { return true }) {
+		stored[bp.Bundle.ID()] = bp
+	}
+
+	if len(stored) != 2 {
+		t.Fatalf("Store contains %d bundle packs instead of two", len(stored))
+	}
+
+	if bp, ok := stored[finished.Bundle.ID()]; !ok || !bp.Tombstone {
+		t.Fatalf("Finished bundle pack was not replaced by a tombstone: %v", bp)
+	}
+
+	if bp, ok := stored[pending.Bundle.ID()]; !ok || bp.Tombstone || !bp.HasConstraint(Contraindicated) {
+		t.Fatalf("Pending bundle pack was modified: %v", bp)
+	}
+
+	if !KnowsBundle(c.store, finished) {
+		t.Fatalf("Tombstone's bundle is unknown")
+	}
+}
+
+func TestCoreCollectPayloadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := bundle.SetPayloadSpool(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer bundle.SetPayloadSpool("", 0)
+
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var newPayloadFile = func(age time.Duration) bundle.FilePayload {
+		f, err := bundle.NewPayloadFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("hello world")
+		f.Close()
+
+		var mtime = time.Now().Add(-age)
+		if err := os.Chtimes(f.Name(), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		fp, err := bundle.NewFilePayload(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+
+	var referenced = newPayloadFile(time.Hour)
+	var unreferenced = newPayloadFile(time.Hour)
+	var recent = newPayloadFile(0)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewFilePayloadBlock(0, referenced),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bp = NewBundlePack(bndl)
+	bp.AddConstraint(ForwardPending)
+
+	if err := c.store.Push(bp); err != nil {
+		t.Fatal(err)
+	}
+
+	c.collectGarbage()
+
+	for _, fp := range []bundle.FilePayload{referenced, recent} {
+		if _, err := os.Stat(fp.Path); err != nil {
+			t.Fatalf("Payload file %s was removed: %v", fp.Path, err)
+		}
+	}
+
+	if _, err := os.Stat(unreferenced.Path); !os.IsNotExist(err) {
+		t.Fatalf("Unreferenced payload file %s was not removed: %v", unreferenced.Path, err)
+	}
+}
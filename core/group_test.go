@@ -0,0 +1,76 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestCoreGroupDelivery(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+
+	var group = bundle.MustNewEndpointID("dtn://chat/~members")
+	var agents = []*patternAgent{
+		{eid: group},
+		{eid: bundle.MustNewEndpointID("dtn://alpha/chat"),
+			patterns: []EndpointPattern{MustNewEndpointPattern("dtn://chat/~*")}},
+		{eid: bundle.MustNewEndpointID("dtn://alpha/mail")},
+	}
+	for _, agent := range agents {
+		c.RegisterApplicationAgent(agent)
+	}
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			group,
+			bundle.MustNewEndpointID("dtn://beta/"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewBundlePack(bndl))
+
+	for i, expected := range []int{1, 1, 0} {
+		if l := len(agents[i].delivered); l != expected {
+			t.Fatalf("Agent %d received %d instead of %d bundles", i, l, expected)
+		}
+	}
+
+	// Without any CLA, the group bundle must remain for forwarding.
+	var bps = QueryPending(c.store)
+	if len(bps) != 1 || !bps[0].Delivered {
+		t.Fatalf("Group bundle is not pending for forwarding: %v", bps)
+	}
+
+	// Retrying the bundle must not deliver it again.
+	c.dispatching(bps[0])
+
+	for i, expected := range []int{1, 1, 0} {
+		if l := len(agents[i].delivered); l != expected {
+			t.Fatalf("Agent %d received %d instead of %d bundles after retry", i, l, expected)
+		}
+	}
+}
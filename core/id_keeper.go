@@ -57,6 +57,20 @@ func (idk *IdKeeper) update(bndl *bundle.Bundle) {
 	}
 }
 
+// observe raises the IdKeeper's state regarding this bundle to at least its
+// sequence number. Thus, already used sequence numbers, e.g., of stored bundles
+// after a restart, will not be assigned again.
+func (idk *IdKeeper) observe(bndl *bundle.Bundle) {
+	var tpl = newIdTuple(bndl)
+	var seq = bndl.PrimaryBlock.CreationTimestamp.SequenceNumber()
+
+	idk.mutex.Lock()
+	if state, ok := idk.data[tpl]; !ok || state < seq {
+		idk.data[tpl] = seq
+	}
+	idk.mutex.Unlock()
+}
+
 // clean removes states which are older an hour and aren't the epoch time.
 func (idk *IdKeeper) clean() {
 	idk.mutex.Lock()
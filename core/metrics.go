@@ -0,0 +1,198 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metrics collects the counters of a Core's bundle processing. Gauges, e.g.,
+// the store's size, are calculated on demand by the Core's WriteMetrics.
+type metrics struct {
+	received  uint64
+	forwarded uint64
+	delivered uint64
+	deleted   map[StatusReportReason]uint64
+
+	bytesReceived map[string]uint64
+	bytesSent     map[string]uint64
+
+	mutex sync.Mutex
+}
+
+// newMetrics creates a new, empty metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		deleted:       make(map[StatusReportReason]uint64),
+		bytesReceived: make(map[string]uint64),
+		bytesSent:     make(map[string]uint64),
+	}
+}
+
+// countReceived counts a received bundle of the given size for the receiving
+// CLA's endpoint.
+func (m *metrics) countReceived(receiver string, size int) {
+	m.mutex.Lock()
+	m.received++
+	m.bytesReceived[receiver] += uint64(size)
+	m.mutex.Unlock()
+}
+
+// countSent counts the bytes sent by a CLA, identified by its address.
+func (m *metrics) countSent(cla string, size int) {
+	m.mutex.Lock()
+	m.bytesSent[cla] += uint64(size)
+	m.mutex.Unlock()
+}
+
+// countForwarded counts a forwarded bundle.
+func (m *metrics) countForwarded() {
+	m.mutex.Lock()
+	m.forwarded++
+	m.mutex.Unlock()
+}
+
+// countDelivered counts a locally delivered bundle.
+func (m *metrics) countDelivered() {
+	m.mutex.Lock()
+	m.delivered++
+	m.mutex.Unlock()
+}
+
+// countDeleted counts a deleted bundle for the given reason.
+func (m *metrics) countDeleted(reason StatusReportReason) {
+	m.mutex.Lock()
+	m.deleted[reason]++
+	m.mutex.Unlock()
+}
+
+// metricsLabelEscaper escapes a label value for the Prometheus text format.
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// header writes the HELP and TYPE lines of a metric.
+func (mw metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// value writes a sample of a metric, optionally with one label.
+func (mw metricsWriter) value(name, label, labelValue string, value uint64) {
+	if label == "" {
+		fmt.Fprintf(mw.w, "%s %d\n", name, value)
+	} else {
+		fmt.Fprintf(mw.w, "%s{%s=\"%s\"} %d\n",
+			name, label, metricsLabelEscaper.Replace(labelValue), value)
+	}
+}
+
+// labeled writes a metric with one sample for each label value, sorted by the
+// label values.
+func (mw metricsWriter) labeled(name, kind, help, label string, values map[string]uint64) {
+	var keys = make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mw.header(name, kind, help)
+	for _, key := range keys {
+		mw.value(name, label, key, values[key])
+	}
+}
+
+// WriteMetrics writes this Core's metrics in the Prometheus text exposition
+// format.
+//
+// The counters include the received, forwarded, delivered and deleted bundles,
+// the latter labeled by their StatusReportReason, and the bytes received and
+// sent by the CLAs. Received bytes are labeled by the receiving CLA's endpoint
+// ID, sent bytes by the sending CLA's address. The gauges include the stored
+// bundles by Constraint, the tombstones, the active CLAs and the length of the
+// queue of CLAs waiting for a restart.
+func (c *Core) WriteMetrics(w io.Writer) {
+	var mw = metricsWriter{w}
+
+	// Counters
+	c.metrics.mutex.Lock()
+
+	mw.header("dtn_bundles_received_total", "counter", "Number of received bundles.")
+	mw.value("dtn_bundles_received_total", "", "", c.metrics.received)
+
+	mw.header("dtn_bundles_forwarded_total", "counter", "Number of forwarded bundles.")
+	mw.value("dtn_bundles_forwarded_total", "", "", c.metrics.forwarded)
+
+	mw.header("dtn_bundles_delivered_total", "counter", "Number of locally delivered bundles.")
+	mw.value("dtn_bundles_delivered_total", "", "", c.metrics.delivered)
+
+	var deleted = make(map[string]uint64)
+	for reason, n := range c.metrics.deleted {
+		deleted[reason.String()] = n
+	}
+	mw.labeled("dtn_bundles_deleted_total", "counter",
+		"Number of deleted bundles by reason.", "reason", deleted)
+
+	mw.labeled("dtn_cla_received_bytes_total", "counter",
+		"Bytes of bundles received by the CLAs, by endpoint ID.", "endpoint", c.metrics.bytesReceived)
+	mw.labeled("dtn_cla_sent_bytes_total", "counter",
+		"Bytes of bundles sent by the CLAs, by address.", "cla", c.metrics.bytesSent)
+
+	c.metrics.mutex.Unlock()
+
+	// Store
+	var constraints = make(map[string]uint64)
+	for _, constraint := range []Constraint{
+		DispatchPending, ForwardPending, ReassemblyPending, Contraindicated, CustodyAccepted} {
+		constraints[constraint.String()] = 0
+	}
+	var tombstones uint64
+
+	c.store.Query(func(bp BundlePack) bool {
+		if bp.Tombstone {
+			tombstones++
+		}
+
+		for constraint := range bp.Constraints {
+			constraints[constraint.String()]++
+		}
+
+		return false
+	})
+
+	mw.labeled("dtn_store_bundles", "gauge",
+		"Number of stored bundles by constraint.", "constraint", constraints)
+
+	mw.header("dtn_store_tombstones", "gauge", "Number of stored tombstones.")
+	mw.value("dtn_store_tombstones", "", "", tombstones)
+
+	// CLAs
+	c.convergenceMutex.Lock()
+	var senders = uint64(len(c.convergenceSenders))
+	var receivers = uint64(len(c.convergenceReceivers))
+	var queue = uint64(len(c.convergenceQueue))
+	c.convergenceMutex.Unlock()
+
+	mw.header("dtn_cla_senders", "gauge", "Number of active convergence senders.")
+	mw.value("dtn_cla_senders", "", "", senders)
+
+	mw.header("dtn_cla_receivers", "gauge", "Number of active convergence receivers.")
+	mw.value("dtn_cla_receivers", "", "", receivers)
+
+	mw.header("dtn_cla_queue_length", "gauge", "Number of CLAs waiting for a restart.")
+	mw.value("dtn_cla_queue_length", "", "", queue)
+}
+
+// MetricsHandler returns a http.Handler, serving this Core's metrics in the
+// Prometheus text exposition format.
+func (c *Core) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(respWriter http.ResponseWriter, _ *http.Request) {
+		respWriter.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		c.WriteMetrics(respWriter)
+	})
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestCoreMetrics(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var agent = &patternAgent{eid: bundle.MustNewEndpointID("dtn:alpha")}
+	c.RegisterApplicationAgent(agent)
+
+	for i, dest := range []string{"dtn:alpha", "dtn:beta"} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID(dest),
+				bundle.MustNewEndpointID("dtn:gamma"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.receive(NewBundlePack(bndl))
+	}
+
+	c.bundleDeletion(QueryPending(c.store)[0], HopLimitExceeded)
+
+	var buf bytes.Buffer
+	c.WriteMetrics(&buf)
+
+	for _, line := range []string{
+		"# TYPE dtn_bundles_received_total counter",
+		"dtn_bundles_received_total 2",
+		"dtn_bundles_delivered_total 1",
+		"dtn_bundles_forwarded_total 0",
+		`dtn_bundles_deleted_total{reason="Hop limit exceeded"} 1`,
+		`dtn_store_bundles{constraint="contraindicated"} 0`,
+		"dtn_cla_senders 0",
+		"dtn_cla_queue_length 0",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Fatalf("Metrics miss %q:\n%s", line, buf.String())
+		}
+	}
+
+	if !strings.Contains(buf.String(), `dtn_cla_received_bytes_total{endpoint="dtn:none"} `) {
+		t.Fatalf("Metrics miss received bytes:\n%s", buf.String())
+	}
+}
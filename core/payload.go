@@ -0,0 +1,116 @@
+package core
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// deliveredPayloadDir is the payload spool's subdirectory for payload files
+// of delivered bundles. Those files are owned by their ApplicationAgent and
+// are not inspected by the garbage collection.
+const deliveredPayloadDir = "delivered"
+
+// claimFilePayload hard links, or copies if this fails, a delivered
+// FilePayload into the spool's directory for delivered payloads. Thus, the
+// returned FilePayload stays available after its bundle was removed from the
+// store and must be removed by the ApplicationAgent.
+func claimFilePayload(fp bundle.FilePayload) (bundle.FilePayload, error) {
+	var dir = filepath.Join(filepath.Dir(fp.Path), deliveredPayloadDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return bundle.FilePayload{}, err
+	}
+
+	dst, err := ioutil.TempFile(dir, "payload-*")
+	if err != nil {
+		return bundle.FilePayload{}, err
+	}
+
+	dst.Close()
+	os.Remove(dst.Name())
+
+	if err := os.Link(fp.Path, dst.Name()); err != nil {
+		if err := copyFilePayload(fp, dst.Name()); err != nil {
+			os.Remove(dst.Name())
+			return bundle.FilePayload{}, err
+		}
+	}
+
+	return bundle.FilePayload{Path: dst.Name(), Size: fp.Size}, nil
+}
+
+// copyFilePayload copies the FilePayload's file to the destination path.
+func copyFilePayload(fp bundle.FilePayload, dst string) error {
+	src, err := fp.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// collectPayloadFiles removes payload files within the spool, which are not
+// referenced by any stored bundle. Recently modified files are kept because
+// they might belong to a bundle which is currently received or processed.
+func (c *Core) collectPayloadFiles() {
+	files, err := bundle.PayloadFiles()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("Garbage collection failed to list payload files")
+		return
+	} else if len(files) == 0 {
+		return
+	}
+
+	var referenced = make(map[string]bool)
+	for _, bp := range c.store.Query(func(bp BundlePack) bool { return !bp.Tombstone }) {
+		if payloadBlock, err := bp.Bundle.PayloadBlock(); err == nil {
+			if fp, ok := payloadBlock.Data.(bundle.FilePayload); ok {
+				referenced[fp.Path] = true
+			}
+		}
+	}
+
+	var removals int
+	for _, file := range files {
+		if referenced[file] {
+			continue
+		}
+
+		if fi, err := os.Stat(file); err != nil || time.Since(fi.ModTime()) < garbageCollectionInterval {
+			continue
+		}
+
+		if err := os.Remove(file); err != nil {
+			log.WithFields(log.Fields{
+				"file":  file,
+				"error": err,
+			}).Warn("Garbage collection failed to remove payload file")
+		} else {
+			removals++
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"files":    len(files),
+		"removals": removals,
+	}).Debug("Garbage collection inspected payload files")
+}
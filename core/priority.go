@@ -0,0 +1,74 @@
+package core
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// Priority returns the Priority of this BundlePack's bundle. Tombstones and
+// bundles without a Priority block have the bundle.DefaultPriority.
+func (bp BundlePack) Priority() bundle.Priority {
+	if bp.Bundle == nil {
+		return bundle.DefaultPriority()
+	}
+
+	return bp.Bundle.Priority()
+}
+
+// sortByPriority sorts the bundle packs by their priority, the most important
+// first. Bundle packs of the same priority are ordered by their time stamps,
+// the oldest first.
+func sortByPriority(bps []BundlePack) {
+	sort.SliceStable(bps, func(i, j int) bool {
+		pi, pj := bps[i].Priority(), bps[j].Priority()
+		if pi != pj {
+			return pi.Higher(pj)
+		}
+
+		return bps[i].Timestamp.Before(bps[j].Timestamp)
+	})
+}
+
+// sortForEviction sorts the bundle packs in the order they should be evicted
+// from a full store: the least important first. Bundle packs of the same
+// priority are ordered by their expiration, the nearest first.
+func (c *Core) sortForEviction(bps []BundlePack) {
+	sort.SliceStable(bps, func(i, j int) bool {
+		pi, pj := bps[i].Priority(), bps[j].Priority()
+		if pi != pj {
+			return pj.Higher(pi)
+		}
+
+		return c.expiration(bps[i]).Before(c.expiration(bps[j]))
+	})
+}
+
+// notifyPending requests a retry of all pending bundles without waiting for
+// it. The retry is performed by the Core's goroutine, which also performs the
+// periodic retries. Thus, the pending bundles are never dispatched twice.
+func (c *Core) notifyPending() {
+	select {
+	case c.pendingSyn <- struct{}{}:
+	default:
+		// Another retry is already requested.
+	}
+}
+
+// dispatchPending retries all pending bundles from the store and the bundles
+// to be retransmitted for the custody transfer, the most important first.
+func (c *Core) dispatchPending() {
+	var bps = append(QueryPending(c.store), c.custodyRetransmissions()...)
+	sortByPriority(bps)
+
+	for _, bp := range bps {
+		log.WithFields(log.Fields{
+			"bundle":   bp.Bundle,
+			"priority": bp.Priority(),
+		}).Info("Retrying bundle from store")
+
+		c.dispatching(bp)
+	}
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestSortByPriority(t *testing.T) {
+	var prios = []bundle.Priority{
+		bundle.NewPriority(bundle.BulkPriority, 0),
+		bundle.NewPriority(bundle.NormalPriority, 1),
+		bundle.NewPriority(bundle.ExpeditedPriority, 0),
+		bundle.NewPriority(bundle.NormalPriority, 1),
+		bundle.NewPriority(bundle.NormalPriority, 5),
+	}
+
+	var bps []BundlePack
+	for i, prio := range prios {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn://gamma/"),
+				bundle.MustNewEndpointID("dtn://alpha/"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)), 60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPriorityBlock(1, 0, prio),
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bp := NewBundlePack(bndl)
+		bp.Timestamp = time.Unix(int64(1000-i), 0)
+		bps = append(bps, bp)
+	}
+
+	sortByPriority(bps)
+
+	var expected = []bundle.Priority{prios[2], prios[4], prios[3], prios[1], prios[0]}
+	for i, bp := range bps {
+		if prio := bp.Priority(); prio != expected[i] {
+			t.Fatalf("Bundle pack %d has priority %v instead of %v", i, prio, expected[i])
+		}
+	}
+
+	// Equal priorities are ordered by their time stamps, the oldest first.
+	if !bps[2].Timestamp.Before(bps[3].Timestamp) {
+		t.Fatalf("Bundle packs of equal priority are not ordered by their time stamps")
+	}
+}
+
+func TestSortForEviction(t *testing.T) {
+	var c = &Core{}
+
+	var bps []BundlePack
+	for _, b := range []struct {
+		src      string
+		prio     bundle.Priority
+		lifetime uint
+	}{
+		{"dtn://alpha/", bundle.NewPriority(bundle.ExpeditedPriority, 0), 60},
+		{"dtn://beta/", bundle.NewPriority(bundle.BulkPriority, 0), 3600},
+		{"dtn://gamma/", bundle.NewPriority(bundle.BulkPriority, 0), 60},
+		{"dtn://delta/", bundle.DefaultPriority(), 60},
+	} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn://gamma/"),
+				bundle.MustNewEndpointID(b.src),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), b.lifetime*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPriorityBlock(1, 0, b.prio),
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bps = append(bps, NewBundlePack(bndl))
+	}
+
+	c.sortForEviction(bps)
+
+	for i, src := range []string{"dtn://gamma/", "dtn://beta/", "dtn://delta/", "dtn://alpha/"} {
+		if s := bps[i].Bundle.PrimaryBlock.SourceNode.String(); s != src {
+			t.Fatalf("Bundle pack %d is from %s instead of %s", i, s, src)
+		}
+	}
+}
+
+func TestCorePriorityOnContact(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+
+	var classes = []bundle.PriorityClass{
+		bundle.BulkPriority, bundle.NormalPriority, bundle.ExpeditedPriority}
+	for i, class := range classes {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn://gamma/"),
+				bundle.MustNewEndpointID("dtn://alpha/"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)), 60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPriorityBlock(1, 0, bundle.NewPriority(class, 0)),
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c.SendBundle(bndl)
+	}
+
+	if l := len(QueryPending(c.store)); l != len(classes) {
+		t.Fatalf("Store contains %d instead of %d pending bundles", l, len(classes))
+	}
+
+	// An appearing contact receives the pending bundles, the most important first.
+	var sender = newPeerSender("dtn://beta/")
+	c.RegisterConvergence(sender)
+
+	for i := len(classes) - 1; i >= 0; i-- {
+		select {
+		case bndl := <-sender.bundles:
+			if class := bndl.Priority().Class; class != classes[i] {
+				t.Fatalf("Received a bundle of class %v instead of %v", class, classes[i])
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("Peer did not receive the bundle of class %v", classes[i])
+		}
+	}
+}
@@ -9,21 +9,34 @@ import (
 	"github.com/geistesk/dtn7/cla"
 )
 
-// SendBundle transmits an outbounding bundle.
-func (c *Core) SendBundle(bndl bundle.Bundle) {
-	c.transmit(NewBundlePack(bndl))
+// SendBundle transmits an outbounding bundle. An error is returned if the
+// bundle was refused, e.g., because of the storage quota.
+func (c *Core) SendBundle(bndl bundle.Bundle) error {
+	return c.transmit(NewBundlePack(bndl))
 }
 
 // transmit starts the transmission of an outbounding bundle pack. Therefore
 // the source's endpoint ID must be dtn:none or a member of this node.
-func (c *Core) transmit(bp BundlePack) {
+func (c *Core) transmit(bp BundlePack) error {
 	log.WithFields(log.Fields{
 		"bundle": bp.Bundle,
 	}).Info("Transmission of bundle requested")
 
+	if c.clockless {
+		c.prepareClockless(bp)
+	}
+
 	c.idKeeper.update(bp.Bundle)
 
+	if !bp.Bundle.IsAdministrativeRecord() && !c.admitToStore(bp) {
+		c.refuseBundle(bp)
+		return newCoreError("Bundle exceeds the storage quota")
+	}
+
 	bp.AddConstraint(DispatchPending)
+	if requestsCustody(bp) && c.nodeId != bundle.DtnNone() && !bp.Bundle.IsAdministrativeRecord() {
+		bp.AddConstraint(CustodyAccepted)
+	}
 	c.store.Push(bp)
 
 	src := bp.Bundle.PrimaryBlock.SourceNode
@@ -34,10 +47,11 @@ func (c *Core) transmit(bp BundlePack) {
 		}).Info("Bundle's source is neither dtn:none nor an endpoint of this node")
 
 		c.bundleDeletion(bp, NoInformation)
-		return
+		return newCoreError("Bundle's source is not an endpoint of this node")
 	}
 
 	c.dispatching(bp)
+	return nil
 }
 
 // receive handles received/incoming bundles.
@@ -46,6 +60,8 @@ func (c *Core) receive(bp BundlePack) {
 		"bundle": bp.Bundle,
 	}).Debug("Received new bundle")
 
+	c.metrics.countReceived(bp.Receiver.String(), int(bp.Bundle.EncodedSize()))
+
 	if KnowsBundle(c.store, bp) {
 		log.WithFields(log.Fields{
 			"bundle": bp.Bundle,
@@ -53,11 +69,18 @@ func (c *Core) receive(bp BundlePack) {
 
 		// bundleDeletion is _not_ called because this would delete the already
 		// stored BundlePack.
+		c.refuseRedundantCustody(bp)
+		return
+	}
+
+	if !c.admitToStore(bp) {
+		c.refuseBundle(bp)
 		return
 	}
 
 	log.WithFields(log.Fields{
-		"bundle": bp.Bundle,
+		"bundle":        bp.Bundle,
+		"previous_node": bp.PreviousNode,
 	}).Info("Processing new received bundle")
 
 	bp.AddConstraint(DispatchPending)
@@ -113,16 +136,42 @@ func (c *Core) receive(bp BundlePack) {
 		}
 	}
 
+	// A fragment's integrity will be checked after its reassembly.
+	if !bp.Bundle.PrimaryBlock.HasFragmentation() && !c.checkIntegrity(bp) {
+		return
+	}
+
+	// Custody is accepted only for a bundle which passed the validation.
+	c.acceptCustody(&bp)
+
 	c.dispatching(bp)
 }
 
-// dispatching handles the dispatching of received bundles.
+// checkIntegrity verifies the bundle's Block Integrity Blocks against the
+// registered IntegrityContexts. A bundle failing this verification will be
+// deleted and false is returned.
+func (c *Core) checkIntegrity(bp BundlePack) bool {
+	if err := bp.Bundle.VerifyIntegrity(c.integrityContexts); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Bundle's integrity verification failed")
+
+		c.bundleDeletion(bp, FailedSecurityOperation)
+		return false
+	}
+
+	return true
+}
+
+// dispatching handles the dispatching of received bundles. A bundle addressed
+// to a group endpoint is forwarded after its local delivery.
 func (c *Core) dispatching(bp BundlePack) {
 	log.WithFields(log.Fields{
 		"bundle": bp.Bundle,
 	}).Info("Dispatching bundle")
 
-	if c.HasEndpoint(bp.Bundle.PrimaryBlock.Destination) {
+	if !bp.Delivered && c.HasEndpoint(bp.Bundle.PrimaryBlock.Destination) {
 		c.localDelivery(bp)
 	} else {
 		c.forward(bp)
@@ -139,6 +188,10 @@ func (c *Core) forward(bp BundlePack) {
 	bp.RemoveConstraint(DispatchPending)
 	c.store.Push(bp)
 
+	// The hop count, bundle age and previous node are altered on a copy. The
+	// former bundle might still be sent by a CLA, e.g., by a retransmission.
+	bp.copyBundle()
+
 	if hcBlock, err := bp.Bundle.ExtensionBlock(bundle.HopCountBlock); err == nil {
 		hc := hcBlock.Data.(bundle.HopCount)
 		hc.Increment()
@@ -160,7 +213,7 @@ func (c *Core) forward(bp BundlePack) {
 		}
 	}
 
-	if bp.Bundle.PrimaryBlock.IsLifetimeExceeded() {
+	if c.isLifetimeExceeded(bp) {
 		log.WithFields(log.Fields{
 			"bundle":        bp.Bundle,
 			"primary_block": bp.Bundle.PrimaryBlock,
@@ -179,15 +232,26 @@ func (c *Core) forward(bp BundlePack) {
 			c.bundleDeletion(bp, LifetimeExpired)
 			return
 		}
+
+		// The updated age and time stamp must be stored together, otherwise the
+		// time since the reception would be counted twice.
+		c.store.Push(bp)
 	}
 
+	c.updatePreviousNode(bp)
+
 	var nodes []cla.ConvergenceSender
 	var deleteAfterwards = true
 
 	// Try a direct delivery or consult the RoutingAlgorithm otherwise.
-	nodes = c.senderForDestination(bp.Bundle.PrimaryBlock.Destination)
+	// The RoutingSendReporter is only consulted for its own ConvergenceSenders.
+	var reporter RoutingSendReporter
+
+	nodes = acceptingSenders(c.senderForDestination(bp.Bundle.PrimaryBlock.Destination), *bp.Bundle)
 	if nodes == nil {
 		nodes, deleteAfterwards = c.routing.SenderForBundle(bp)
+		nodes = acceptingSenders(nodes, *bp.Bundle)
+		reporter, _ = c.routing.(RoutingSendReporter)
 	}
 
 	var bundleSent = false
@@ -204,11 +268,44 @@ func (c *Core) forward(bp BundlePack) {
 				"cla":    node,
 			}).Info("Sending bundle to a CLA (ConvergenceSender)")
 
-			if err := node.Send(*bp.Bundle); err != nil {
+			var bndl = *bp.Bundle
+			if reporter != nil {
+				bndl = reporter.PrepareSend(bp, node)
+			}
+
+			var sendErr error
+			fragErr := forEachFragment(bndl, node, func(fragment bundle.Bundle) error {
+				if sendErr = node.Send(fragment); sendErr != nil {
+					return sendErr
+				}
+
+				c.metrics.countSent(node.Address(), int(fragment.EncodedSize()))
+				return nil
+			})
+			if fragErr != nil && sendErr == nil {
+				log.WithFields(log.Fields{
+					"bundle": bp.Bundle,
+					"cla":    node,
+					"error":  fragErr,
+				}).Warn("Bundle exceeds CLA's maximum size and cannot be fragmented")
+
+				if reporter != nil {
+					reporter.ReportSent(bp, node, false)
+				}
+
+				wg.Done()
+				return
+			}
+
+			if reporter != nil {
+				reporter.ReportSent(bp, node, sendErr == nil)
+			}
+
+			if sendErr != nil {
 				log.WithFields(log.Fields{
 					"bundle": bp.Bundle,
 					"cla":    node,
-					"error":  err,
+					"error":  sendErr,
 				}).Warn("Sending bundle failed")
 
 				node.Close()
@@ -228,6 +325,15 @@ func (c *Core) forward(bp BundlePack) {
 
 	wg.Wait()
 
+	// A custody signal might have released the bundle while it was sent.
+	if bp.HasConstraint(CustodyAccepted) && c.custodyReleased(bp) {
+		bp.RemoveConstraint(CustodyAccepted)
+	}
+
+	if bundleSent && bp.HasConstraint(CustodyAccepted) {
+		c.startCustodyTimer(bp)
+	}
+
 	if hcBlock, err := bp.Bundle.ExtensionBlock(bundle.HopCountBlock); err == nil {
 		hc := hcBlock.Data.(bundle.HopCount)
 		hc.Decrement()
@@ -240,16 +346,25 @@ func (c *Core) forward(bp BundlePack) {
 	}
 
 	if bundleSent {
+		c.metrics.countForwarded()
+
 		if bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestForward) {
 			c.SendStatusReport(bp, ForwardedBundle, NoInformation)
 		}
 
 		if deleteAfterwards {
+			var custody = bp.HasConstraint(CustodyAccepted)
+
 			bp.PurgeConstraints()
+			if custody {
+				bp.AddConstraint(CustodyAccepted)
+			}
 			c.store.Push(bp)
 		} else if c.inspectAllBundles && bp.Bundle.IsAdministrativeRecord() {
 			c.bundleContraindicated(bp)
 			c.checkAdministrativeRecord(bp)
+		} else if retryer, ok := c.routing.(RoutingRetryer); ok && retryer.RetryBundle(bp) {
+			c.bundleContraindicated(bp)
 		}
 	} else {
 		log.WithFields(log.Fields{
@@ -259,6 +374,22 @@ func (c *Core) forward(bp BundlePack) {
 	}
 }
 
+// updatePreviousNode replaces the bundle's Previous Node block by one
+// containing this node's ID before forwarding. Without a node ID, an existing
+// Previous Node block is removed because it would name another node.
+func (c *Core) updatePreviousNode(bp BundlePack) {
+	if c.nodeId == bundle.DtnNone() {
+		bp.Bundle.RemoveExtensionBlock(bundle.PreviousNodeBlock)
+		return
+	}
+
+	if cb, err := bp.Bundle.ExtensionBlock(bundle.PreviousNodeBlock); err == nil {
+		cb.Data = c.nodeId
+	} else {
+		bp.Bundle.AddExtensionBlock(bundle.NewPreviousNodeBlock(0, 0, c.nodeId))
+	}
+}
+
 // checkAdministrativeRecord checks administrative records. If this method
 // returns false, an error occured.
 func (c *Core) checkAdministrativeRecord(bp BundlePack) bool {
@@ -269,7 +400,7 @@ func (c *Core) checkAdministrativeRecord(bp BundlePack) bool {
 		return false
 	}
 
-	canonicalAr, err := bp.Bundle.PayloadBlock()
+	payload, err := bp.Bundle.PayloadData()
 	if err != nil {
 		log.WithFields(log.Fields{
 			"bundle": bp.Bundle,
@@ -279,7 +410,7 @@ func (c *Core) checkAdministrativeRecord(bp BundlePack) bool {
 		return false
 	}
 
-	ar, err := NewAdministrativeRecordFromCbor(canonicalAr.Data.([]byte))
+	ar, err := NewAdministrativeRecordFromCbor(payload)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"bundle": bp.Bundle,
@@ -294,15 +425,21 @@ func (c *Core) checkAdministrativeRecord(bp BundlePack) bool {
 		"admin_rec": ar,
 	}).Info("Received bundle contains an administrative record")
 
-	// Currently there are only status reports. This must be changed if more
-	// types of administrative records are introduced.
-	c.inspectStatusReport(bp, ar)
+	switch content := ar.Content.(type) {
+	case StatusReport:
+		c.inspectStatusReport(bp, ar, content)
+
+	case CustodySignal:
+		c.inspectCustodySignal(bp, content)
+
+	case BIBEProtocolDataUnit:
+		c.decapsulateBIBE(bp, content)
+	}
 
 	return true
 }
 
-func (c *Core) inspectStatusReport(bp BundlePack, ar AdministrativeRecord) {
-	var status = ar.Content
+func (c *Core) inspectStatusReport(bp BundlePack, ar AdministrativeRecord, status StatusReport) {
 	var sips = status.StatusInformations()
 
 	if len(sips) == 0 {
@@ -364,10 +501,32 @@ func (c *Core) inspectStatusReport(bp BundlePack, ar AdministrativeRecord) {
 }
 
 func (c *Core) localDelivery(bp BundlePack) {
-	// TODO: check fragmentation
+	if bp.Bundle.PrimaryBlock.HasFragmentation() {
+		var complete bool
+		if bp, complete = c.reassemble(bp); !complete {
+			return
+		}
+
+		if !c.checkIntegrity(bp) {
+			return
+		}
+	}
+
+	// A bundle addressed to a group endpoint will be forwarded afterwards. Thus,
+	// only a copy of it might be decrypted for the local delivery.
+	var group = !bp.Bundle.PrimaryBlock.Destination.IsSingleton()
+	var forwardPack = bp
+	if group {
+		bp.copyBundle()
+	}
+
+	if bp.Bundle.IsEncrypted() && !c.decryptConfidentiality(bp) {
+		return
+	}
 
 	log.WithFields(log.Fields{
 		"bundle": bp.Bundle,
+		"group":  group,
 	}).Info("Received bundle for local delivery")
 
 	if bp.Bundle.IsAdministrativeRecord() {
@@ -377,22 +536,84 @@ func (c *Core) localDelivery(bp BundlePack) {
 		}
 	}
 
+	var delivered = false
 	for _, agent := range c.Agents {
-		if agent.EndpointID() == bp.Bundle.PrimaryBlock.Destination {
-			agent.Deliver(bp.Bundle)
+		if !agentHasEndpoint(agent, bp.Bundle.PrimaryBlock.Destination) {
+			continue
+		}
+
+		if err := agent.Deliver(bp.Bundle); err != nil {
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+				"agent":  agent.EndpointID(),
+				"error":  err,
+			}).Warn("ApplicationAgent failed to deliver bundle")
+		} else {
+			delivered = true
 		}
 	}
 
+	// A bundle for a local endpoint without an ApplicationAgent, or whose
+	// delivery failed, is kept until it is dispatched again. A group bundle is
+	// forwarded anyway and delivered, if it is dispatched again.
+	if !delivered && !bp.Bundle.IsAdministrativeRecord() {
+		log.WithFields(log.Fields{
+			"bundle":      bp.Bundle,
+			"destination": bp.Bundle.PrimaryBlock.Destination,
+		}).Warn("Bundle was not delivered to any ApplicationAgent, keeping it pending")
+
+		if group {
+			c.forward(forwardPack)
+		} else {
+			c.bundleContraindicated(bp)
+		}
+		return
+	}
+
 	c.routing.NotifyIncoming(bp)
 
-	if bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDelivery) {
-		c.SendStatusReport(bp, DeliveredBundle, NoInformation)
+	if delivered {
+		c.metrics.countDelivered()
+
+		if bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDelivery) {
+			c.SendStatusReport(bp, DeliveredBundle, NoInformation)
+		}
+	}
+
+	if group {
+		forwardPack.Delivered = true
+		c.forward(forwardPack)
+		return
 	}
 
 	bp.PurgeConstraints()
 	c.store.Push(bp)
 }
 
+// decryptConfidentiality decrypts the bundle's Block Confidentiality Blocks
+// with the registered ConfidentialityContexts. A bundle failing this
+// decryption will be deleted and false is returned. A bundle without a
+// matching ConfidentialityContext stays encrypted.
+func (c *Core) decryptConfidentiality(bp BundlePack) bool {
+	if err := bp.Bundle.DecryptConfidentiality(c.confidentialityContexts); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Bundle's decryption failed")
+
+		c.bundleDeletion(bp, FailedSecurityOperation)
+		return false
+	}
+
+	if bp.Bundle.IsEncrypted() {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Warn("Bundle remains encrypted, no matching security context is available")
+	}
+
+	return true
+}
+
 func (c *Core) bundleContraindicated(bp BundlePack) {
 	log.WithFields(log.Fields{
 		"bundle": bp.Bundle,
@@ -403,6 +624,8 @@ func (c *Core) bundleContraindicated(bp BundlePack) {
 }
 
 func (c *Core) bundleDeletion(bp BundlePack, reason StatusReportReason) {
+	c.metrics.countDeleted(reason)
+
 	if bp.Bundle.PrimaryBlock.BundleControlFlags.Has(bundle.StatusRequestDeletion) {
 		c.SendStatusReport(bp, DeletedBundle, reason)
 	}
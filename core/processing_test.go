@@ -0,0 +1,85 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// failingAgent is an ApplicationAgent whose delivery always fails.
+type failingAgent struct {
+	eid bundle.EndpointID
+}
+
+func (fa *failingAgent) EndpointID() bundle.EndpointID {
+	return fa.eid
+}
+
+func (fa *failingAgent) Deliver(bndl *bundle.Bundle) error {
+	return newCoreError("Delivery failed")
+}
+
+func TestLocalDeliveryWithoutAgent(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn://alpha/app"),
+			bundle.MustNewEndpointID("dtn://beta/"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewBundlePack(bndl))
+
+	// Without an ApplicationAgent, the bundle must be kept.
+	if bps := QueryPending(c.store); len(bps) != 1 {
+		t.Fatalf("Store contains %d instead of 1 pending bundles", len(bps))
+	}
+
+	// A failed delivery must also keep the bundle.
+	c.Agents = append(c.Agents, &failingAgent{eid: bundle.MustNewEndpointID("dtn://alpha/app")})
+	c.dispatching(QueryPending(c.store)[0])
+
+	if bps := QueryPending(c.store); len(bps) != 1 {
+		t.Fatalf("Store contains %d instead of 1 pending bundles after a failed delivery", len(bps))
+	}
+
+	var agent = &patternAgent{eid: bundle.MustNewEndpointID("dtn://alpha/app")}
+	c.RegisterApplicationAgent(agent)
+
+	for deadline := time.Now().Add(time.Second); len(QueryPending(c.store)) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Pending bundle was not delivered to the registered agent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if l := len(agent.delivered); l != 1 {
+		t.Fatalf("Agent received %d instead of 1 bundles", l)
+	}
+}
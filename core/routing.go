@@ -1,6 +1,9 @@
 package core
 
-import "github.com/geistesk/dtn7/cla"
+import (
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
 
 // RoutingAlgorithm is an interface to specify routing algorithms for
 // delay-tolerant networks. An implementation might store a reference to a Core
@@ -16,4 +19,35 @@ type RoutingAlgorithm interface {
 	// be deleted afterwards.
 	// The CLA selection is based on the algorithm's design.
 	SenderForBundle(bp BundlePack) (sender []cla.ConvergenceSender, delete bool)
+
+	// ReportPeerAppeared notifies this RoutingAlgorithm about a new registered
+	// Convergence, e.g., a connected peer.
+	ReportPeerAppeared(peer cla.Convergence)
+
+	// ReportPeerDisappeared notifies this RoutingAlgorithm about a removed
+	// Convergence.
+	ReportPeerDisappeared(peer cla.Convergence)
+}
+
+// RoutingSendReporter is an optional interface for a RoutingAlgorithm whose
+// state depends on the outcome of sending a bundle to one of the
+// ConvergenceSenders returned by its SenderForBundle method. For each of those,
+// the Core calls PrepareSend before and ReportSent after sending.
+type RoutingSendReporter interface {
+	// PrepareSend returns the bundle to be sent to the ConvergenceSender. This
+	// might be a modified copy of the BundlePack's bundle.
+	PrepareSend(bp BundlePack, sender cla.ConvergenceSender) bundle.Bundle
+
+	// ReportSent notifies this RoutingAlgorithm if sending the bundle to the
+	// ConvergenceSender succeeded.
+	ReportSent(bp BundlePack, sender cla.ConvergenceSender, success bool)
+}
+
+// RoutingRetryer is an optional interface for a RoutingAlgorithm, which might
+// forward a bundle again after it was sent to some of its ConvergenceSenders,
+// e.g., to peers appearing later.
+type RoutingRetryer interface {
+	// RetryBundle returns true if the bundle should be kept pending and be
+	// dispatched again by the Core's next retry of pending bundles.
+	RetryBundle(bp BundlePack) bool
 }
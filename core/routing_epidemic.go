@@ -23,12 +23,19 @@ func NewEpidemicRouting(c *Core, sendBack bool) EpidemicRouting {
 // EpidemicRouting simply does not listen.
 func (er EpidemicRouting) NotifyIncoming(_ BundlePack) {}
 
+// ReportPeerAppeared is ignored by the EpidemicRouting, which always uses all
+// of the Core's ConvergenceSenders.
+func (er EpidemicRouting) ReportPeerAppeared(_ cla.Convergence) {}
+
+// ReportPeerDisappeared is ignored by the EpidemicRouting.
+func (er EpidemicRouting) ReportPeerDisappeared(_ cla.Convergence) {}
+
 // SenderForBundle returns the Core's ConvergenceSenders. The ConvergenceSender
-// for this BundlePack's receiver will be removed sendBack is false.
+// for this BundlePack's previous node will be removed if sendBack is false.
 func (er EpidemicRouting) SenderForBundle(bp BundlePack) ([]cla.ConvergenceSender, bool) {
 	var css []cla.ConvergenceSender
-	for _, cs := range er.c.convergenceSenders {
-		if !er.sendBack && cs.GetPeerEndpointID() != bp.Receiver {
+	for _, cs := range er.c.senders() {
+		if er.sendBack || !bp.IsPreviousNode(cs.GetPeerEndpointID()) {
 			css = append(css, cs)
 		}
 	}
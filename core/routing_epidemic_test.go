@@ -0,0 +1,72 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+func TestEpidemicRoutingPreviousNode(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn:a"))
+
+	var senderPrev = newPeerSender("dtn:prev")
+	var senderNext = newPeerSender("dtn:next")
+	c.convergenceSenders = append(c.convergenceSenders, senderPrev, senderNext)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.MustNewEndpointID("dtn:src"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPreviousNodeBlock(1, 0, senderPrev.peer),
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.receive(NewRecBundlePack(cla.NewRecBundle(bndl, bundle.MustNewEndpointID("dtn:a"))))
+
+	select {
+	case b := <-senderNext.bundles:
+		cb, err := b.ExtensionBlock(bundle.PreviousNodeBlock)
+		if err != nil {
+			t.Fatalf("Forwarded bundle has no Previous Node block: %v", err)
+		}
+
+		if prevNode := cb.Data.(bundle.EndpointID); prevNode != c.NodeId() {
+			t.Fatalf("Forwarded bundle's previous node is %v instead of %v", prevNode, c.NodeId())
+		}
+
+	case <-time.After(time.Second):
+		t.Fatalf("Bundle was not forwarded to the next node")
+	}
+
+	select {
+	case b := <-senderPrev.bundles:
+		t.Fatalf("Bundle was sent back to its previous node: %v", b)
+
+	default:
+	}
+}
@@ -0,0 +1,297 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+	"github.com/ugorji/go/codec"
+)
+
+// ProphetEndpoint is the endpoint ID of ProphetRouting's ApplicationAgent,
+// receiving the delivery predictabilities of peers.
+var ProphetEndpoint = bundle.MustNewEndpointID("dtn:prophet")
+
+// prophetLifetime is the lifetime of bundles, exchanging the delivery
+// predictabilities, in microseconds.
+const prophetLifetime = 60 * 1000000
+
+// ProphetConfig contains the parameters of ProphetRouting, as described in
+// section 2.1.1 of RFC 6693.
+type ProphetConfig struct {
+	// PInit is the initial delivery predictability for an encountered node.
+	PInit float64
+
+	// Beta is the scaling factor of the transitivity's impact.
+	Beta float64
+
+	// Gamma is the aging constant, applied for each elapsed AgeInterval.
+	Gamma float64
+
+	// AgeInterval is the time unit for aging the delivery predictabilities.
+	AgeInterval time.Duration
+}
+
+// DefaultProphetConfig returns a ProphetConfig with the default values,
+// recommended in section 3.3 of RFC 6693.
+func DefaultProphetConfig() ProphetConfig {
+	return ProphetConfig{
+		PInit:       0.75,
+		Beta:        0.25,
+		Gamma:       0.98,
+		AgeInterval: 30 * time.Second,
+	}
+}
+
+// ProphetRouting is an implementation of a RoutingAlgorithm, based on the
+// Probabilistic Routing Protocol using History of Encounters and Transitivity
+// (PRoPHET) as specified in RFC 6693.
+//
+// Each node maintains delivery predictabilities for the other nodes. When a
+// peer appears, the own delivery predictabilities are sent to this peer. A
+// bundle is only forwarded to peers with a higher delivery predictability for
+// its destination than this node's one.
+type ProphetRouting struct {
+	c      *Core
+	nodeId bundle.EndpointID
+	config ProphetConfig
+
+	// predictabilities are this node's delivery predictabilities, identified
+	// by the endpoint IDs' string representation.
+	predictabilities map[string]float64
+	// peerPredictabilities are the last received delivery predictabilities of
+	// each peer.
+	peerPredictabilities map[string]map[string]float64
+	lastAging            time.Time
+
+	mutex sync.Mutex
+}
+
+// NewProphetRouting creates a new ProphetRouting RoutingAlgorithm interacting
+// with the given Core. The node ID identifies this node towards its peers and
+// must not be dtn:none. The ProphetRouting registers itself as an
+// ApplicationAgent for the ProphetEndpoint.
+func NewProphetRouting(c *Core, nodeId bundle.EndpointID, config ProphetConfig) (*ProphetRouting, error) {
+	if nodeId == bundle.DtnNone() {
+		return nil, newCoreError("ProphetRouting requires a node ID")
+	}
+
+	var pr = &ProphetRouting{
+		c:                    c,
+		nodeId:               nodeId,
+		config:               config,
+		predictabilities:     make(map[string]float64),
+		peerPredictabilities: make(map[string]map[string]float64),
+		lastAging:            time.Now(),
+	}
+
+	c.RegisterApplicationAgent(pr)
+
+	return pr, nil
+}
+
+// age applies the aging of the delivery predictabilities for each elapsed
+// AgeInterval since the last aging. The mutex must be locked.
+func (pr *ProphetRouting) age() {
+	if pr.config.AgeInterval <= 0 {
+		return
+	}
+
+	var k = int(time.Since(pr.lastAging) / pr.config.AgeInterval)
+	if k == 0 {
+		return
+	}
+
+	var factor = math.Pow(pr.config.Gamma, float64(k))
+	for eid, p := range pr.predictabilities {
+		pr.predictabilities[eid] = p * factor
+	}
+
+	pr.lastAging = pr.lastAging.Add(time.Duration(k) * pr.config.AgeInterval)
+}
+
+// encounter updates the delivery predictabilities for a directly encountered
+// peer and applies the transitivity based on its delivery predictabilities.
+func (pr *ProphetRouting) encounter(peer string, peerPredictabilities map[string]float64) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	pr.age()
+
+	var pOld = pr.predictabilities[peer]
+	var pPeer = pOld + (1-pOld)*pr.config.PInit
+	pr.predictabilities[peer] = pPeer
+
+	for eid, p := range peerPredictabilities {
+		if eid == pr.nodeId.String() || eid == peer {
+			continue
+		}
+
+		pr.predictabilities[eid] = math.Max(pr.predictabilities[eid], pPeer*p*pr.config.Beta)
+	}
+
+	pr.peerPredictabilities[peer] = peerPredictabilities
+}
+
+// Predictability returns this node's current delivery predictability for the
+// given endpoint ID.
+func (pr *ProphetRouting) Predictability(eid bundle.EndpointID) float64 {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	pr.age()
+
+	return pr.predictabilities[eid.String()]
+}
+
+// predictabilitiesBundle creates a bundle, addressed to the ProphetEndpoint
+// and containing this node's delivery predictabilities. Its hop limit prevents
+// further forwarding by a peer without a ProphetRouting.
+func (pr *ProphetRouting) predictabilitiesBundle() (bndl bundle.Bundle, err error) {
+	pr.mutex.Lock()
+	pr.age()
+
+	var payload []byte
+	err = codec.NewEncoderBytes(&payload, new(codec.CborHandle)).Encode(pr.predictabilities)
+	pr.mutex.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	var hopCount = bundle.NewHopCount(1)
+	hopCount.Increment()
+
+	bndl, err = bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			ProphetEndpoint,
+			pr.nodeId,
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			prophetLifetime),
+		[]bundle.CanonicalBlock{
+			bundle.NewHopCountBlock(1, 0, hopCount),
+			bundle.NewPayloadBlock(0, payload),
+		})
+	if err != nil {
+		return
+	}
+
+	pr.c.idKeeper.update(&bndl)
+	return
+}
+
+// NotifyIncoming tells the ProphetRouting new bundles. However, the delivery
+// predictabilities are received through the ProphetEndpoint.
+func (pr *ProphetRouting) NotifyIncoming(_ BundlePack) {}
+
+// ReportPeerAppeared sends this node's delivery predictabilities to an
+// appeared ConvergenceSender's peer.
+func (pr *ProphetRouting) ReportPeerAppeared(peer cla.Convergence) {
+	sender, ok := peer.(cla.ConvergenceSender)
+	if !ok || sender.GetPeerEndpointID() == bundle.DtnNone() {
+		return
+	}
+
+	bndl, err := pr.predictabilitiesBundle()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"cla":   sender,
+			"error": err,
+		}).Warn("ProphetRouting failed to create its predictabilities bundle")
+		return
+	}
+
+	go func() {
+		if err := sender.Send(bndl); err != nil {
+			log.WithFields(log.Fields{
+				"cla":   sender,
+				"error": err,
+			}).Warn("ProphetRouting failed to send its predictabilities")
+		}
+	}()
+}
+
+// ReportPeerDisappeared drops the delivery predictabilities of a disappeared
+// ConvergenceSender's peer. This node's delivery predictabilities are kept.
+func (pr *ProphetRouting) ReportPeerDisappeared(peer cla.Convergence) {
+	sender, ok := peer.(cla.ConvergenceSender)
+	if !ok {
+		return
+	}
+
+	pr.mutex.Lock()
+	delete(pr.peerPredictabilities, sender.GetPeerEndpointID().String())
+	pr.mutex.Unlock()
+}
+
+// SenderForBundle returns the ConvergenceSenders whose peers have a higher
+// delivery predictability for the bundle's destination than this node. The
+// ConvergenceSender for this BundlePack's previous node will not be returned.
+func (pr *ProphetRouting) SenderForBundle(bp BundlePack) ([]cla.ConvergenceSender, bool) {
+	var destination = bp.Bundle.PrimaryBlock.Destination.String()
+	var senders = pr.c.senders()
+
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+
+	pr.age()
+
+	var css []cla.ConvergenceSender
+	for _, cs := range senders {
+		var peer = cs.GetPeerEndpointID()
+		if bp.IsPreviousNode(peer) {
+			continue
+		}
+
+		if peer.String() == destination ||
+			pr.peerPredictabilities[peer.String()][destination] > pr.predictabilities[destination] {
+			css = append(css, cs)
+		}
+	}
+
+	return css, false
+}
+
+// EndpointID returns the ProphetEndpoint.
+func (pr *ProphetRouting) EndpointID() bundle.EndpointID {
+	return ProphetEndpoint
+}
+
+// Deliver receives a peer's delivery predictabilities, which updates this
+// node's delivery predictabilities.
+func (pr *ProphetRouting) Deliver(bndl *bundle.Bundle) error {
+	var peer = bndl.PrimaryBlock.SourceNode
+	if peer == bundle.DtnNone() || peer == pr.nodeId {
+		return newCoreError("ProphetRouting received predictabilities of an invalid peer")
+	}
+
+	payloadBlock, err := bndl.PayloadBlock()
+	if err != nil {
+		return err
+	}
+
+	payload, ok := payloadBlock.Data.([]byte)
+	if !ok {
+		return newCoreError("ProphetRouting received a malformed payload block")
+	}
+
+	var peerPredictabilities = make(map[string]float64)
+	if err := codec.NewDecoderBytes(payload, new(codec.CborHandle)).Decode(&peerPredictabilities); err != nil {
+		return newCoreError(fmt.Sprintf("ProphetRouting failed to decode predictabilities: %v", err))
+	}
+
+	pr.encounter(peer.String(), peerPredictabilities)
+
+	log.WithFields(log.Fields{
+		"peer":             peer,
+		"predictabilities": len(peerPredictabilities),
+	}).Debug("ProphetRouting received peer's predictabilities")
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// peerSender is a dummy ConvergenceSender for a peer, which passes sent
+// bundles into a channel.
+type peerSender struct {
+	peer    bundle.EndpointID
+	bundles chan bundle.Bundle
+}
+
+func newPeerSender(peer string) peerSender {
+	return peerSender{
+		peer:    bundle.MustNewEndpointID(peer),
+		bundles: make(chan bundle.Bundle, 10),
+	}
+}
+
+func (ps peerSender) Start() (error, bool)                 { return nil, false }
+func (ps peerSender) Close()                               {}
+func (ps peerSender) Address() string                      { return "dummy://" + ps.peer.String() }
+func (ps peerSender) IsPermanent() bool                    { return false }
+func (ps peerSender) Send(b bundle.Bundle) error           { ps.bundles <- b; return nil }
+func (ps peerSender) GetPeerEndpointID() bundle.EndpointID { return ps.peer }
+
+// createProphetRouting creates a Core with a ProphetRouting. The returned
+// function closes the Core and removes its store.
+func createProphetRouting(t *testing.T, nodeId string) (*Core, *ProphetRouting, func()) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, err := NewProphetRouting(c, bundle.MustNewEndpointID(nodeId), DefaultProphetConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetRoutingAlgorithm(pr)
+
+	return c, pr, func() {
+		c.Close()
+		os.Remove(file.Name())
+	}
+}
+
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// exchangePredictabilities sends the predictabilities of from to to.
+func exchangePredictabilities(t *testing.T, from *ProphetRouting, to *ProphetRouting) {
+	var sender = newPeerSender(to.nodeId.String())
+	from.ReportPeerAppeared(sender)
+
+	select {
+	case bndl := <-sender.bundles:
+		if bndl.PrimaryBlock.Destination != ProphetEndpoint {
+			t.Fatalf("Predictabilities bundle is addressed to %v", bndl.PrimaryBlock.Destination)
+		}
+
+		if err := to.Deliver(&bndl); err != nil {
+			t.Fatal(err)
+		}
+
+	case <-time.After(time.Second):
+		t.Fatal("No predictabilities bundle was sent")
+	}
+}
+
+func TestProphetRoutingEncounter(t *testing.T) {
+	var pInit = DefaultProphetConfig().PInit
+	var beta = DefaultProphetConfig().Beta
+
+	_, prA, closeFnA := createProphetRouting(t, "dtn:a")
+	defer closeFnA()
+	_, prB, closeFnB := createProphetRouting(t, "dtn:b")
+	defer closeFnB()
+	_, prC, closeFnC := createProphetRouting(t, "dtn:c")
+	defer closeFnC()
+
+	// B encounters C
+	exchangePredictabilities(t, prC, prB)
+	if p := prB.Predictability(prC.nodeId); !floatEqual(p, pInit) {
+		t.Fatalf("P(b,c) = %f, expected %f", p, pInit)
+	}
+
+	// A encounters B, learning about C
+	exchangePredictabilities(t, prB, prA)
+	if p := prA.Predictability(prB.nodeId); !floatEqual(p, pInit) {
+		t.Fatalf("P(a,b) = %f, expected %f", p, pInit)
+	}
+	if p := prA.Predictability(prC.nodeId); !floatEqual(p, pInit*pInit*beta) {
+		t.Fatalf("P(a,c) = %f, expected %f", p, pInit*pInit*beta)
+	}
+
+	// A encounters B again
+	exchangePredictabilities(t, prB, prA)
+	var pAB = pInit + (1-pInit)*pInit
+	if p := prA.Predictability(prB.nodeId); !floatEqual(p, pAB) {
+		t.Fatalf("P(a,b) = %f, expected %f", p, pAB)
+	}
+}
+
+func TestProphetRoutingAging(t *testing.T) {
+	_, pr, closeFn := createProphetRouting(t, "dtn:a")
+	defer closeFn()
+
+	var config = DefaultProphetConfig()
+	var peer = bundle.MustNewEndpointID("dtn:b")
+
+	pr.encounter(peer.String(), nil)
+	pr.lastAging = pr.lastAging.Add(-3 * config.AgeInterval)
+
+	var expected = config.PInit * math.Pow(config.Gamma, 3)
+	if p := pr.Predictability(peer); !floatEqual(p, expected) {
+		t.Fatalf("Aged predictability is %f, expected %f", p, expected)
+	}
+}
+
+func TestProphetRoutingSenderForBundle(t *testing.T) {
+	c, pr, closeFn := createProphetRouting(t, "dtn:a")
+	defer closeFn()
+
+	var senderB = newPeerSender("dtn:b")
+	var senderC = newPeerSender("dtn:c")
+	c.convergenceSenders = append(c.convergenceSenders, senderB, senderC)
+
+	// A knows D through C, but B knows D better.
+	pr.encounter("dtn:c", map[string]float64{"dtn:d": 0.8})
+	pr.encounter("dtn:b", map[string]float64{"dtn:d": 0.9, "dtn:e": 0.1})
+
+	var createBp = func(dst string) BundlePack {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(0,
+				bundle.MustNewEndpointID(dst), bundle.MustNewEndpointID("dtn:src"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+			[]bundle.CanonicalBlock{bundle.NewPayloadBlock(0, []byte("hello world"))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return NewBundlePack(bndl)
+	}
+
+	var tests = []struct {
+		destination string
+		peers       []string
+	}{
+		{"dtn:d", []string{"dtn:b", "dtn:c"}},
+		{"dtn:e", []string{"dtn:b"}},
+		{"dtn:c", []string{"dtn:c"}},
+		{"dtn:f", []string{}},
+	}
+
+	for _, test := range tests {
+		css, _ := pr.SenderForBundle(createBp(test.destination))
+
+		if len(css) != len(test.peers) {
+			t.Fatalf("Destination %s resulted in %d senders, expected %v",
+				test.destination, len(css), test.peers)
+		}
+
+		for i, cs := range css {
+			if cs.GetPeerEndpointID().String() != test.peers[i] {
+				t.Fatalf("Destination %s resulted in sender %v, expected %v",
+					test.destination, cs.GetPeerEndpointID(), test.peers)
+			}
+		}
+	}
+}
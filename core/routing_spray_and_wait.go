@@ -0,0 +1,223 @@
+package core
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// sprayState is the SprayAndWaitRouting's state of a bundle, containing the
+// remaining copies, the peers which already received a copy and the copies
+// reserved for peers while sending to them.
+type sprayState struct {
+	copies    uint
+	peers     map[string]bool
+	handovers map[string]uint
+}
+
+// SprayAndWaitRouting is an implementation of a RoutingAlgorithm, behaving like
+// Binary Spray and Wait. Each bundle carries its number of copies within a
+// Spray and Wait block. A node holding more than one copy hands over half of
+// its copies to each encountered peer. A node holding a single copy waits for
+// a direct delivery to the bundle's destination.
+//
+// The remaining copies are kept in memory. After a restart, the copies of a
+// stored bundle fall back to its Spray and Wait block's value.
+type SprayAndWaitRouting struct {
+	c             *Core
+	initialCopies uint
+
+	bundles map[string]*sprayState
+	mutex   sync.Mutex
+}
+
+// NewSprayAndWaitRouting creates a new SprayAndWaitRouting RoutingAlgorithm
+// interacting with the given Core. The initial copies are assigned to bundles
+// originating at this node.
+func NewSprayAndWaitRouting(c *Core, initialCopies uint) *SprayAndWaitRouting {
+	return &SprayAndWaitRouting{
+		c:             c,
+		initialCopies: initialCopies,
+		bundles:       make(map[string]*sprayState),
+	}
+}
+
+// copiesOf returns the number of copies of a yet unknown bundle. This is
+// either its Spray and Wait block's value, the initial copies for bundles from
+// this node or a single copy otherwise.
+func (sw *SprayAndWaitRouting) copiesOf(bp BundlePack) uint {
+	if cb, err := bp.Bundle.ExtensionBlock(bundle.SprayAndWaitBlock); err == nil {
+		if copies, ok := cb.Data.(uint); ok && copies > 0 {
+			return copies
+		}
+	}
+
+	var src = bp.Bundle.PrimaryBlock.SourceNode
+	if sw.c.HasEndpoint(src) || (src == bundle.DtnNone() && !bp.HasReceiver()) {
+		return sw.initialCopies
+	}
+
+	return 1
+}
+
+// setCopies sets the bundle's Spray and Wait block to the given copies. The
+// block will be added, if it does not exist.
+func setCopies(bndl *bundle.Bundle, copies uint) {
+	if cb, err := bndl.ExtensionBlock(bundle.SprayAndWaitBlock); err == nil {
+		cb.Data = copies
+	} else {
+		bndl.AddExtensionBlock(bundle.NewSprayAndWaitBlock(0, 0, copies))
+	}
+}
+
+// peerKey identifies a ConvergenceSender's peer by its endpoint ID or by its
+// address, if the endpoint ID is unknown.
+func peerKey(cs cla.ConvergenceSender) string {
+	if eid := cs.GetPeerEndpointID(); eid != bundle.DtnNone() {
+		return eid.String()
+	}
+
+	return cs.Address()
+}
+
+// NotifyIncoming tells the SprayAndWaitRouting new bundles. However, a
+// bundle's copies are inspected while searching for ConvergenceSenders.
+func (sw *SprayAndWaitRouting) NotifyIncoming(_ BundlePack) {}
+
+// ReportPeerAppeared forgets the finished bundles and requests a retry of the
+// pending bundles. Thus, an appeared peer might receive copies of them, handed
+// over by the Core's goroutine.
+func (sw *SprayAndWaitRouting) ReportPeerAppeared(_ cla.Convergence) {
+	go func() {
+		var known = make(map[string]bool)
+		sw.c.store.Query(func(bp BundlePack) bool {
+			if bp.HasConstraints() {
+				known[bp.Bundle.ID()] = true
+			}
+			return false
+		})
+
+		sw.mutex.Lock()
+		for id := range sw.bundles {
+			if !known[id] {
+				delete(sw.bundles, id)
+			}
+		}
+		sw.mutex.Unlock()
+	}()
+
+	sw.c.notifyPending()
+}
+
+// ReportPeerDisappeared is ignored by the SprayAndWaitRouting.
+func (sw *SprayAndWaitRouting) ReportPeerDisappeared(_ cla.Convergence) {}
+
+// SenderForBundle returns the ConvergenceSenders, whose peers have not yet
+// received a copy, while this node holds more than one copy. Each peer gets
+// half of the remaining copies reserved, which are handed over by the Spray
+// and Wait block of the bundle returned by PrepareSend. With a single copy, no
+// ConvergenceSender is returned and the bundle waits for its direct delivery.
+func (sw *SprayAndWaitRouting) SenderForBundle(bp BundlePack) ([]cla.ConvergenceSender, bool) {
+	var id = bp.Bundle.ID()
+	var senders = acceptingSenders(sw.c.senders(), *bp.Bundle)
+
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	state, ok := sw.bundles[id]
+	if !ok {
+		state = &sprayState{
+			copies:    sw.copiesOf(bp),
+			peers:     make(map[string]bool),
+			handovers: make(map[string]uint),
+		}
+		sw.bundles[id] = state
+	}
+
+	var css []cla.ConvergenceSender
+	for _, cs := range senders {
+		if state.copies <= 1 {
+			break
+		}
+
+		var key = peerKey(cs)
+		if _, sending := state.handovers[key]; sending || state.peers[key] {
+			continue
+		} else if bp.IsPreviousNode(cs.GetPeerEndpointID()) {
+			continue
+		}
+
+		var handover = state.copies / 2
+		state.copies -= handover
+		state.handovers[key] = handover
+
+		log.WithFields(log.Fields{
+			"bundle":    bp.Bundle,
+			"peer":      key,
+			"handover":  handover,
+			"remaining": state.copies,
+		}).Debug("SprayAndWaitRouting hands over copies")
+
+		css = append(css, cs)
+	}
+
+	return css, false
+}
+
+// RetryBundle keeps each bundle pending after sending, either for peers
+// appearing later or for its direct delivery.
+func (sw *SprayAndWaitRouting) RetryBundle(_ BundlePack) bool {
+	return true
+}
+
+// PrepareSend returns a copy of the bundle whose Spray and Wait block contains
+// the copies reserved for the ConvergenceSender's peer.
+func (sw *SprayAndWaitRouting) PrepareSend(bp BundlePack, sender cla.ConvergenceSender) bundle.Bundle {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	if state, ok := sw.bundles[bp.Bundle.ID()]; ok {
+		if handover, ok := state.handovers[peerKey(sender)]; ok {
+			bp.copyBundle()
+			setCopies(bp.Bundle, handover)
+		}
+	}
+
+	return *bp.Bundle
+}
+
+// ReportSent completes the handover of the copies reserved for the
+// ConvergenceSender's peer. If sending failed, those copies remain at this
+// node and the peer might receive them later.
+func (sw *SprayAndWaitRouting) ReportSent(bp BundlePack, sender cla.ConvergenceSender, success bool) {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	state, ok := sw.bundles[bp.Bundle.ID()]
+	if !ok {
+		return
+	}
+
+	var key = peerKey(sender)
+	handover, ok := state.handovers[key]
+	if !ok {
+		return
+	}
+
+	delete(state.handovers, key)
+	if success {
+		state.peers[key] = true
+	} else {
+		state.copies += handover
+
+		log.WithFields(log.Fields{
+			"bundle":    bp.Bundle,
+			"peer":      key,
+			"handover":  handover,
+			"remaining": state.copies,
+		}).Debug("SprayAndWaitRouting takes back copies after a failed handover")
+	}
+}
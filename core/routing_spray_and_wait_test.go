@@ -0,0 +1,210 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+func TestSprayAndWaitRouting(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var sw = NewSprayAndWaitRouting(c, 8)
+	c.SetRoutingAlgorithm(sw)
+
+	c.convergenceSenders = append(c.convergenceSenders,
+		newPeerSender("dtn:b"), newPeerSender("dtn:c"), newPeerSender("dtn:d"), newPeerSender("dtn:e"))
+
+	// A bundle from this node starts with the initial copies.
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:dest"),
+			bundle.DtnNone(),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bp = NewBundlePack(bndl)
+
+	type handover struct {
+		peer    string
+		copies  uint
+		success bool
+	}
+
+	// Each call splits the copies among all peers without a copy. A failed
+	// handover keeps the copies, which are offered to the same peer again.
+	var rounds = [][]handover{
+		{{"dtn:b", 4, false}, {"dtn:c", 2, true}, {"dtn:d", 1, true}},
+		{{"dtn:b", 2, true}, {"dtn:e", 1, true}},
+		{},
+	}
+
+	for i, round := range rounds {
+		css, del := sw.SenderForBundle(bp)
+		if del {
+			t.Fatalf("SprayAndWaitRouting requested deletion")
+		}
+
+		if len(css) != len(round) {
+			t.Fatalf("SprayAndWaitRouting returned %v in round %d", css, i)
+		}
+
+		for j, test := range round {
+			if peer := css[j].GetPeerEndpointID().String(); peer != test.peer {
+				t.Fatalf("SprayAndWaitRouting returned %s instead of %s", peer, test.peer)
+			}
+
+			var sent = sw.PrepareSend(bp, css[j])
+			cb, err := sent.ExtensionBlock(bundle.SprayAndWaitBlock)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if copies := cb.Data.(uint); copies != test.copies {
+				t.Fatalf("Handed over %d copies to %s instead of %d", copies, test.peer, test.copies)
+			}
+
+			// The handover must not alter the stored bundle.
+			if _, err := bp.Bundle.ExtensionBlock(bundle.SprayAndWaitBlock); err == nil {
+				t.Fatalf("Handover added a Spray and Wait block to the stored bundle")
+			}
+
+			sw.ReportSent(bp, css[j], test.success)
+		}
+	}
+
+	// The last copy waits for a direct delivery.
+	if css, _ := sw.SenderForBundle(bp); len(css) != 0 {
+		t.Fatalf("SprayAndWaitRouting returned %v for the last copy", css)
+	}
+
+	// Received bundles with a single copy, without a Spray and Wait block, or
+	// with two copies.
+	var received = []struct {
+		blocks  []bundle.CanonicalBlock
+		senders int
+	}{
+		{[]bundle.CanonicalBlock{bundle.NewSprayAndWaitBlock(1, 0, 1)}, 0},
+		{nil, 0},
+		{[]bundle.CanonicalBlock{bundle.NewSprayAndWaitBlock(1, 0, 2)}, 1},
+	}
+
+	for i, test := range received {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn:dest"),
+				bundle.DtnNone(),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i+1)),
+				60*1000000),
+			append(test.blocks, bundle.NewPayloadBlock(0, []byte("hello world"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var bp = NewBundlePack(bndl)
+		bp.Receiver = bundle.MustNewEndpointID("dtn:a")
+		if css, _ := sw.SenderForBundle(bp); len(css) != test.senders {
+			t.Fatalf("SprayAndWaitRouting returned %v for received bundle %d", css, i)
+		}
+	}
+}
+
+func TestSprayAndWaitRoutingCore(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn:a"))
+	c.SetRoutingAlgorithm(NewSprayAndWaitRouting(c, 8))
+
+	var senderB, senderC, senderD = newPeerSender("dtn:b"), newPeerSender("dtn:c"), newPeerSender("dtn:d")
+	c.convergenceSenders = append(c.convergenceSenders, senderB, senderC)
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn:z"),
+			bundle.MustNewEndpointID("dtn:a"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var receiveCopies = func(ps peerSender, copies uint) {
+		select {
+		case sent := <-ps.bundles:
+			cb, err := sent.ExtensionBlock(bundle.SprayAndWaitBlock)
+			if err != nil {
+				t.Fatal(err)
+			} else if cb.Data.(uint) != copies {
+				t.Fatalf("%v received %d copies instead of %d", ps.peer, cb.Data.(uint), copies)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatalf("%v received no copies", ps.peer)
+		}
+	}
+
+	// Both connected peers receive their copies at once.
+	c.SendBundle(bndl)
+	receiveCopies(senderB, 4)
+	receiveCopies(senderC, 2)
+
+	// The pending bundle is dispatched again for an appeared peer.
+	c.convergenceMutex.Lock()
+	c.convergenceSenders = append(c.convergenceSenders, senderD)
+	c.convergenceMutex.Unlock()
+	c.routing.ReportPeerAppeared(senderD)
+
+	receiveCopies(senderD, 1)
+
+	// The last copy waits for its direct delivery.
+	select {
+	case sent := <-senderB.bundles:
+		t.Fatalf("Peer received another copy: %v", sent)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if bps := QueryPending(c.store); len(bps) != 1 {
+		t.Fatalf("Store contains %d instead of 1 pending bundles", len(bps))
+	}
+}
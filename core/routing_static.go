@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// StaticRoute is an entry of the StaticRouting's table. Bundles whose
+// destination matches the Pattern will be forwarded to the NextHop.
+//
+// The Pattern is a glob for endpoint IDs, where "*" matches any sequence of
+// characters and "?" matches a single character. Routes with a higher
+// Priority are preferred. If the NextHop is currently unavailable, a route
+// with the Fallback flag passes the bundle to the fallback RoutingAlgorithm.
+// Otherwise, the bundle waits for the NextHop.
+type StaticRoute struct {
+	Pattern  string
+	NextHop  bundle.EndpointID
+	Priority int
+	Fallback bool
+
+	regex *regexp.Regexp
+}
+
+// NewStaticRoute creates a new StaticRoute. An error is returned for an invalid
+// pattern.
+func NewStaticRoute(pattern string, nextHop bundle.EndpointID, priority int, fallback bool) (StaticRoute, error) {
+	regex, err := compileGlob(pattern)
+	if err != nil {
+		return StaticRoute{}, err
+	}
+
+	return StaticRoute{
+		Pattern:  pattern,
+		NextHop:  nextHop,
+		Priority: priority,
+		Fallback: fallback,
+		regex:    regex,
+	}, nil
+}
+
+// Matches returns true if the endpoint ID matches this StaticRoute's Pattern.
+func (sr StaticRoute) Matches(eid bundle.EndpointID) bool {
+	return sr.regex.MatchString(eid.String())
+}
+
+func (sr StaticRoute) String() string {
+	return fmt.Sprintf("%s via %v (priority %d, fallback %t)",
+		sr.Pattern, sr.NextHop, sr.Priority, sr.Fallback)
+}
+
+// StaticRouting is an implementation of a RoutingAlgorithm, forwarding bundles
+// based on a table of StaticRoutes. Bundles without a matching StaticRoute are
+// passed to the fallback RoutingAlgorithm. The table might be modified at
+// runtime.
+type StaticRouting struct {
+	c        *Core
+	fallback RoutingAlgorithm
+
+	routes []StaticRoute
+	mutex  sync.RWMutex
+}
+
+// NewStaticRouting creates a new StaticRouting RoutingAlgorithm interacting
+// with the given Core. The fallback RoutingAlgorithm is consulted for bundles
+// without a matching StaticRoute.
+func NewStaticRouting(c *Core, fallback RoutingAlgorithm) *StaticRouting {
+	return &StaticRouting{
+		c:        c,
+		fallback: fallback,
+	}
+}
+
+// AddRoute adds a StaticRoute to the table. A StaticRoute with the same pattern
+// and next hop will be replaced.
+func (sr *StaticRouting) AddRoute(route StaticRoute) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	sr.removeRoute(route.Pattern, route.NextHop)
+	sr.routes = append(sr.routes, route)
+
+	sort.SliceStable(sr.routes, func(i, j int) bool {
+		return sr.routes[i].Priority > sr.routes[j].Priority
+	})
+
+	log.WithFields(log.Fields{
+		"route": route,
+	}).Info("StaticRouting added route")
+}
+
+// removeRoute removes all StaticRoutes of the pattern and next hop. The mutex
+// must be locked.
+func (sr *StaticRouting) removeRoute(pattern string, nextHop bundle.EndpointID) (removed bool) {
+	for i := len(sr.routes) - 1; i >= 0; i-- {
+		if sr.routes[i].Pattern == pattern && sr.routes[i].NextHop == nextHop {
+			sr.routes = append(sr.routes[:i], sr.routes[i+1:]...)
+			removed = true
+		}
+	}
+
+	return
+}
+
+// RemoveRoute removes the StaticRoute of the pattern and next hop and returns
+// true if such a route existed.
+func (sr *StaticRouting) RemoveRoute(pattern string, nextHop bundle.EndpointID) bool {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	return sr.removeRoute(pattern, nextHop)
+}
+
+// Routes returns a copy of the table, sorted by priority.
+func (sr *StaticRouting) Routes() []StaticRoute {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	var routes = make([]StaticRoute, len(sr.routes))
+	copy(routes, sr.routes)
+
+	return routes
+}
+
+// NotifyIncoming passes the incoming bundle to the fallback RoutingAlgorithm.
+func (sr *StaticRouting) NotifyIncoming(bp BundlePack) {
+	sr.fallback.NotifyIncoming(bp)
+}
+
+// ReportPeerAppeared passes the appeared peer to the fallback
+// RoutingAlgorithm.
+func (sr *StaticRouting) ReportPeerAppeared(peer cla.Convergence) {
+	sr.fallback.ReportPeerAppeared(peer)
+}
+
+// ReportPeerDisappeared passes the disappeared peer to the fallback
+// RoutingAlgorithm.
+func (sr *StaticRouting) ReportPeerDisappeared(peer cla.Convergence) {
+	sr.fallback.ReportPeerDisappeared(peer)
+}
+
+// SenderForBundle returns the ConvergenceSender of the next hop of the first
+// matching and available StaticRoute. If no StaticRoute matches or an
+// unavailable StaticRoute allows a fallback, the fallback RoutingAlgorithm is
+// consulted. Otherwise, no ConvergenceSender is returned and the bundle waits
+// for its next hop.
+func (sr *StaticRouting) SenderForBundle(bp BundlePack) ([]cla.ConvergenceSender, bool) {
+	var destination = bp.Bundle.PrimaryBlock.Destination
+	var matched, fallback = false, false
+
+	sr.mutex.RLock()
+	for _, route := range sr.routes {
+		if !route.Matches(destination) {
+			continue
+		}
+
+		matched = true
+		fallback = fallback || route.Fallback
+
+		if css := sr.c.senderForDestination(route.NextHop); css != nil {
+			sr.mutex.RUnlock()
+
+			log.WithFields(log.Fields{
+				"bundle": bp.Bundle,
+				"route":  route,
+			}).Debug("StaticRouting found a route")
+
+			return css, false
+		}
+	}
+	sr.mutex.RUnlock()
+
+	if matched && !fallback {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Debug("StaticRouting's routes are unavailable, bundle waits")
+
+		return nil, false
+	}
+
+	return sr.fallback.SenderForBundle(bp)
+}
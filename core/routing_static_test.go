@@ -0,0 +1,147 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla"
+)
+
+// fallbackRouting is a dummy RoutingAlgorithm, returning a fixed
+// ConvergenceSender.
+type fallbackRouting struct {
+	cs cla.ConvergenceSender
+}
+
+func (fr fallbackRouting) NotifyIncoming(_ BundlePack)             {}
+func (fr fallbackRouting) ReportPeerAppeared(_ cla.Convergence)    {}
+func (fr fallbackRouting) ReportPeerDisappeared(_ cla.Convergence) {}
+func (fr fallbackRouting) SenderForBundle(_ BundlePack) ([]cla.ConvergenceSender, bool) {
+	return []cla.ConvergenceSender{fr.cs}, false
+}
+
+func TestStaticRouteMatches(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		eid     string
+		matches bool
+	}{
+		{"dtn:gamma*", "dtn:gamma", true},
+		{"dtn:gamma*", "dtn:gamma/foo/bar", true},
+		{"dtn:gamma*", "dtn:alpha", false},
+		{"dtn:gamma?", "dtn:gamma1", true},
+		{"dtn:gamma?", "dtn:gamma12", false},
+		{"dtn:gamma.foo", "dtn:gammaxfoo", false},
+		{"ipn:23.*", "ipn:23.42", true},
+		{"*", "dtn:none", true},
+	}
+
+	for _, test := range tests {
+		route, err := NewStaticRoute(test.pattern, bundle.MustNewEndpointID("dtn:beta"), 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := route.Matches(bundle.MustNewEndpointID(test.eid)); m != test.matches {
+			t.Fatalf("Pattern %s matching %s resulted in %t", test.pattern, test.eid, m)
+		}
+	}
+}
+
+func TestStaticRouting(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var senderBeta = newPeerSender("dtn:beta")
+	var senderDelta = newPeerSender("dtn:delta")
+	var senderFallback = newPeerSender("dtn:fallback")
+	c.convergenceSenders = append(c.convergenceSenders, senderBeta, senderDelta)
+
+	var sr = NewStaticRouting(c, fallbackRouting{senderFallback})
+
+	var addRoute = func(pattern, nextHop string, priority int, fallback bool) {
+		route, err := NewStaticRoute(pattern, bundle.MustNewEndpointID(nextHop), priority, fallback)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sr.AddRoute(route)
+	}
+
+	addRoute("dtn:gamma*", "dtn:beta", 1, false)
+	addRoute("dtn:gamma*", "dtn:delta", 10, false)
+	addRoute("dtn:epsilon*", "dtn:zeta", 1, false)
+	addRoute("dtn:eta*", "dtn:zeta", 1, true)
+
+	if routes := sr.Routes(); len(routes) != 4 || routes[0].NextHop != senderDelta.peer {
+		t.Fatalf("Routes are not sorted by priority: %v", routes)
+	}
+
+	var tests = []struct {
+		destination string
+		peers       []string
+	}{
+		{"dtn:gamma/foo", []string{"dtn:delta"}},
+		{"dtn:epsilon", []string{}},
+		{"dtn:eta", []string{"dtn:fallback"}},
+		{"dtn:theta", []string{"dtn:fallback"}},
+	}
+
+	var check = func() {
+		for _, test := range tests {
+			bndl, err := bundle.NewBundle(
+				bundle.NewPrimaryBlock(
+					bundle.MustNotFragmented,
+					bundle.MustNewEndpointID(test.destination),
+					bundle.DtnNone(),
+					bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0), 60*1000000),
+				[]bundle.CanonicalBlock{
+					bundle.NewPayloadBlock(0, []byte("hello world")),
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var bp = NewBundlePack(bndl)
+
+			css, _ := sr.SenderForBundle(bp)
+
+			if len(css) != len(test.peers) {
+				t.Fatalf("Destination %s resulted in %v, expected %v", test.destination, css, test.peers)
+			}
+
+			for i, cs := range css {
+				if cs.GetPeerEndpointID().String() != test.peers[i] {
+					t.Fatalf("Destination %s resulted in %v, expected %v", test.destination, css, test.peers)
+				}
+			}
+		}
+	}
+
+	check()
+
+	// Without the preferred route, the next route will be used.
+	if !sr.RemoveRoute("dtn:gamma*", senderDelta.peer) {
+		t.Fatalf("Removing an existing route failed")
+	}
+	if sr.RemoveRoute("dtn:gamma*", senderDelta.peer) {
+		t.Fatalf("Removing an already removed route succeeded")
+	}
+
+	tests[0].peers = []string{"dtn:beta"}
+	check()
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"fmt"
 	"os"
 	"sync"
 
@@ -64,6 +65,7 @@ func (store *SimpleStore) Push(bp BundlePack) error {
 	// Originally, bundle packs without any constraints were removed from the
 	// store, as advided in dtn-bpbis. However, removing all track of a bundle
 	// whatsoever	resulted in accepting an already known bundle.
+	bp.copyConstraints()
 	store.bundles[bp.Bundle.ID()] = bp
 
 	return store.sync()
@@ -75,9 +77,54 @@ func (store *SimpleStore) Query(sel func(BundlePack) bool) (bps []BundlePack) {
 
 	for _, v := range store.bundles {
 		if sel(v) {
+			v.copyConstraints()
 			bps = append(bps, v)
 		}
 	}
 
 	return
 }
+
+func (store *SimpleStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.bundles[id]; !ok {
+		return nil
+	}
+
+	delete(store.bundles, id)
+
+	return store.sync()
+}
+
+// Lookup returns the bundle pack of the requested bundle ID and true, or false
+// if there is no such bundle pack.
+func (store *SimpleStore) Lookup(id string) (bp BundlePack, ok bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if bp, ok = store.bundles[id]; ok {
+		bp.copyConstraints()
+	}
+	return
+}
+
+// MigrateSimpleStore imports all bundle packs of a SimpleStore's file into
+// another Store. Afterwards, the SimpleStore's file will be renamed by
+// appending ".migrated" to prevent a repeated migration.
+func MigrateSimpleStore(filename string, store Store) (int, error) {
+	simpleStore, err := NewSimpleStore(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	var bps = simpleStore.Query(func(_ BundlePack) bool { return true })
+	for _, bp := range bps {
+		if err := store.Push(bp); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(bps), os.Rename(filename, fmt.Sprintf("%s.migrated", filename))
+}
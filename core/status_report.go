@@ -119,6 +119,10 @@ const (
 	// HopLimitExceeded is the "Hop limit exceeded" bundle status report reason
 	// code.
 	HopLimitExceeded StatusReportReason = 9
+
+	// FailedSecurityOperation is the "Failed security operation" bundle status
+	// report reason code, defined in draft-ietf-dtn-bpsec.
+	FailedSecurityOperation StatusReportReason = 15
 )
 
 func (srr StatusReportReason) String() string {
@@ -153,6 +157,9 @@ func (srr StatusReportReason) String() string {
 	case HopLimitExceeded:
 		return "Hop limit exceeded"
 
+	case FailedSecurityOperation:
+		return "Failed security operation"
+
 	default:
 		return "unknown"
 	}
@@ -0,0 +1,389 @@
+package core
+
+import (
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// StorageQuota limits the bundles held by the Core's store. A zero value
+// disables the respective limit.
+//
+// MaxBytes limits the size of all stored bundles and MaxBundles their number.
+// MaxSourceShare limits the share of both limits a single source node might
+// occupy, e.g., 0.25 for a quarter.
+//
+// A new bundle exceeding the quota will be refused, unless Evict is set. In
+// this case, stored bundles of a lower priority or, within the same priority,
+// an earlier expiration are evicted to make room for the new bundle. Bundles in
+// this node's custody are never evicted.
+//
+// Bundles are refused or evicted with a DepletedStorage deletion status
+// report, if requested. Administrative records created by this node are never
+// refused.
+type StorageQuota struct {
+	MaxBytes       uint64
+	MaxBundles     uint
+	MaxSourceShare float64
+	Evict          bool
+}
+
+// isEnabled returns true if any limit is set.
+func (sq StorageQuota) isEnabled() bool {
+	return sq.MaxBytes > 0 || sq.MaxBundles > 0
+}
+
+// sourceLimits returns the limits for a single source node, based on the
+// MaxSourceShare.
+func (sq StorageQuota) sourceLimits() (maxBytes uint64, maxBundles uint) {
+	if sq.MaxSourceShare <= 0 || sq.MaxSourceShare >= 1 {
+		return sq.MaxBytes, sq.MaxBundles
+	}
+
+	maxBytes = uint64(sq.MaxSourceShare * float64(sq.MaxBytes))
+	maxBundles = uint(sq.MaxSourceShare * float64(sq.MaxBundles))
+	return
+}
+
+// storageUsage sums up the size and number of stored bundles.
+type storageUsage struct {
+	bytes   uint64
+	bundles uint
+}
+
+func (su *storageUsage) add(size uint64) {
+	su.bytes += size
+	su.bundles++
+}
+
+func (su *storageUsage) remove(size uint64) {
+	su.bytes -= size
+	su.bundles--
+}
+
+// exceeds checks if this storageUsage exceeds the limits. A zero limit is
+// ignored.
+func (su storageUsage) exceeds(maxBytes uint64, maxBundles uint) bool {
+	return (maxBytes > 0 && su.bytes > maxBytes) ||
+		(maxBundles > 0 && su.bundles > maxBundles)
+}
+
+// storedSize returns the size of the BundlePack's bundle in bytes.
+func storedSize(bp BundlePack) uint64 {
+	return bp.Bundle.EncodedSize()
+}
+
+// isCounted checks if the stored BundlePack counts towards the StorageQuota.
+func isCounted(bp BundlePack) bool {
+	return !bp.Tombstone && bp.HasConstraints()
+}
+
+// usageEntry is a stored bundle's share of the storeUsage.
+type usageEntry struct {
+	size   uint64
+	source string
+}
+
+// storeUsage keeps the storageUsage of all stored bundles and of each source
+// node. Thus, admitting a new bundle does not require querying the store.
+type storeUsage struct {
+	total   storageUsage
+	sources map[string]storageUsage
+	entries map[string]usageEntry
+}
+
+// newStoreUsage creates a storeUsage of the bundles currently stored.
+func newStoreUsage(store Store) *storeUsage {
+	var su = &storeUsage{
+		sources: make(map[string]storageUsage),
+		entries: make(map[string]usageEntry),
+	}
+
+	for _, bp := range store.Query(isCounted) {
+		su.track(bp)
+	}
+
+	return su
+}
+
+// add counts a bundle of the source and size, identified by its ID.
+func (su *storeUsage) add(id, source string, size uint64) {
+	su.remove(id)
+
+	var srcUsage = su.sources[source]
+	srcUsage.add(size)
+	su.sources[source] = srcUsage
+
+	su.total.add(size)
+	su.entries[id] = usageEntry{size: size, source: source}
+}
+
+// remove stops counting the bundle of the ID, if it was counted.
+func (su *storeUsage) remove(id string) {
+	entry, ok := su.entries[id]
+	if !ok {
+		return
+	}
+
+	var srcUsage = su.sources[entry.source]
+	if srcUsage.remove(entry.size); srcUsage.bundles == 0 {
+		delete(su.sources, entry.source)
+	} else {
+		su.sources[entry.source] = srcUsage
+	}
+
+	su.total.remove(entry.size)
+	delete(su.entries, id)
+}
+
+// track updates the storeUsage after the BundlePack was stored. A bundle is
+// only measured when it starts counting.
+func (su *storeUsage) track(bp BundlePack) {
+	var id = bp.Bundle.ID()
+
+	if _, ok := su.entries[id]; ok && isCounted(bp) {
+		return
+	} else if isCounted(bp) {
+		su.add(id, bp.Bundle.PrimaryBlock.SourceNode.String(), storedSize(bp))
+	} else {
+		su.remove(id)
+	}
+}
+
+// quotaStore wraps the Core's Store to keep the storeUsage up to date, once a
+// StorageQuota was enabled.
+type quotaStore struct {
+	Store
+	c *Core
+}
+
+// Push inserts or updates the BundlePack and updates the storeUsage.
+func (qs quotaStore) Push(bp BundlePack) error {
+	if err := qs.Store.Push(bp); err != nil {
+		return err
+	}
+
+	qs.c.quotaMutex.Lock()
+	if qs.c.usage != nil {
+		qs.c.usage.track(bp)
+	}
+	qs.c.quotaMutex.Unlock()
+
+	return nil
+}
+
+// Delete removes the BundlePack and updates the storeUsage.
+func (qs quotaStore) Delete(id string) error {
+	if err := qs.Store.Delete(id); err != nil {
+		return err
+	}
+
+	qs.c.quotaMutex.Lock()
+	if qs.c.usage != nil {
+		qs.c.usage.remove(id)
+	}
+	qs.c.quotaMutex.Unlock()
+
+	return nil
+}
+
+// Lookup passes the request to the wrapped Store, see LookupBundle.
+func (qs quotaStore) Lookup(id string) (BundlePack, bool) {
+	return LookupBundle(qs.Store, id)
+}
+
+// Close closes the wrapped Store, if it implements the io.Closer interface.
+func (qs quotaStore) Close() error {
+	if closer, ok := qs.Store.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// SetStorageQuota sets the StorageQuota for the store. Already stored bundles
+// are not affected, even if they exceed the new quota. Enabling a quota for the
+// first time measures the stored bundles once; afterwards, their usage is
+// kept up to date.
+func (c *Core) SetStorageQuota(quota StorageQuota) {
+	c.quotaMutex.Lock()
+	defer c.quotaMutex.Unlock()
+
+	c.quota = quota
+	if quota.isEnabled() && c.usage == nil {
+		c.usage = newStoreUsage(c.store)
+	}
+}
+
+// StorageQuota returns the current StorageQuota.
+func (c *Core) StorageQuota() StorageQuota {
+	c.quotaMutex.Lock()
+	defer c.quotaMutex.Unlock()
+
+	return c.quota
+}
+
+// isEvictable checks if the stored BundlePack might be evicted in favor of
+// the new one.
+func (c *Core) isEvictable(stored, bp BundlePack) bool {
+	if stored.HasConstraint(CustodyAccepted) {
+		return false
+	}
+
+	storedPrio, prio := stored.Priority(), bp.Priority()
+	if storedPrio != prio {
+		return prio.Higher(storedPrio)
+	}
+
+	return c.expiration(stored).Before(c.expiration(bp))
+}
+
+// admitToStore checks if the BundlePack fits into the store, based on the
+// StorageQuota. If required and allowed, other bundles will be evicted. False
+// is returned if the BundlePack must be refused.
+//
+// An admitted BundlePack is counted immediately. Thus, concurrently admitted
+// bundles cannot exceed the StorageQuota together.
+func (c *Core) admitToStore(bp BundlePack) bool {
+	var id = bp.Bundle.ID()
+	var src = bp.Bundle.PrimaryBlock.SourceNode
+	var size = storedSize(bp)
+
+	c.quotaMutex.Lock()
+
+	var quota = c.quota
+	if !quota.isEnabled() {
+		c.quotaMutex.Unlock()
+		return true
+	}
+
+	var srcMaxBytes, srcMaxBundles = quota.sourceLimits()
+
+	// The usage of a known bundle is replaced by the new one.
+	var total, source = c.usage.total, c.usage.sources[src.String()]
+	if entry, ok := c.usage.entries[id]; ok {
+		total.remove(entry.size)
+		if entry.source == src.String() {
+			source.remove(entry.size)
+		}
+	}
+
+	total.add(size)
+	source.add(size)
+
+	var exceeded = func() bool {
+		return total.exceeds(quota.MaxBytes, quota.MaxBundles) ||
+			source.exceeds(srcMaxBytes, srcMaxBundles)
+	}
+
+	if !exceeded() {
+		c.usage.add(id, src.String(), size)
+		c.quotaMutex.Unlock()
+
+		return true
+	}
+
+	if !quota.Evict {
+		c.quotaMutex.Unlock()
+
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Info("Bundle exceeds the storage quota")
+
+		return false
+	}
+
+	// Only an eviction requires the stored bundles.
+	var candidates []BundlePack
+	for _, s := range c.store.Query(func(stored BundlePack) bool {
+		return isCounted(stored) && stored.Bundle.ID() != id
+	}) {
+		if _, ok := c.usage.entries[s.Bundle.ID()]; ok && c.isEvictable(s, bp) {
+			candidates = append(candidates, s)
+		}
+	}
+	c.sortForEviction(candidates)
+
+	var evictions []BundlePack
+	for _, candidate := range candidates {
+		if !exceeded() {
+			break
+		}
+
+		var fromSource = candidate.Bundle.PrimaryBlock.SourceNode == src
+
+		// Evicting other sources' bundles does not help an exceeded source share.
+		if !total.exceeds(quota.MaxBytes, quota.MaxBundles) && !fromSource {
+			continue
+		}
+
+		var candidateSize = c.usage.entries[candidate.Bundle.ID()].size
+		total.remove(candidateSize)
+		if fromSource {
+			source.remove(candidateSize)
+		}
+
+		evictions = append(evictions, candidate)
+	}
+
+	if exceeded() {
+		c.quotaMutex.Unlock()
+
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+		}).Info("Bundle exceeds the storage quota, even after evicting other bundles")
+
+		return false
+	}
+
+	for _, eviction := range evictions {
+		c.usage.remove(eviction.Bundle.ID())
+	}
+	c.usage.add(id, src.String(), size)
+	c.quotaMutex.Unlock()
+
+	for _, eviction := range evictions {
+		log.WithFields(log.Fields{
+			"bundle":  bp.Bundle,
+			"evicted": eviction.Bundle,
+		}).Info("Evicting bundle to comply with the storage quota")
+
+		c.evictBundle(eviction)
+	}
+
+	return true
+}
+
+// evictBundle deletes the stored BundlePack because of depleted storage. Its
+// tombstone replaces the bundle immediately to free the storage.
+func (c *Core) evictBundle(bp BundlePack) {
+	c.bundleDeletion(bp, DepletedStorage)
+
+	if err := c.store.Push(c.toTombstone(bp)); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Failed to replace evicted bundle by its tombstone")
+	}
+}
+
+// refuseBundle deletes a new BundlePack, which exceeds the storage quota. No
+// tombstone is kept. Thus, the bundle might be received again later. For a
+// received bundle, a requested custody transfer is refused.
+func (c *Core) refuseBundle(bp BundlePack) {
+	if requestsCustody(bp) && !bp.Bundle.IsAdministrativeRecord() &&
+		c.nodeId != bundle.DtnNone() && bp.HasPreviousNode() {
+		c.SendCustodySignal(bp, false, CustodyDepletedStorage)
+	}
+
+	c.bundleDeletion(bp, DepletedStorage)
+
+	if err := c.store.Delete(bp.Bundle.ID()); err != nil {
+		log.WithFields(log.Fields{
+			"bundle": bp.Bundle,
+			"error":  err,
+		}).Warn("Failed to remove refused bundle")
+	}
+}
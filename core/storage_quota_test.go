@@ -0,0 +1,298 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/geistesk/dtn7/bundle"
+)
+
+// storedBundles counts the stored bundles, which are neither tombstones nor
+// administrative records.
+func storedBundles(c *Core) int {
+	return len(c.store.Query(func(bp BundlePack) bool {
+		return !bp.Tombstone && bp.HasConstraints() && !bp.Bundle.IsAdministrativeRecord()
+	}))
+}
+
+func TestStorageQuotaRefuse(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+	c.SetStorageQuota(StorageQuota{MaxBundles: 2})
+
+	bndl, err := bundle.NewBundle(
+		bundle.NewPrimaryBlock(
+			bundle.MustNotFragmented,
+			bundle.MustNewEndpointID("dtn://omega/"),
+			bundle.MustNewEndpointID("dtn://alpha/"),
+			bundle.NewCreationTimestamp(bundle.DtnTimeNow(), 0),
+			60*60*1000000),
+		[]bundle.CanonicalBlock{
+			bundle.NewPayloadBlock(0, []byte("hello world")),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := c.SendBundle(bndl)
+		if i < 2 && err != nil {
+			t.Fatalf("Sending bundle %d errored: %v", i, err)
+		} else if i == 2 && err == nil {
+			t.Fatalf("Sending bundle %d exceeding the quota did not error", i)
+		}
+	}
+
+	if n := storedBundles(c); n != 2 {
+		t.Fatalf("Store contains %d instead of 2 bundles", n)
+	}
+
+	// The quota might be raised at runtime.
+	c.SetStorageQuota(StorageQuota{MaxBundles: 3})
+	if err := c.SendBundle(bndl); err != nil {
+		t.Fatalf("Sending bundle after raising the quota errored: %v", err)
+	}
+}
+
+func TestStorageQuotaEvict(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetStorageQuota(StorageQuota{MaxBundles: 2, Evict: true})
+
+	var bndls []bundle.Bundle
+	for i, class := range []bundle.PriorityClass{
+		bundle.BulkPriority, bundle.NormalPriority, bundle.ExpeditedPriority, bundle.BulkPriority} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn://omega/"),
+				bundle.MustNewEndpointID("dtn://beta/"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPriorityBlock(1, 0, bundle.NewPriority(class, 0)),
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bndls = append(bndls, bndl)
+	}
+
+	var bulk, normal, expedited, bulk2 = bndls[0], bndls[1], bndls[2], bndls[3]
+
+	for _, bndl := range []bundle.Bundle{bulk, normal, expedited} {
+		c.receive(NewBundlePack(bndl))
+	}
+
+	if n := storedBundles(c); n != 2 {
+		t.Fatalf("Store contains %d instead of 2 bundles", n)
+	}
+
+	if bp, ok := LookupBundle(c.store, bulk.ID()); !ok || !bp.Tombstone {
+		t.Fatalf("Bulk bundle was not evicted: %v", bp)
+	}
+
+	for _, bndl := range []bundle.Bundle{normal, expedited} {
+		if bp, ok := LookupBundle(c.store, bndl.ID()); !ok || bp.Tombstone {
+			t.Fatalf("Bundle %v was evicted", bndl)
+		}
+	}
+
+	// Another bulk bundle must not evict any more important bundle.
+	c.receive(NewBundlePack(bulk2))
+
+	if _, ok := LookupBundle(c.store, bulk2.ID()); ok {
+		t.Fatalf("Bulk bundle exceeding the quota was stored")
+	}
+	if n := storedBundles(c); n != 2 {
+		t.Fatalf("Store contains %d instead of 2 bundles", n)
+	}
+}
+
+func TestStorageQuotaSourceShare(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetNodeId(bundle.MustNewEndpointID("dtn://alpha/"))
+	c.SetStorageQuota(StorageQuota{MaxBundles: 4, MaxSourceShare: 0.5})
+
+	var receive = func(bndl bundle.Bundle) {
+		bp := NewBundlePack(bndl)
+		bp.Receiver = bundle.MustNewEndpointID("dtn://alpha/")
+		c.receive(bp)
+	}
+
+	var bndls []bundle.Bundle
+	for i, src := range []string{"dtn://beta/", "dtn://beta/", "dtn://beta/", "dtn://gamma/"} {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented|bundle.StatusRequestDeletion,
+				bundle.MustNewEndpointID("dtn://omega/"),
+				bundle.MustNewEndpointID(src),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), uint(i)),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bndls = append(bndls, bndl)
+	}
+
+	var betas, gamma = bndls[:3], bndls[3]
+	for _, bndl := range betas {
+		receive(bndl)
+	}
+
+	if _, ok := LookupBundle(c.store, betas[2].ID()); ok {
+		t.Fatalf("Bundle exceeding the source's share was stored")
+	}
+
+	// The refused bundle requested a deletion status report.
+	var reports = c.store.Query(func(bp BundlePack) bool {
+		return bp.Bundle.IsAdministrativeRecord()
+	})
+	if len(reports) != 1 {
+		t.Fatalf("Store contains %d instead of 1 status reports", len(reports))
+	}
+
+	payload, err := reports[0].Bundle.PayloadBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := NewAdministrativeRecordFromCbor(payload.Data.([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sr, ok := ar.Content.(StatusReport); !ok || sr.ReportReason != DepletedStorage {
+		t.Fatalf("Administrative record is no depleted storage status report: %v", ar)
+	}
+
+	// Other sources are not affected.
+	receive(gamma)
+
+	if _, ok := LookupBundle(c.store, gamma.ID()); !ok {
+		t.Fatalf("Bundle of another source was not stored")
+	}
+}
+
+func TestStorageQuotaConcurrent(t *testing.T) {
+	file, err := ioutil.TempFile("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// We don't want this file; just it's filename.
+	os.Remove(file.Name())
+	defer os.Remove(file.Name())
+
+	c, err := NewCore(file.Name(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetStorageQuota(StorageQuota{MaxBundles: 5})
+
+	var bndls []bundle.Bundle
+	for i := uint(0); i < 22; i++ {
+		bndl, err := bundle.NewBundle(
+			bundle.NewPrimaryBlock(
+				bundle.MustNotFragmented,
+				bundle.MustNewEndpointID("dtn://omega/"),
+				bundle.MustNewEndpointID("dtn://beta/"),
+				bundle.NewCreationTimestamp(bundle.DtnTimeNow(), i),
+				60*60*1000000),
+			[]bundle.CanonicalBlock{
+				bundle.NewPayloadBlock(0, []byte("hello world")),
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bndls = append(bndls, bndl)
+	}
+
+	var admitted = make(chan bundle.Bundle, 20)
+
+	var wg sync.WaitGroup
+	wg.Add(20)
+	for _, bndl := range bndls[:20] {
+		go func(bndl bundle.Bundle) {
+			defer wg.Done()
+
+			if c.admitToStore(NewBundlePack(bndl)) {
+				admitted <- bndl
+			}
+		}(bndl)
+	}
+	wg.Wait()
+	close(admitted)
+
+	var admittedBndls []bundle.Bundle
+	for bndl := range admitted {
+		admittedBndls = append(admittedBndls, bndl)
+	}
+
+	if len(admittedBndls) != 5 {
+		t.Fatalf("Admitted %d instead of 5 concurrent bundles", len(admittedBndls))
+	}
+
+	// Removing an admitted bundle makes room for another one.
+	if c.admitToStore(NewBundlePack(bndls[20])) {
+		t.Fatalf("Admitted a bundle exceeding the quota")
+	}
+
+	if err := c.store.Delete(admittedBndls[0].ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.admitToStore(NewBundlePack(bndls[21])) {
+		t.Fatalf("Removed bundle still counts towards the quota")
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import log "github.com/sirupsen/logrus"
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/geistesk/dtn7/bundle"
+)
 
 // Store is an interface for a bundle storage.
 type Store interface {
@@ -15,6 +19,18 @@ type Store interface {
 	// Some convenience function, starting with "Query", are existing in the
 	// core package.
 	Query(func(BundlePack) bool) []BundlePack
+
+	// Delete removes the bundle pack of the given bundle ID. Removing an
+	// unknown bundle pack is not an error.
+	Delete(id string) error
+}
+
+// StoreLookup is an optional interface for Stores, which are able to look up a
+// single bundle pack by its bundle's ID without querying all bundle packs.
+type StoreLookup interface {
+	// Lookup returns the bundle pack of the requested bundle ID and true, or
+	// false if there is no such bundle pack.
+	Lookup(id string) (BundlePack, bool)
 }
 
 // QueryAll is a helper function for Stores and queries all bundle packs.
@@ -33,6 +49,14 @@ func QueryFromStatusReport(store Store, sr StatusReport) []BundlePack {
 	})
 }
 
+// QueryFromCustodySignal returns all (hopefully <= 1) bundles related to the
+// given CustodySignal, for which this node is a custodian.
+func QueryFromCustodySignal(store Store, cs CustodySignal) []BundlePack {
+	return store.Query(func(bp BundlePack) bool {
+		return bp.HasConstraint(CustodyAccepted) && cs.Matches(*bp.Bundle)
+	})
+}
+
 // QueryPending is a helper function for Stores and queries those bundle packs,
 // which could not be delivered previously, but are complete (not fragmented).
 func QueryPending(store Store) []BundlePack {
@@ -41,17 +65,42 @@ func QueryPending(store Store) []BundlePack {
 	})
 }
 
+// QueryFragments returns all bundle packs of fragments belonging to the same
+// bundle as the given one, which are pending reassembly.
+func QueryFragments(store Store, b bundle.Bundle) []BundlePack {
+	return store.Query(func(bp BundlePack) bool {
+		return bp.HasConstraint(ReassemblyPending) && bp.Bundle.IsFragmentOf(b)
+	})
+}
+
+// LookupBundle returns the BundlePack of the requested bundle ID and true, or
+// false if the store does not know this bundle. A StoreLookup will be used, if
+// the store implements it.
+func LookupBundle(store Store, id string) (BundlePack, bool) {
+	if lookupStore, ok := store.(StoreLookup); ok {
+		return lookupStore.Lookup(id)
+	}
+
+	var bps = store.Query(func(bp BundlePack) bool {
+		return bp.Bundle.ID() == id
+	})
+	if len(bps) == 0 {
+		return BundlePack{}, false
+	}
+
+	return bps[0], true
+}
+
 // KnowsBundle returns true if the requested store knows a BundlePack which
 // bundle equals the requested BundlePack's.
 func KnowsBundle(store Store, requested BundlePack) bool {
-	return store.Query(func(bp BundlePack) bool {
-		return bp.Bundle.ID() == requested.Bundle.ID()
-	}) != nil
+	_, known := LookupBundle(store, requested.Bundle.ID())
+	return known
 }
 
 // NoStore is a dummy implemention of the Store interface which represents, as
-// the name indicates, no store whatsoever. Push will produce log messages and
-// Query has no functionality at all.
+// the name indicates, no store whatsoever. Push will produce log messages,
+// Query and Delete have no functionality at all.
 type NoStore struct{}
 
 func (_ NoStore) Push(bp BundlePack) error {
@@ -64,3 +113,7 @@ func (_ NoStore) Push(bp BundlePack) error {
 func (_ NoStore) Query(_ func(BundlePack) bool) []BundlePack {
 	return nil
 }
+
+func (_ NoStore) Delete(_ string) error {
+	return nil
+}
@@ -32,6 +32,14 @@ const (
 	// STCP is the "Simple TCP Convergence-Layer Protocol" as specified in
 	// draft-burleigh-dtn-stcp-00 or newer documents.
 	STCP CLAType = 1
+
+	// UDP is the simple UDP convergence layer of the cla/udp package. The
+	// DiscoveryMessage's Additionals contain the maximum bundle size.
+	UDP CLAType = 2
+
+	// MTCP is the "Minimal TCP Convergence-Layer Protocol", framing each bundle
+	// as a CBOR byte string.
+	MTCP CLAType = 3
 )
 
 // DiscoveryMessage is the kind of message used by this peer/neighbor discovery.
@@ -62,6 +70,27 @@ func DiscoveryMessagesToCbor(dms []DiscoveryMessage) (buff []byte, err error) {
 	return
 }
 
+// SetMaxBundleSize stores the maximum bundle size, e.g., of an UDP CLA, CBOR
+// encoded in the Additionals field.
+func (dm *DiscoveryMessage) SetMaxBundleSize(size uint) {
+	dm.Additionals = nil
+	codec.NewEncoderBytes(&dm.Additionals, new(codec.CborHandle)).MustEncode(size)
+}
+
+// MaxBundleSize returns the maximum bundle size, stored by SetMaxBundleSize in
+// the Additionals field. Zero is returned if there is no such value.
+func (dm DiscoveryMessage) MaxBundleSize() (size uint) {
+	if len(dm.Additionals) == 0 {
+		return 0
+	}
+
+	if err := codec.NewDecoderBytes(dm.Additionals, new(codec.CborHandle)).Decode(&size); err != nil {
+		return 0
+	}
+
+	return
+}
+
 func (dm DiscoveryMessage) String() string {
 	var builder strings.Builder
 
@@ -72,6 +101,10 @@ func (dm DiscoveryMessage) String() string {
 		fmt.Fprintf(&builder, "TCPCLv4")
 	case STCP:
 		fmt.Fprintf(&builder, "STCP")
+	case UDP:
+		fmt.Fprintf(&builder, "UDP")
+	case MTCP:
+		fmt.Fprintf(&builder, "MTCP")
 	default:
 		fmt.Fprintf(&builder, "Unknown CLA")
 	}
@@ -82,3 +82,31 @@ func TestDiscoveryMessageCbor(t *testing.T) {
 		t.Logf("%x", buff)
 	}
 }
+
+func TestDiscoveryMessageMaxBundleSize(t *testing.T) {
+	var dm = DiscoveryMessage{
+		Type:     UDP,
+		Endpoint: bundle.MustNewEndpointID("dtn:foobar"),
+		Port:     35040,
+	}
+
+	if size := dm.MaxBundleSize(); size != 0 {
+		t.Fatalf("Empty Additionals resulted in a maximum size of %d", size)
+	}
+
+	dm.SetMaxBundleSize(1400)
+
+	buff, err := DiscoveryMessagesToCbor([]DiscoveryMessage{dm})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dms, err := NewDiscoveryMessagesFromCbor(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := dms[0].MaxBundleSize(); size != 1400 {
+		t.Fatalf("Decoded maximum size is %d instead of 1400", size)
+	}
+}
@@ -6,7 +6,11 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/geistesk/dtn7/bundle"
+	"github.com/geistesk/dtn7/cla/mtcp"
 	"github.com/geistesk/dtn7/cla/stcp"
+	"github.com/geistesk/dtn7/cla/tcpcl"
+	"github.com/geistesk/dtn7/cla/udp"
 	"github.com/geistesk/dtn7/core"
 	"github.com/schollz/peerdiscovery"
 )
@@ -14,7 +18,8 @@ import (
 // DiscoveryService is a type to publish the node's CLAs to its network while
 // discovering new peers. Internally UDP mulitcast packets are used.
 type DiscoveryService struct {
-	c *core.Core
+	c      *core.Core
+	nodeId bundle.EndpointID
 
 	stopChan4 chan struct{}
 	stopChan6 chan struct{}
@@ -50,18 +55,36 @@ func (ds *DiscoveryService) handleDiscovery(dm DiscoveryMessage, addr string) {
 		"message":   dm,
 	}).Debug("Peer discovery received a message")
 
-	if dm.Type != STCP {
+	var address = fmt.Sprintf("%s:%d", addr, dm.Port)
+
+	switch dm.Type {
+	case STCP:
+		ds.c.RegisterConvergence(stcp.NewSTCPClient(address, dm.Endpoint, false))
+
+	case MTCP:
+		ds.c.RegisterConvergence(mtcp.NewMTCPClient(address, dm.Endpoint, false))
+
+	case TCPCLV4:
+		if ds.nodeId == bundle.DtnNone() {
+			log.WithFields(log.Fields{
+				"discovery": ds,
+				"peer":      addr,
+			}).Warn("DiscoveryService has no node ID, ignoring TCPCLv4 peer")
+			return
+		}
+
+		ds.c.RegisterConvergence(tcpcl.NewTCPCLClient(address, ds.nodeId, dm.Endpoint, false))
+
+	case UDP:
+		ds.c.RegisterConvergence(udp.NewUDPClient(address, dm.Endpoint, dm.MaxBundleSize(), false))
+
+	default:
 		log.WithFields(log.Fields{
 			"discovery": ds,
 			"peer":      addr,
 			"type":      uint(dm.Type),
 		}).Warn("DiscoveryMessage's Type is unknown or unsupported")
-		return
 	}
-
-	client := stcp.NewSTCPClient(
-		fmt.Sprintf("%s:%d", addr, dm.Port), dm.Endpoint, false)
-	ds.c.RegisterConvergence(client)
 }
 
 // Close shuts the DiscoveryService down.
@@ -77,8 +100,10 @@ func (ds *DiscoveryService) Close() {
 
 // NewDiscoveryService starts a new DiscoveryService and promotes the given
 // DiscoveryMessages through IPv4 and/or IPv6, as specified in the parameters.
-// Furthermore, received DiscoveryMessages will be processed.
-func NewDiscoveryService(dms []DiscoveryMessage, c *core.Core, ipv4, ipv6 bool) (*DiscoveryService, error) {
+// Furthermore, received DiscoveryMessages will be processed. The nodeId is
+// this node's endpoint ID, which is required to establish TCPCLv4 sessions.
+// It might be dtn:none, resulting in ignored TCPCLv4 peers.
+func NewDiscoveryService(dms []DiscoveryMessage, c *core.Core, nodeId bundle.EndpointID, ipv4, ipv6 bool) (*DiscoveryService, error) {
 	log.WithFields(log.Fields{
 		"ipv4":    ipv4,
 		"ipv6":    ipv6,
@@ -86,7 +111,8 @@ func NewDiscoveryService(dms []DiscoveryMessage, c *core.Core, ipv4, ipv6 bool)
 	}).Info("Started DiscoveryService")
 
 	var ds = &DiscoveryService{
-		c: c,
+		c:      c,
+		nodeId: nodeId,
 	}
 
 	if ipv4 {
@@ -2,9 +2,11 @@ module github.com/geistesk/dtn7
 
 require (
 	github.com/BurntSushi/toml v0.3.1
+	github.com/gorilla/websocket v1.4.1
 	github.com/hashicorp/go-multierror v1.0.0
 	github.com/howeyc/crc16 v0.0.0-20171223171357-2b2a61e366a6
 	github.com/schollz/peerdiscovery v1.4.0
 	github.com/sirupsen/logrus v1.3.0
 	github.com/ugorji/go/codec v0.0.0-20190128213124-ee1426cffec0
+	go.etcd.io/bbolt v1.3.6
 )